@@ -0,0 +1,41 @@
+// Package guestbook provides the guestbook as an http.Handler, so that it
+// can be mounted inside an existing Go service rather than run as its own
+// server.
+//
+// The handler needs a Postgres pool with the guestbook's migrations
+// applied, a Redis client, and the guestbook's templates. Everything else
+// is optional:
+//
+//	if err := guestbook.Migrate(ctx, pool); err != nil {
+//		return err
+//	}
+//
+//	gb, err := guestbook.New(
+//		guestbook.WithDB(pool),
+//		guestbook.WithRedis(rdb),
+//		guestbook.WithTemplates(templates),
+//		guestbook.WithStatic(os.DirFS("static")),
+//		guestbook.WithLogger(logger),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer gb.Close()
+//
+//	mux.Handle("guestbook.example.com/", gb)
+//
+// The handler must be mounted at the root of a host rather than under a
+// path prefix, and there's no option to change this. Its templates and
+// themes, its redirects and the links in push notifications all refer to
+// its pages by absolute path, such as /static/, /login and /g/<slug>, and
+// its session and access cookies are scoped to /. Stripping a prefix with
+// http.StripPrefix would serve the first page, but every link and redirect
+// from it would leave the prefix. A host of its own, such as
+// guestbook.example.com, keeps its paths and cookies apart from the
+// service's.
+//
+// Handler.ForwardAuth returns a second handler, for a Traefik ForwardAuth
+// middleware to apply the guestbook's ban list, rate limits and maintenance
+// mode to other services behind the proxy. It should be served on its own
+// listener, which only the proxy can reach.
+package guestbook
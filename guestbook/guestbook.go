@@ -0,0 +1,243 @@
+package guestbook
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+	"github.com/dreamsofcode-io/guestbook/internal/analytics"
+	"github.com/dreamsofcode-io/guestbook/internal/auth"
+	"github.com/dreamsofcode-io/guestbook/internal/forwardauth"
+	"github.com/dreamsofcode-io/guestbook/internal/handler"
+	"github.com/dreamsofcode-io/guestbook/internal/jobs"
+	"github.com/dreamsofcode-io/guestbook/internal/maintenance"
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+	"github.com/dreamsofcode-io/guestbook/internal/plugin"
+	"github.com/dreamsofcode-io/guestbook/internal/presence"
+	"github.com/dreamsofcode-io/guestbook/internal/push"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+	"github.com/dreamsofcode-io/guestbook/internal/seal"
+	"github.com/dreamsofcode-io/guestbook/internal/smtpd"
+	"github.com/dreamsofcode-io/guestbook/internal/theme"
+)
+
+var (
+	ErrMissingDB        = errors.New("guestbook: a database is required")
+	ErrMissingRedis     = errors.New("guestbook: a redis client is required")
+	ErrMissingTemplates = errors.New("guestbook: templates are required")
+)
+
+// Handler serves the guestbook.
+type Handler struct {
+	mux         *http.ServeMux
+	handler     http.Handler
+	forwardAuth http.Handler
+	static      fs.FS
+	hub         *presence.Hub
+	mail        *handler.Mail
+	plugins     *plugin.Host
+	cancel      context.CancelFunc
+}
+
+// New creates a Handler configured by the options. The handler runs
+// background work until it's closed.
+func New(opts ...Option) (*Handler, error) {
+	o := options{
+		logger:  slog.Default(),
+		filters: []Filter{Profanity},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch {
+	case o.db == nil:
+		return nil, ErrMissingDB
+	case o.rdb == nil:
+		return nil, ErrMissingRedis
+	case o.templates == nil:
+		return nil, ErrMissingTemplates
+	}
+
+	tmpl, err := template.New("").Funcs(handler.Funcs()).ParseFS(o.templates, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("guestbook: failed to parse templates: %w", err)
+	}
+
+	var sealer *seal.Sealer
+	if o.messageKey != nil {
+		if sealer, err = seal.New(o.messageKey); err != nil {
+			return nil, fmt.Errorf("guestbook: invalid message key: %w", err)
+		}
+	} else {
+		o.logger.Info("no message key set, private messages disabled")
+	}
+
+	var keys *push.Keys
+	if o.pushKey != nil {
+		if keys, err = push.ParseKeys(o.pushKey); err != nil {
+			return nil, fmt.Errorf("guestbook: invalid push key: %w", err)
+		}
+	}
+
+	if o.cookieSecret == nil {
+		o.logger.Warn("no cookie secret set, generating a random one")
+
+		o.cookieSecret = make([]byte, 32)
+		rand.Read(o.cookieSecret)
+	}
+
+	signer := access.NewSigner(o.cookieSecret)
+	sessions := auth.NewSessions(signer, time.Hour*24*14)
+
+	gate := access.NewGate(signer, time.Hour*24*30)
+
+	tracker := analytics.NewTracker(o.logger, o.rdb)
+	hub := presence.NewHub(o.rdb, time.Second*30)
+	themes := theme.Load(o.logger, tmpl, o.themesDir)
+
+	mode := maintenance.New(o.rdb)
+
+	guestbook := handler.New(
+		o.logger, o.db, themes, gate, sessions, sealer, tracker, hub, mode,
+	)
+	guestbook.Filters = o.filters
+	gate.RoleOf = guestbook.Role
+	guestbook.Proxies = o.proxies
+
+	login := handler.NewAuth(o.logger, o.db, tmpl, sessions)
+
+	if o.limit == nil {
+		// Record each time a visitor is turned away for moderators
+		limiter := &middleware.RateLimiter{
+			Period:  time.Minute,
+			MaxRate: 5,
+			Store:   o.rdb,
+			OnLimit: guestbook.RecordRateLimit,
+		}
+		o.limit = limiter.Middleware
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	rollup := analytics.NewRollup(o.logger, o.rdb, repository.New(o.db), time.Minute*5)
+	go rollup.Run(ctx)
+	go tracker.Run(ctx)
+
+	if keys != nil {
+		sender := push.NewSender(keys, o.pushSubject, nil)
+		guestbook.Push = push.NewService(o.logger, o.db, sender, signer)
+
+		queue := jobs.New(o.logger, repository.New(o.db))
+		guestbook.Push.Register(queue)
+		go queue.Run(ctx, time.Second*5)
+	}
+
+	if o.mailDomain != "" {
+		guestbook.Mail = handler.NewMail(
+			guestbook, o.mailDomain,
+			&middleware.RateLimiter{Period: time.Hour, MaxRate: 5, Store: o.rdb},
+			&middleware.RateLimiter{Period: time.Hour, MaxRate: 20, Store: o.rdb},
+		)
+	}
+
+	if o.pluginsDir != "" {
+		guestbook.Plugins = plugin.NewHost(o.logger, o.pluginsDir, plugin.DefaultLimits)
+		go guestbook.Plugins.Watch(ctx, time.Second*5)
+	}
+
+	h := &Handler{
+		mux:     http.NewServeMux(),
+		static:  o.static,
+		hub:     hub,
+		mail:    guestbook.Mail,
+		plugins: guestbook.Plugins,
+		cancel:  cancel,
+	}
+
+	h.loadRoutes(guestbook, login, o.limit)
+
+	proxies := middleware.Proxies(o.proxies)
+
+	h.handler = middleware.NewChain(
+		proxies.Middleware,
+		middleware.WithErrorPages(tmpl),
+	).Then(h.mux)
+
+	// The forward auth responses are sent on to the clients of other
+	// services as they are, so they skip the guestbook's error pages.
+	forwardAuth := http.NewServeMux()
+	forwardAuth.Handle("/forward-auth", middleware.NoStore.Middleware(&forwardauth.Handler{
+		Logger:      o.logger,
+		Banned:      repository.New(o.db).IsBanned,
+		Maintenance: mode.Status,
+		Admin:       guestbook.IsAdmin,
+		Policies:    forwardPolicies(o.rdb),
+		OnLimit:     guestbook.RecordRateLimit,
+	}))
+
+	h.forwardAuth = proxies.Middleware(forwardAuth)
+
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
+
+// ForwardAuth returns the handler for Traefik's ForwardAuth middleware,
+// served at /forward-auth. It answers for requests to other services, and
+// trusts the proxy to describe them, so it must be served on a listener
+// that only the proxy can reach rather than alongside the guestbook.
+func (h *Handler) ForwardAuth() http.Handler {
+	return h.forwardAuth
+}
+
+// Mail returns the backend receiving messages by email, for an SMTP
+// server to serve, or nil if it wasn't enabled with WithMail.
+func (h *Handler) Mail() smtpd.Backend {
+	if h.mail == nil {
+		return nil
+	}
+
+	return h.mail
+}
+
+// Close stops the handler's background work and ends any open presence
+// streams. It should be called when the server shuts down.
+func (h *Handler) Close() {
+	h.cancel()
+	h.hub.Close()
+
+	if h.plugins != nil {
+		h.plugins.Close(context.Background())
+	}
+}
+
+// forwardPolicies are the rate limits applied to requests checked through
+// the forward auth endpoint: generous for reading, and strict for anything
+// that changes state.
+func forwardPolicies(store redis.Cmdable) []forwardauth.Policy {
+	return []forwardauth.Policy{
+		{
+			Name:    "read",
+			Methods: []string{http.MethodGet, http.MethodHead, http.MethodOptions},
+			Limiter: &middleware.RateLimiter{Period: time.Minute, MaxRate: 1200, Store: store},
+		},
+		{
+			Name:    "write",
+			Methods: []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+			Limiter: &middleware.RateLimiter{Period: time.Minute, MaxRate: 5, Store: store},
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package guestbook_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/guestbook"
+)
+
+func TestNew(t *testing.T) {
+	// Neither connects until it's used
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/guestbook")
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:1"})
+	defer rdb.Close()
+
+	templates := fstest.MapFS{
+		"index.html": {Data: []byte(`{{ .Guestbook.Title }}`)},
+	}
+
+	testCases := []struct {
+		Description string
+		Options     []guestbook.Option
+		ExpectedErr error
+	}{
+		{
+			Description: "missing database",
+			Options: []guestbook.Option{
+				guestbook.WithRedis(rdb),
+				guestbook.WithTemplates(templates),
+			},
+			ExpectedErr: guestbook.ErrMissingDB,
+		},
+		{
+			Description: "missing redis",
+			Options: []guestbook.Option{
+				guestbook.WithDB(pool),
+				guestbook.WithTemplates(templates),
+			},
+			ExpectedErr: guestbook.ErrMissingRedis,
+		},
+		{
+			Description: "missing templates",
+			Options: []guestbook.Option{
+				guestbook.WithDB(pool),
+				guestbook.WithRedis(rdb),
+			},
+			ExpectedErr: guestbook.ErrMissingTemplates,
+		},
+		{
+			Description: "configured",
+			Options: []guestbook.Option{
+				guestbook.WithDB(pool),
+				guestbook.WithRedis(rdb),
+				guestbook.WithTemplates(templates),
+				guestbook.WithCookieSecret([]byte("secret")),
+				guestbook.WithFilters(),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			h, err := guestbook.New(tc.Options...)
+			if tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			h.Close()
+		})
+	}
+}
+
+func TestNewInvalidMessageKey(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/guestbook")
+	assert.NoError(t, err)
+	defer pool.Close()
+
+	_, err = guestbook.New(
+		guestbook.WithDB(pool),
+		guestbook.WithRedis(redis.NewClient(&redis.Options{Addr: "localhost:1"})),
+		guestbook.WithTemplates(fstest.MapFS{"index.html": {}}),
+		guestbook.WithMessageKey([]byte("too short")),
+	)
+	assert.ErrorContains(t, err, "invalid message key")
+}
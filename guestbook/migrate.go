@@ -0,0 +1,34 @@
+package guestbook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/dreamsofcode-io/guestbook/internal/database"
+	"github.com/dreamsofcode-io/guestbook/migrations"
+)
+
+// MigrationsTable is the table Migrate records the applied migrations in.
+// It isn't golang-migrate's default, so that a service using golang-migrate
+// for its own schema can keep doing so in the same database.
+const MigrationsTable = "guestbook_schema_migrations"
+
+// Migrate applies the guestbook's migrations to the pool's database,
+// creating the tables the handler needs in its current schema. Those that
+// have already been applied are skipped, and they're applied under an
+// advisory lock, so it's safe to call each time the service starts, from
+// any number of instances.
+//
+// The guestbook's own server records its migrations in schema_migrations
+// rather than MigrationsTable. A database it has migrated can be moved to
+// Migrate by renaming that table.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	err := database.Migrate(ctx, pool, migrations.FS, MigrationsTable)
+	if err != nil {
+		return fmt.Errorf("guestbook: %w", err)
+	}
+
+	return nil
+}
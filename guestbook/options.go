@@ -0,0 +1,166 @@
+package guestbook
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dreamsofcode-io/guestbook/internal/handler"
+)
+
+// Filter checks a message before it's stored, returning an error to
+// reject it. The error's text is shown to the visitor.
+type Filter = handler.Filter
+
+// Profanity is a Filter rejecting messages that contain profanity. It's
+// the only filter used by default.
+var Profanity Filter = handler.Profanity
+
+// Option configures a Handler.
+type Option func(*options)
+
+type options struct {
+	logger       *slog.Logger
+	db           *pgxpool.Pool
+	rdb          redis.Cmdable
+	templates    fs.FS
+	static       fs.FS
+	themesDir    string
+	pluginsDir   string
+	cookieSecret []byte
+	messageKey   []byte
+	pushKey      []byte
+	pushSubject  string
+	mailDomain   string
+	proxies      []netip.Prefix
+	filters      []Filter
+	limit        func(http.Handler) http.Handler
+}
+
+// WithLogger sets the logger errors are reported to. Defaults to
+// slog.Default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithDB sets the Postgres pool messages, guestbooks and accounts are
+// stored in. It's required, and must have the guestbook's migrations
+// applied, by Migrate or from migrations.FS.
+func WithDB(db *pgxpool.Pool) Option {
+	return func(o *options) {
+		o.db = db
+	}
+}
+
+// WithRedis sets the Redis client used for rate limiting, analytics and
+// presence. It's required.
+func WithRedis(rdb redis.Cmdable) Option {
+	return func(o *options) {
+		o.rdb = rdb
+	}
+}
+
+// WithTemplates sets the filesystem the page templates are parsed from,
+// with the templates at its root. It's required.
+func WithTemplates(templates fs.FS) Option {
+	return func(o *options) {
+		o.templates = templates
+	}
+}
+
+// WithStatic sets the filesystem served under /static/, which holds the
+// stylesheets the templates link to. Without it, nothing is served there.
+func WithStatic(static fs.FS) Option {
+	return func(o *options) {
+		o.static = static
+	}
+}
+
+// WithThemesDir sets the directory extra themes are loaded from.
+func WithThemesDir(dir string) Option {
+	return func(o *options) {
+		o.themesDir = dir
+	}
+}
+
+// WithPluginsDir sets the directory WebAssembly content filters are loaded
+// from. Messages passing the filters are checked by each plugin, which may
+// reject them or hold them for review. The directory is watched, so
+// plugins can be added, changed or removed without a restart.
+func WithPluginsDir(dir string) Option {
+	return func(o *options) {
+		o.pluginsDir = dir
+	}
+}
+
+// WithCookieSecret sets the key used to sign session and access cookies.
+// Without it a random key is used, so cookies won't survive a restart or
+// be shared between replicas.
+func WithCookieSecret(secret []byte) Option {
+	return func(o *options) {
+		o.cookieSecret = secret
+	}
+}
+
+// WithMessageKey sets the 32 byte key private messages are encrypted
+// with. Without it, private messages are disabled.
+func WithMessageKey(key []byte) Option {
+	return func(o *options) {
+		o.messageKey = key
+	}
+}
+
+// WithPush enables Web Push notifications, signed with the 32 byte VAPID
+// private key. The subject is a mailto: or https: URL push services can
+// contact the operator at. Owners can then be notified of new messages,
+// and visitors whose messages were held for review of their approval.
+func WithPush(key []byte, subject string) Option {
+	return func(o *options) {
+		o.pushKey = key
+		o.pushSubject = subject
+	}
+}
+
+// WithMail enables messages by email, sent to <slug>@<domain>. Owners
+// turn it on for each guestbook from its dashboard, optionally limiting
+// who may send, and the messages are held for their approval. Mail is
+// received by serving Handler.Mail with an SMTP server.
+func WithMail(domain string) Option {
+	return func(o *options) {
+		o.mailDomain = domain
+	}
+}
+
+// WithTrustedProxies sets the networks of the reverse proxies in front of
+// the handler. The client's address, which bans, rate limits and the
+// moderation history go by, is read from the X-Forwarded-For hops added
+// by these proxies. Without it, requests are taken to come directly from
+// the client.
+func WithTrustedProxies(proxies ...netip.Prefix) Option {
+	return func(o *options) {
+		o.proxies = proxies
+	}
+}
+
+// WithFilters replaces the filters messages are checked against before
+// they're stored.
+func WithFilters(filters ...Filter) Option {
+	return func(o *options) {
+		o.filters = filters
+	}
+}
+
+// WithRateLimiter replaces the middleware limiting how quickly visitors
+// can leave messages and reports. By default each address may submit 5
+// a minute, tracked in Redis.
+func WithRateLimiter(limit func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.limit = limit
+	}
+}
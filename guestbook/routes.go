@@ -0,0 +1,89 @@
+package guestbook
+
+import (
+	"net/http"
+
+	"github.com/dreamsofcode-io/guestbook/internal/handler"
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+	"github.com/dreamsofcode-io/guestbook/internal/router"
+)
+
+func (h *Handler) loadRoutes(
+	guestbook *handler.Guestbook, login *handler.Auth,
+	limit func(http.Handler) http.Handler,
+) {
+	r := router.New(h.mux)
+
+	if h.static != nil {
+		files := http.FileServer(http.FS(h.static))
+		r.With(middleware.Fingerprinted(middleware.Asset)).
+			Handle("GET /static/", http.StripPrefix("/static", files))
+	}
+
+	// The service worker is checked for updates whenever it's registered
+	r.HandleFunc("GET /push/sw.js", guestbook.ServiceWorker)
+
+	forms := r.With(middleware.NoStore.Middleware)
+	forms.HandleFunc("GET /login", login.Login)
+	forms.HandleFunc("POST /login", login.SignIn)
+	forms.HandleFunc("POST /logout", login.SignOut)
+	forms.HandleFunc("GET /signup", login.Signup)
+	forms.HandleFunc("POST /signup", login.CreateAccount)
+
+	forms.HandleFunc("GET /account", guestbook.Account)
+	forms.HandleFunc("POST /push/subscriptions", guestbook.Subscribe)
+	forms.HandleFunc("GET /new", guestbook.NewGuestbook)
+	forms.HandleFunc("POST /new", guestbook.CreateGuestbook)
+
+	admin := forms.Group("/admin")
+	admin.HandleFunc("GET", guestbook.Admin)
+	admin.HandleFunc("POST /accounts/{id}", guestbook.UpdatePlan)
+	admin.HandleFunc("POST /maintenance", guestbook.UpdateMaintenance)
+	admin.HandleFunc("GET /moderation", guestbook.Moderation)
+	admin.HandleFunc("POST /moderation", guestbook.Moderate)
+	admin.HandleFunc("GET /history", guestbook.History)
+
+	// Every guestbook is served at /g/{slug}, and the default one at the
+	// root as well.
+	for _, book := range []*router.Router{r, r.Group("/g/{slug}")} {
+		loadGuestbookRoutes(book, guestbook, limit)
+	}
+}
+
+func loadGuestbookRoutes(
+	r *router.Router, guestbook *handler.Guestbook,
+	limit func(http.Handler) http.Handler,
+) {
+	listing := middleware.Listing.WithKeys(handler.SurrogateKeys)
+
+	pages := r.With(listing.Middleware)
+	pages.HandleFunc("GET /{$}", guestbook.Home)
+	pages.HandleFunc("GET /tags/{tag}", guestbook.Tag)
+	pages.HandleFunc("GET /stats", guestbook.Stats)
+	pages.HandleFunc("GET /embed", guestbook.Embed)
+
+	r.With(middleware.Fingerprinted(listing)).
+		HandleFunc("GET /theme.css", guestbook.Stylesheet)
+
+	forms := r.With(middleware.NoStore.Middleware)
+	forms.HandleFunc("GET /access", guestbook.Access)
+	forms.HandleFunc("POST /access", guestbook.EnterCode)
+	forms.HandleFunc("GET /presence", guestbook.Presence)
+
+	limited := forms.With(limit)
+	limited.HandleFunc("POST /{$}", guestbook.Create)
+	limited.HandleFunc("POST /report", guestbook.Report)
+
+	dashboard := forms.Group("/dashboard")
+	dashboard.HandleFunc("GET", guestbook.Dashboard)
+	dashboard.HandleFunc("POST /settings", guestbook.UpdateSettings)
+	dashboard.HandleFunc("GET /share", guestbook.Share)
+	dashboard.HandleFunc("GET /qr.png", guestbook.QRCode)
+	dashboard.HandleFunc("POST /fields", guestbook.UpdateFields)
+	dashboard.HandleFunc("POST /tags/block", guestbook.BlockTag)
+	dashboard.HandleFunc("POST /tags/unblock", guestbook.UnblockTag)
+	dashboard.HandleFunc("POST /theme", guestbook.UpdateTheme)
+	dashboard.HandleFunc("POST /email", guestbook.UpdateEmail)
+	dashboard.HandleFunc("POST /viewers/invite", guestbook.InviteViewer)
+	dashboard.HandleFunc("POST /viewers/remove", guestbook.RemoveViewer)
+}
@@ -0,0 +1,4 @@
+// Package access decides who is allowed to view a guestbook based on its
+// visibility setting, and issues the signed cookies that remember a visitor
+// has entered the correct access code.
+package access
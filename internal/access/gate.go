@@ -0,0 +1,167 @@
+package access
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// Decision is the outcome of checking a request against a guestbook's
+// visibility.
+type Decision int
+
+const (
+	// Allow means the request may view the guestbook.
+	Allow Decision = iota
+	// NeedsCode means the visitor must enter the access code first.
+	NeedsCode
+	// NeedsLogin means the visitor must sign in first.
+	NeedsLogin
+	// Forbidden means the visitor is signed in, but wasn't invited to view
+	// the guestbook.
+	Forbidden
+)
+
+// Role is how the account a request is signed in as relates to a
+// guestbook.
+type Role int
+
+const (
+	// Anonymous requests aren't signed in.
+	Anonymous Role = iota
+	// Stranger accounts have nothing to do with the guestbook. Anyone can
+	// sign up for one, so they're treated as any other visitor.
+	Stranger
+	// Invited accounts were let in by the owner to view the guestbook when
+	// it requires signing in.
+	Invited
+	// Owner is the guestbook's owner, or an admin.
+	Owner
+)
+
+const cookiePrefix = "guestbook_access_"
+
+// Gate enforces guestbook visibility settings.
+type Gate struct {
+	signer *Signer
+	ttl    time.Duration
+
+	// RoleOf reports how the account the request is signed in as relates
+	// to the guestbook. If it is nil then no request is considered signed
+	// in.
+	RoleOf func(r *http.Request, gb repository.Guestbook) Role
+}
+
+func NewGate(signer *Signer, ttl time.Duration) *Gate {
+	return &Gate{
+		signer: signer,
+		ttl:    ttl,
+	}
+}
+
+// Check decides whether the request may view the given guestbook. Only
+// the owner skips the access code, and only the owner and the accounts
+// they invited may view a guestbook that requires signing in.
+func (g *Gate) Check(r *http.Request, gb repository.Guestbook) Decision {
+	switch Visibility(gb.Visibility) {
+	case Public, Unlisted:
+		return Allow
+	case AccessCode:
+		if g.role(r, gb) == Owner || g.hasGrant(r, gb) {
+			return Allow
+		}
+		return NeedsCode
+	default:
+		switch g.role(r, gb) {
+		case Owner, Invited:
+			return Allow
+		case Stranger:
+			return Forbidden
+		default:
+			return NeedsLogin
+		}
+	}
+}
+
+func (g *Gate) role(r *http.Request, gb repository.Guestbook) Role {
+	if g.RoleOf == nil {
+		return Anonymous
+	}
+
+	return g.RoleOf(r, gb)
+}
+
+// codeTag ties a grant to the current access code, so that changing the
+// code revokes every grant issued for the old one.
+func codeTag(gb repository.Guestbook) string {
+	sum := sha256.Sum256([]byte(gb.AccessCodeHash))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (g *Gate) hasGrant(r *http.Request, gb repository.Guestbook) bool {
+	cookie, err := r.Cookie(cookiePrefix + gb.ID.String())
+	if err != nil {
+		return false
+	}
+
+	value, ok := g.signer.Verify(cookie.Value)
+	if !ok {
+		return false
+	}
+
+	parts := strings.Split(value, "|")
+	if len(parts) != 3 || parts[0] != gb.ID.String() || parts[2] != codeTag(gb) {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Before(time.Unix(expires, 0))
+}
+
+// VerifyCode reports whether the code matches the guestbook's access code.
+func (g *Gate) VerifyCode(gb repository.Guestbook, code string) bool {
+	if gb.AccessCodeHash == "" {
+		return false
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(gb.AccessCodeHash), []byte(code))
+	return err == nil
+}
+
+// Grant sets a signed cookie allowing the visitor to view the guestbook
+// until it expires.
+func (g *Gate) Grant(w http.ResponseWriter, gb repository.Guestbook) {
+	expires := time.Now().Add(g.ttl)
+	value := fmt.Sprintf("%s|%d|%s", gb.ID, expires.Unix(), codeTag(gb))
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookiePrefix + gb.ID.String(),
+		Value:    g.signer.Sign(value),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// HashCode hashes an access code for storage.
+func HashCode(code string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash code: %w", err)
+	}
+
+	return string(hash), nil
+}
@@ -0,0 +1,123 @@
+package access_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+func TestGateCheck(t *testing.T) {
+	gate := access.NewGate(access.NewSigner([]byte("secret")), time.Hour)
+
+	hash, err := access.HashCode("letmein")
+	assert.NoError(t, err)
+
+	gb := repository.Guestbook{
+		ID:             uuid.New(),
+		Visibility:     string(access.AccessCode),
+		AccessCodeHash: hash,
+	}
+
+	t.Run("public and unlisted are allowed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+
+		for _, v := range []access.Visibility{access.Public, access.Unlisted} {
+			gb := gb
+			gb.Visibility = string(v)
+			assert.Equal(t, access.Allow, gate.Check(req, gb))
+		}
+	})
+
+	t.Run("access code requires a grant", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		assert.Equal(t, access.NeedsCode, gate.Check(req, gb))
+	})
+
+	t.Run("verifying the code", func(t *testing.T) {
+		assert.True(t, gate.VerifyCode(gb, "letmein"))
+		assert.False(t, gate.VerifyCode(gb, "wrong"))
+	})
+
+	t.Run("granted cookie allows access", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		gate.Grant(w, gb)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+
+		assert.Equal(t, access.Allow, gate.Check(req, gb))
+
+		changed := gb
+		changed.AccessCodeHash = "changed"
+		assert.Equal(t, access.NeedsCode, gate.Check(req, changed))
+	})
+
+	t.Run("tampered cookie is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{
+			Name:  "guestbook_access_" + gb.ID.String(),
+			Value: gb.ID.String() + "|99999999999|abc.forged",
+		})
+
+		assert.Equal(t, access.NeedsCode, gate.Check(req, gb))
+	})
+
+	t.Run("only the owner skips the access code", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+
+		testCases := []struct {
+			Role     access.Role
+			Expected access.Decision
+		}{
+			{access.Anonymous, access.NeedsCode},
+			{access.Stranger, access.NeedsCode},
+			{access.Invited, access.NeedsCode},
+			{access.Owner, access.Allow},
+		}
+
+		for _, tc := range testCases {
+			gate := withRole(gate, tc.Role)
+			assert.Equal(t, tc.Expected, gate.Check(req, gb), "role %d", tc.Role)
+		}
+	})
+
+	t.Run("login required", func(t *testing.T) {
+		gb := gb
+		gb.Visibility = string(access.LoginRequired)
+		req := httptest.NewRequest("GET", "/", nil)
+
+		assert.Equal(t, access.NeedsLogin, gate.Check(req, gb))
+
+		testCases := []struct {
+			Role     access.Role
+			Expected access.Decision
+		}{
+			{access.Anonymous, access.NeedsLogin},
+			{access.Stranger, access.Forbidden},
+			{access.Invited, access.Allow},
+			{access.Owner, access.Allow},
+		}
+
+		for _, tc := range testCases {
+			gate := withRole(gate, tc.Role)
+			assert.Equal(t, tc.Expected, gate.Check(req, gb), "role %d", tc.Role)
+		}
+	})
+}
+
+// withRole returns a copy of the gate that treats every request as coming
+// from an account with the role.
+func withRole(gate *access.Gate, role access.Role) *access.Gate {
+	g := *gate
+	g.RoleOf = func(*http.Request, repository.Guestbook) access.Role { return role }
+	return &g
+}
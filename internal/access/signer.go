@@ -0,0 +1,53 @@
+package access
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// Signer signs values with an HMAC so they can be handed to a client and
+// later verified as untampered.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{
+		key: key,
+	}
+}
+
+func (s *Signer) mac(value string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(value))
+	return h.Sum(nil)
+}
+
+// Sign returns the value with its signature appended.
+func (s *Signer) Sign(value string) string {
+	return value + "." + base64.RawURLEncoding.EncodeToString(s.mac(value))
+}
+
+// Verify checks the signature of a value produced by Sign, returning the
+// original value if the signature is valid.
+func (s *Signer) Verify(signed string) (string, bool) {
+	idx := strings.LastIndex(signed, ".")
+	if idx < 0 {
+		return "", false
+	}
+
+	value := signed[:idx]
+
+	sig, err := base64.RawURLEncoding.DecodeString(signed[idx+1:])
+	if err != nil {
+		return "", false
+	}
+
+	if !hmac.Equal(sig, s.mac(value)) {
+		return "", false
+	}
+
+	return value, true
+}
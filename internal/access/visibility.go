@@ -0,0 +1,37 @@
+package access
+
+import "fmt"
+
+// Visibility controls who is able to see a guestbook and its messages.
+type Visibility string
+
+const (
+	// Public guestbooks can be viewed by anyone.
+	Public Visibility = "public"
+	// Unlisted guestbooks can be viewed by anyone with the link, but are
+	// never listed and are excluded from search engines.
+	Unlisted Visibility = "unlisted"
+	// AccessCode guestbooks require the visitor to enter a code shared by
+	// the owner before they can be viewed.
+	AccessCode Visibility = "access_code"
+	// LoginRequired guestbooks can only be viewed by their owner and the
+	// accounts they invite, once signed in.
+	LoginRequired Visibility = "login_required"
+)
+
+// ParseVisibility converts a stored or submitted value into a Visibility,
+// returning an error if it isn't one of the known settings.
+func ParseVisibility(value string) (Visibility, error) {
+	switch v := Visibility(value); v {
+	case Public, Unlisted, AccessCode, LoginRequired:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unknown visibility: %q", value)
+	}
+}
+
+// Indexable reports whether pages with this visibility may be indexed by
+// search engines.
+func (v Visibility) Indexable() bool {
+	return v == Public
+}
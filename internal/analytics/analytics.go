@@ -0,0 +1,168 @@
+// Package analytics counts page views and unique visitors per guestbook
+// without cookies. Visitors are identified by a hash of their IP and user
+// agent salted with a value that changes daily, so they can't be tracked
+// across days, and only the HyperLogLog of those hashes is kept.
+package analytics
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// retention is how long the raw counters are kept in redis, which needs
+// to comfortably outlast the gap between rollups.
+const retention = time.Hour * 24 * 3
+
+const dayFormat = "2006-01-02"
+
+// queueSize is the most views waiting to be recorded. Views past it are
+// dropped rather than holding up pages while redis is slow.
+const queueSize = 1024
+
+// recordTimeout bounds how long recording a single view may take.
+const recordTimeout = time.Second * 2
+
+// view is a page view waiting to be recorded.
+type view struct {
+	guestbookID uuid.UUID
+	ip          string
+	userAgent   string
+}
+
+// Tracker records page views in redis.
+type Tracker struct {
+	logger *slog.Logger
+	store  redis.Cmdable
+	queue  chan view
+
+	mu   sync.Mutex
+	day  string
+	salt string
+}
+
+func NewTracker(logger *slog.Logger, store redis.Cmdable) *Tracker {
+	return &Tracker{
+		logger: logger,
+		store:  store,
+		queue:  make(chan view, queueSize),
+	}
+}
+
+// Track queues a page view to be recorded by Run, without waiting for it.
+// It returns false if the view was dropped because the queue is full.
+func (t *Tracker) Track(guestbookID uuid.UUID, ip string, userAgent string) bool {
+	select {
+	case t.queue <- view{guestbookID: guestbookID, ip: ip, userAgent: userAgent}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run records the queued page views one at a time until the context is
+// done.
+func (t *Tracker) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case v := <-t.queue:
+			recordCtx, cancel := context.WithTimeout(ctx, recordTimeout)
+			err := t.Record(recordCtx, v.guestbookID, v.ip, v.userAgent)
+			cancel()
+
+			if err != nil {
+				t.logger.Warn("failed to record view", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func viewsKey(guestbookID uuid.UUID, day string) string {
+	return fmt.Sprintf("analytics:views:%s:%s", guestbookID, day)
+}
+
+func visitorsKey(guestbookID uuid.UUID, day string) string {
+	return fmt.Sprintf("analytics:visitors:%s:%s", guestbookID, day)
+}
+
+func activeKey(day string) string {
+	return "analytics:active:" + day
+}
+
+// dailySalt returns the salt for the day, creating it if this is the first
+// replica to need it. Once the day is over the salt expires and can no
+// longer be used to link visitor hashes back to anyone.
+func (t *Tracker) dailySalt(ctx context.Context, day string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.day == day {
+		return t.salt, nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := "analytics:salt:" + day
+
+	err := t.store.SetNX(ctx, key, hex.EncodeToString(buf), time.Hour*25).Err()
+	if err != nil {
+		return "", fmt.Errorf("failed to set salt: %w", err)
+	}
+
+	salt, err := t.store.Get(ctx, key).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get salt: %w", err)
+	}
+
+	t.day = day
+	t.salt = salt
+
+	return salt, nil
+}
+
+// Record counts a page view of the guestbook by the visitor identified by
+// their IP and user agent.
+func (t *Tracker) Record(
+	ctx context.Context, guestbookID uuid.UUID, ip string, userAgent string,
+) error {
+	day := time.Now().UTC().Format(dayFormat)
+
+	salt, err := t.dailySalt(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(salt + "|" + ip + "|" + userAgent))
+	visitor := hex.EncodeToString(sum[:16])
+
+	views := viewsKey(guestbookID, day)
+	visitors := visitorsKey(guestbookID, day)
+
+	_, err = t.store.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, views)
+		pipe.Expire(ctx, views, retention)
+		pipe.PFAdd(ctx, visitors, visitor)
+		pipe.Expire(ctx, visitors, retention)
+		pipe.SAdd(ctx, activeKey(day), guestbookID.String())
+		pipe.Expire(ctx, activeKey(day), retention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record view: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package analytics_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/analytics"
+)
+
+func TestTrackDropsViewsWhenFull(t *testing.T) {
+	// Nothing runs the tracker, so views are only ever queued
+	tracker := analytics.NewTracker(slog.Default(), nil)
+	id := uuid.New()
+
+	queued := 0
+	for tracker.Track(id, "203.0.113.7", "test") {
+		queued++
+		if queued > 100000 {
+			t.Fatal("the queue is unbounded")
+		}
+	}
+
+	assert.Positive(t, queued)
+	assert.False(t, tracker.Track(id, "203.0.113.7", "test"))
+}
@@ -0,0 +1,109 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// Rollup periodically copies the counters in redis into postgres, where
+// they're kept for the stats page.
+type Rollup struct {
+	logger   *slog.Logger
+	store    redis.Cmdable
+	repo     *repository.Queries
+	interval time.Duration
+}
+
+func NewRollup(
+	logger *slog.Logger, store redis.Cmdable, repo *repository.Queries,
+	interval time.Duration,
+) *Rollup {
+	return &Rollup{
+		logger:   logger,
+		store:    store,
+		repo:     repo,
+		interval: interval,
+	}
+}
+
+// Run rolls up the counters every interval until the context is done.
+func (r *Rollup) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Once(ctx); err != nil {
+				r.logger.Error("failed to roll up analytics", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Once rolls up today's and yesterday's counters, so that the final
+// counts for a day are saved after it ends. Upserts keep the highest value
+// seen, which makes it safe for every replica to run the rollup.
+func (r *Rollup) Once(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now} {
+		if err := r.rollupDay(ctx, day.Truncate(time.Hour*24)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Rollup) rollupDay(ctx context.Context, day time.Time) error {
+	key := day.Format(dayFormat)
+
+	ids, err := r.store.SMembers(ctx, activeKey(key)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get active guestbooks: %w", err)
+	}
+
+	for _, raw := range ids {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		views, err := r.store.Get(ctx, viewsKey(id, key)).Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("failed to get views: %w", err)
+		}
+
+		visitors, err := r.store.PFCount(ctx, visitorsKey(id, key)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to count visitors: %w", err)
+		}
+
+		err = r.repo.UpsertTraffic(ctx, repository.UpsertTrafficParams{
+			GuestbookID: id,
+			Day:         day,
+			Views:       views,
+			Visitors:    visitors,
+		})
+		if err != nil {
+			// Carry on with the rest, as this guestbook may have been deleted
+			r.logger.Error(
+				"failed to upsert traffic",
+				slog.String("guestbook", raw), slog.Any("error", err),
+			)
+		}
+	}
+
+	return nil
+}
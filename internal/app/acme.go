@@ -0,0 +1,29 @@
+package app
+
+import (
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/certcache"
+	"github.com/dreamsofcode-io/guestbook/internal/config"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// certManager creates the manager obtaining certificates through ACME,
+// answering TLS-ALPN-01 challenges on the TLS listener and HTTP-01 through
+// its HTTP handler. Certificates are kept in the database so that every
+// instance shares them.
+func (a *App) certManager(cfg *config.Server) *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      certcache.New(repository.New(a.db)),
+		HostPolicy: autocert.HostWhitelist(cfg.ACMEDomains...),
+		Email:      cfg.ACMEEmail,
+	}
+
+	if cfg.ACMEDirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+	}
+
+	return m
+}
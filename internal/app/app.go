@@ -2,45 +2,33 @@ package app
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"html/template"
 	"io/fs"
 	"log/slog"
-	"net/http"
 	"os"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/dreamsofcode-io/guestbook/guestbook"
+	"github.com/dreamsofcode-io/guestbook/internal/config"
 	"github.com/dreamsofcode-io/guestbook/internal/database"
 	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+	"github.com/dreamsofcode-io/guestbook/internal/sentry"
 )
 
 type App struct {
 	logger     *slog.Logger
-	router     *http.ServeMux
 	db         *pgxpool.Pool
-	rdb        *redis.Client
+	rdb        redis.UniversalClient
 	migrations fs.FS
 	templates  fs.FS
 }
 
 func New(logger *slog.Logger, migrations fs.FS, templates fs.FS) *App {
-	router := http.NewServeMux()
-
-	redisAddr, exists := os.LookupEnv("REDIS_ADDR")
-	if !exists {
-		redisAddr = "localhost:6379"
-	}
-
 	app := &App{
-		logger: logger,
-		router: router,
-		rdb: redis.NewClient(&redis.Options{
-			Addr: redisAddr,
-		}),
+		logger:     logger,
 		migrations: migrations,
 		templates:  templates,
 	}
@@ -49,6 +37,17 @@ func New(logger *slog.Logger, migrations fs.FS, templates fs.FS) *App {
 }
 
 func (a *App) Start(ctx context.Context) error {
+	reporter, err := a.errorReporter()
+	if err != nil {
+		return err
+	}
+
+	if reporter != nil {
+		defer a.flushReports(reporter)
+
+		a.logger = slog.New(sentry.NewHandler(a.logger.Handler(), reporter))
+	}
+
 	db, err := database.Connect(ctx, a.logger, a.migrations)
 	if err != nil {
 		return fmt.Errorf("failed to connect to db: %w", err)
@@ -56,33 +55,103 @@ func (a *App) Start(ctx context.Context) error {
 
 	a.db = db
 
-	tmpl := template.Must(template.New("").ParseFS(a.templates, "templates/*"))
+	rdb, err := a.connectRedis(ctx)
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+
+	a.rdb = rdb
+
+	if err := a.bootstrapOwner(ctx); err != nil {
+		return fmt.Errorf("failed to bootstrap owner: %w", err)
+	}
+
+	cfg, err := config.NewServer()
+	if err != nil {
+		return fmt.Errorf("failed to load server config: %w", err)
+	}
+
+	opts, err := a.options(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	gb, err := guestbook.New(opts...)
+	if err != nil {
+		return err
+	}
+	defer gb.Close()
+
+	chain := middleware.NewChain(
+		middleware.WithLogging(a.logger),
+	)
 
-	a.loadRoutes(tmpl)
+	if reporter != nil {
+		chain = chain.Append(reporter.Middleware)
+	}
+
+	return a.serve(ctx, cfg, chain.Then(gb), chain.Then(gb.ForwardAuth()), gb.Mail(), gb.Close)
+}
+
+// flushReports sends the errors reported before shutting down.
+func (a *App) flushReports(reporter *sentry.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if err := reporter.Close(ctx); err != nil {
+		a.logger.Warn("failed to send error reports", slog.Any("error", err))
+	}
+}
+
+// options configures the guestbook from the environment.
+func (a *App) options(ctx context.Context, cfg *config.Server) ([]guestbook.Option, error) {
+	templates, err := fs.Sub(a.templates, "templates")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load templates: %w", err)
+	}
+
+	opts := []guestbook.Option{
+		guestbook.WithLogger(a.logger),
+		guestbook.WithDB(a.db),
+		guestbook.WithRedis(a.rdb),
+		guestbook.WithTemplates(templates),
+		guestbook.WithStatic(os.DirFS("static")),
+		guestbook.WithThemesDir(os.Getenv("THEMES_DIR")),
+		guestbook.WithPluginsDir(os.Getenv("PLUGINS_DIR")),
+		guestbook.WithTrustedProxies(cfg.TrustedProxies...),
+	}
 
-	server := http.Server{
-		Addr:    ":8080",
-		Handler: middleware.Logging(a.logger, middleware.HandleBadCode(tmpl, a.router)),
+	if secret, err := config.Secret("COOKIE_SECRET"); err == nil && secret != "" {
+		opts = append(opts, guestbook.WithCookieSecret([]byte(secret)))
 	}
 
-	done := make(chan struct{})
-	go func() {
-		err := server.ListenAndServe()
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			a.logger.Error("failed to listen and serve", slog.Any("error", err))
-		}
-		close(done)
-	}()
+	key, err := a.messageKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if key != nil {
+		opts = append(opts, guestbook.WithMessageKey(key))
+	}
+
+	pushKey, subject, err := a.pushConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if pushKey != nil {
+		opts = append(opts, guestbook.WithPush(pushKey, subject))
+	}
+
+	mail, err := config.NewMail()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mail config: %w", err)
+	}
 
-	a.logger.Info("Server listening", slog.String("addr", ":8080"))
-	select {
-	case <-done:
-		break
-	case <-ctx.Done():
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
-		server.Shutdown(ctx)
-		cancel()
+	if mail.Enabled() {
+		opts = append(opts, guestbook.WithMail(mail.Domain))
 	}
 
-	return nil
+	return opts, nil
 }
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dreamsofcode-io/guestbook/internal/auth"
+	"github.com/dreamsofcode-io/guestbook/internal/config"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// bootstrapOwner creates or updates the account configured by OWNER_EMAIL
+// and OWNER_PASSWORD as an admin, making it the owner of the default
+// guestbook if it doesn't already have one.
+func (a *App) bootstrapOwner(ctx context.Context) error {
+	email, ok := os.LookupEnv("OWNER_EMAIL")
+	if !ok {
+		return nil
+	}
+
+	password, err := config.Secret("OWNER_PASSWORD")
+	if err != nil {
+		return fmt.Errorf("loading owner password: %w", err)
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return fmt.Errorf("failed to create account id: %w", err)
+	}
+
+	repo := repository.New(a.db)
+
+	account, err := repo.UpsertAccount(ctx, repository.UpsertAccountParams{
+		ID:           id,
+		Email:        strings.ToLower(strings.TrimSpace(email)),
+		PasswordHash: hash,
+		CreatedAt:    time.Now().UTC(),
+		Admin:        true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upsert owner: %w", err)
+	}
+
+	err = repo.ClaimGuestbook(ctx, repository.ClaimGuestbookParams{
+		Slug:    "default",
+		OwnerID: uuid.NullUUID{UUID: account.ID, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to claim default guestbook: %w", err)
+	}
+
+	return nil
+}
+
+// messageKey loads the key used to encrypt private messages from the
+// MESSAGE_KEY secret. If no key is configured, private messages are
+// disabled rather than encrypted with a key that would be lost.
+func (a *App) messageKey() ([]byte, error) {
+	key, err := config.Secret("MESSAGE_KEY")
+	if err != nil {
+		return nil, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message key: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dreamsofcode-io/guestbook/internal/config"
+	"github.com/dreamsofcode-io/guestbook/internal/push"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// pushConfig returns the VAPID key and subject push notifications are
+// sent with, or a nil key if VAPID_SUBJECT isn't set to enable them.
+//
+// The key is taken from VAPID_PRIVATE_KEY, the base64url encoded private
+// key, and otherwise generated once and kept in the database, so that
+// every replica uses the same key and subscriptions survive restarts.
+func (a *App) pushConfig(ctx context.Context) ([]byte, string, error) {
+	subject := os.Getenv("VAPID_SUBJECT")
+	if subject == "" {
+		return nil, "", nil
+	}
+
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https://") {
+		return nil, "", fmt.Errorf("invalid vapid subject, must be a mailto: or https:// url")
+	}
+
+	encoded, err := config.OptionalSecret("VAPID_PRIVATE_KEY")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load vapid key: %w", err)
+	}
+
+	if encoded != "" {
+		key, err := push.Decode(encoded)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid vapid key: %w", err)
+		}
+
+		return key, subject, nil
+	}
+
+	key, err := a.storedVapidKey(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, subject, nil
+}
+
+// storedVapidKey returns the key kept in the database, generating it if
+// there isn't one yet.
+func (a *App) storedVapidKey(ctx context.Context) ([]byte, error) {
+	repo := repository.New(a.db)
+
+	key, err := repo.FindVapidKey(ctx)
+	if err == nil {
+		return key, nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to find vapid key: %w", err)
+	}
+
+	keys, err := push.GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repo.InsertVapidKey(ctx, keys.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to store vapid key: %w", err)
+	}
+
+	a.logger.Info("generated vapid key for push notifications")
+
+	// Another replica may have stored its key first, in which case that
+	// one is used
+	key, err = repo.FindVapidKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find vapid key: %w", err)
+	}
+
+	return key, nil
+}
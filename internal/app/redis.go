@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dreamsofcode-io/guestbook/internal/config"
+)
+
+// connectRedis creates the redis client from the environment. The client
+// connects lazily, and rate limiting and analytics carry on without redis,
+// so an unreachable server is reported rather than stopping startup.
+func (a *App) connectRedis(ctx context.Context) (redis.UniversalClient, error) {
+	cfg, err := config.NewRedis()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load redis config: %w", err)
+	}
+
+	rdb, err := cfg.Client()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create redis client: %w", err)
+	}
+
+	attrs := []any{
+		slog.String("mode", cfg.Mode()),
+		slog.Any("addrs", cfg.Addrs),
+		slog.Int("db", cfg.DB),
+		slog.Bool("tls", cfg.TLS),
+		slog.Bool("auth", cfg.Password != ""),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		a.logger.Warn(
+			"redis is unreachable, rate limiting and analytics are degraded until it is",
+			append(attrs, slog.Any("error", err))...,
+		)
+
+		return rdb, nil
+	}
+
+	a.logger.Info("connected to redis", attrs...)
+
+	return rdb, nil
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"github.com/dreamsofcode-io/guestbook/internal/config"
+	"github.com/dreamsofcode-io/guestbook/internal/sentry"
+)
+
+// errorReporter creates the client reporting errors to SENTRY_DSN, or nil
+// if it isn't set. SENTRY_RELEASE names the version running, defaulting to
+// the commit it was built from, and SENTRY_ENVIRONMENT where it's running.
+func (a *App) errorReporter() (*sentry.Client, error) {
+	dsn, err := config.OptionalSecret("SENTRY_DSN")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sentry dsn: %w", err)
+	}
+
+	if dsn == "" {
+		return nil, nil
+	}
+
+	release := os.Getenv("SENTRY_RELEASE")
+	if release == "" {
+		release = buildRevision()
+	}
+
+	client, err := sentry.New(sentry.Options{
+		DSN:         dsn,
+		Release:     release,
+		Environment: os.Getenv("SENTRY_ENVIRONMENT"),
+		Logger:      a.logger,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create error reporter: %w", err)
+	}
+
+	return client, nil
+}
+
+// buildRevision returns the commit the binary was built from, if known.
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return ""
+}
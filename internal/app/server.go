@@ -0,0 +1,285 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"github.com/dreamsofcode-io/guestbook/internal/config"
+	"github.com/dreamsofcode-io/guestbook/internal/listener"
+	"github.com/dreamsofcode-io/guestbook/internal/smtpd"
+)
+
+// shutdownTimeout is how long requests in flight are given to finish when
+// the server shuts down.
+const shutdownTimeout = time.Second * 30
+
+// upgradeTimeout is how long a new process is given to start, including
+// connecting to the database and migrating it, before an upgrade is
+// abandoned.
+const upgradeTimeout = time.Minute
+
+// serve listens with the handler until the context is done or a listener
+// fails, then shuts the listeners down gracefully. With HTTP/3 enabled it
+// listens over QUIC too, and responses over TCP advertise it with Alt-Svc.
+// With ACME it also listens over plain HTTP, for HTTP-01 challenges, and
+// with a mail backend it receives mail over SMTP. The forward auth handler
+// is only served when it has an address of its own.
+//
+// Sockets passed in through systemd socket activation are listened on
+// when their address matches, and SIGUSR2 hands the sockets over to a new
+// process, shutting down once it's ready.
+func (a *App) serve(
+	ctx context.Context, cfg *config.Server, handler, forwardAuth http.Handler,
+	mail smtpd.Backend, onShutdown func(),
+) error {
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	var challenges *http.Server
+	if cfg.ACME() {
+		m := a.certManager(cfg)
+
+		tlsConfig = m.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+
+		challenges = &http.Server{
+			Addr:    cfg.ACMEHTTPAddr,
+			Handler: m.HTTPHandler(nil),
+		}
+	}
+
+	server := &http.Server{
+		Addr:      cfg.Addr,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+
+	server.RegisterOnShutdown(onShutdown)
+
+	var auth *http.Server
+	if cfg.ForwardAuthAddr != "" {
+		auth = &http.Server{
+			Addr:    cfg.ForwardAuthAddr,
+			Handler: forwardAuth,
+		}
+	}
+
+	var h3 *http3.Server
+	if cfg.HTTP3 {
+		h3 = &http3.Server{
+			Addr:      cfg.Addr,
+			Port:      cfg.HTTP3Port,
+			Handler:   handler,
+			TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+			Logger:    a.logger,
+		}
+
+		server.Handler = advertiseHTTP3(h3, handler)
+	}
+
+	var (
+		smtp     *smtpd.Server
+		smtpAddr string
+	)
+	if mail != nil {
+		mailCfg, err := config.NewMail()
+		if err != nil {
+			return fmt.Errorf("failed to load mail config: %w", err)
+		}
+
+		smtpAddr = mailCfg.Addr
+		smtp = &smtpd.Server{
+			Domain:  mailCfg.Domain,
+			Backend: mail,
+			Logger:  a.logger,
+			// Each message goes to a single guestbook
+			MaxRecipients: 1,
+		}
+	}
+
+	sockets, err := listener.Inherit()
+	if err != nil {
+		return fmt.Errorf("failed to inherit sockets: %w", err)
+	}
+	defer sockets.Close()
+
+	var (
+		l    net.Listener
+		conn net.PacketConn
+		cl   net.Listener
+		ml   net.Listener
+		al   net.Listener
+	)
+
+	l, err = sockets.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	defer l.Close()
+
+	if h3 != nil {
+		// Serving QUIC doesn't close the connection it's given
+		conn, err = sockets.ListenPacket("udp", cfg.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for http3: %w", err)
+		}
+		defer conn.Close()
+	}
+
+	if challenges != nil {
+		cl, err = sockets.Listen("tcp", cfg.ACMEHTTPAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for acme challenges: %w", err)
+		}
+		defer cl.Close()
+	}
+
+	if smtp != nil {
+		ml, err = sockets.Listen("tcp", smtpAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for smtp: %w", err)
+		}
+		defer ml.Close()
+	}
+
+	if auth != nil {
+		al, err = sockets.Listen("tcp", cfg.ForwardAuthAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for forward auth: %w", err)
+		}
+		defer al.Close()
+	}
+
+	errs := make(chan error, 5)
+	go func() {
+		if tlsConfig != nil {
+			errs <- server.ServeTLS(l, "", "")
+		} else {
+			errs <- server.Serve(l)
+		}
+	}()
+
+	if h3 != nil {
+		go func() {
+			errs <- h3.Serve(conn)
+		}()
+	}
+
+	if challenges != nil {
+		go func() {
+			errs <- challenges.Serve(cl)
+		}()
+	}
+
+	if smtp != nil {
+		go func() {
+			errs <- smtp.Serve(ml)
+		}()
+	}
+
+	if auth != nil {
+		go func() {
+			errs <- auth.Serve(al)
+		}()
+	}
+
+	a.logger.Info(
+		"Server listening",
+		slog.String("addr", cfg.Addr),
+		slog.Bool("tls", cfg.TLS()),
+		slog.Bool("http3", cfg.HTTP3),
+		slog.Bool("acme", cfg.ACME()),
+		slog.String("smtp", smtpAddr),
+		slog.String("forward_auth", cfg.ForwardAuthAddr),
+	)
+
+	if err := sockets.Ready(); err != nil {
+		a.logger.Error("failed to hand over from the old process", slog.Any("error", err))
+	}
+
+	upgrades := make(chan os.Signal, 1)
+	listener.NotifyUpgrade(upgrades)
+	defer signal.Stop(upgrades)
+
+wait:
+	for {
+		select {
+		case err = <-errs:
+			break wait
+		case <-ctx.Done():
+			break wait
+		case <-upgrades:
+			if a.upgrade(ctx, sockets) {
+				break wait
+			}
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	server.Shutdown(shutdownCtx)
+	if h3 != nil {
+		h3.Shutdown(shutdownCtx)
+	}
+
+	if challenges != nil {
+		challenges.Shutdown(shutdownCtx)
+	}
+
+	if smtp != nil {
+		smtp.Shutdown(shutdownCtx)
+	}
+
+	if auth != nil {
+		auth.Shutdown(shutdownCtx)
+	}
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, smtpd.ErrServerClosed) {
+		return fmt.Errorf("failed to listen and serve: %w", err)
+	}
+
+	return nil
+}
+
+// upgrade hands the sockets over to a new process, reporting whether it
+// took over, in which case this one should shut down.
+func (a *App) upgrade(ctx context.Context, sockets *listener.Set) bool {
+	a.logger.Info("Starting new process to upgrade to")
+
+	ctx, cancel := context.WithTimeout(ctx, upgradeTimeout)
+	defer cancel()
+
+	if err := sockets.Upgrade(ctx); err != nil {
+		a.logger.Error("failed to upgrade", slog.Any("error", err))
+		return false
+	}
+
+	a.logger.Info("New process is ready, shutting down")
+
+	return true
+}
+
+// advertiseHTTP3 adds the Alt-Svc header announcing the HTTP/3 listener,
+// so that clients can switch to it for later requests.
+func advertiseHTTP3(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// This fails until the UDP listener is up, in which case there's
+		// nothing to advertise yet.
+		h3.SetQUICHeaders(w.Header())
+
+		next.ServeHTTP(w, r)
+	})
+}
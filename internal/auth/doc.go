@@ -0,0 +1,3 @@
+// Package auth handles account passwords and the signed session cookies
+// used to keep owners signed in.
+package auth
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+)
+
+const (
+	cookieName    = "guestbook_session"
+	sessionPrefix = "session"
+)
+
+// Sessions issues and reads the signed cookie identifying a signed in
+// account.
+type Sessions struct {
+	signer *access.Signer
+	ttl    time.Duration
+}
+
+func NewSessions(signer *access.Signer, ttl time.Duration) *Sessions {
+	return &Sessions{
+		signer: signer,
+		ttl:    ttl,
+	}
+}
+
+// SignIn sets the session cookie for the given account.
+func (s *Sessions) SignIn(w http.ResponseWriter, accountID uuid.UUID) {
+	expires := time.Now().Add(s.ttl)
+	value := fmt.Sprintf("%s|%s|%d", sessionPrefix, accountID, expires.Unix())
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    s.signer.Sign(value),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// SignOut clears the session cookie.
+func (s *Sessions) SignOut(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// AccountID returns the ID of the signed in account, if there is one.
+func (s *Sessions) AccountID(r *http.Request) (uuid.UUID, bool) {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	value, ok := s.signer.Verify(cookie.Value)
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	parts := strings.Split(value, "|")
+	if len(parts) != 3 || parts[0] != sessionPrefix {
+		return uuid.Nil, false
+	}
+
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+// SignedIn reports whether the request has a valid session.
+func (s *Sessions) SignedIn(r *http.Request) bool {
+	_, ok := s.AccountID(r)
+	return ok
+}
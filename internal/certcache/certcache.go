@@ -0,0 +1,66 @@
+package certcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// Store is the subset of queries the cache needs.
+type Store interface {
+	FindCachedCertificate(ctx context.Context, key string) ([]byte, error)
+	UpsertCachedCertificate(ctx context.Context, arg repository.UpsertCachedCertificateParams) error
+	DeleteCachedCertificate(ctx context.Context, key string) error
+}
+
+// Cache is an autocert.Cache backed by the certificate_cache table.
+type Cache struct {
+	store Store
+}
+
+var _ autocert.Cache = (*Cache)(nil)
+
+// New creates a Cache using the store.
+func New(store Store) *Cache {
+	return &Cache{store: store}
+}
+
+// Get returns the data cached under the key, or autocert.ErrCacheMiss if
+// there isn't any.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.store.FindCachedCertificate(ctx, key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, autocert.ErrCacheMiss
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get cached certificate: %w", err)
+	}
+
+	return data, nil
+}
+
+// Put caches the data under the key, replacing anything already there.
+func (c *Cache) Put(ctx context.Context, key string, data []byte) error {
+	err := c.store.UpsertCachedCertificate(ctx, repository.UpsertCachedCertificateParams{
+		Key:  key,
+		Data: data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache certificate: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the data cached under the key, if there is any.
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if err := c.store.DeleteCachedCertificate(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete cached certificate: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package certcache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/certcache"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+type memoryStore struct {
+	data map[string][]byte
+	err  error
+}
+
+func (s *memoryStore) FindCachedCertificate(_ context.Context, key string) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	data, ok := s.data[key]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	return data, nil
+}
+
+func (s *memoryStore) UpsertCachedCertificate(
+	_ context.Context, arg repository.UpsertCachedCertificateParams,
+) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.data[arg.Key] = arg.Data
+	return nil
+}
+
+func (s *memoryStore) DeleteCachedCertificate(_ context.Context, key string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	delete(s.data, key)
+	return nil
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	cache := certcache.New(&memoryStore{data: map[string][]byte{}})
+
+	_, err := cache.Get(ctx, "example.com")
+	assert.ErrorIs(t, err, autocert.ErrCacheMiss)
+
+	assert.NoError(t, cache.Put(ctx, "example.com", []byte("first")))
+	assert.NoError(t, cache.Put(ctx, "example.com", []byte("second")))
+
+	data, err := cache.Get(ctx, "example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	assert.NoError(t, cache.Delete(ctx, "example.com"))
+	assert.NoError(t, cache.Delete(ctx, "example.com"))
+
+	_, err = cache.Get(ctx, "example.com")
+	assert.ErrorIs(t, err, autocert.ErrCacheMiss)
+}
+
+func TestCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	cache := certcache.New(&memoryStore{err: errors.New("connection refused")})
+
+	_, err := cache.Get(ctx, "example.com")
+	assert.EqualError(t, err, "failed to get cached certificate: connection refused")
+	assert.NotErrorIs(t, err, autocert.ErrCacheMiss)
+
+	err = cache.Put(ctx, "example.com", []byte("data"))
+	assert.EqualError(t, err, "failed to cache certificate: connection refused")
+
+	err = cache.Delete(ctx, "example.com")
+	assert.EqualError(t, err, "failed to delete cached certificate: connection refused")
+}
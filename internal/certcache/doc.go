@@ -0,0 +1,4 @@
+// Package certcache stores the certificates and account keys used for
+// ACME in Postgres, so that every instance shares them rather than each
+// requesting its own certificates and running into the CA's rate limits.
+package certcache
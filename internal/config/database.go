@@ -2,87 +2,265 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/jackc/pgpassfile"
+	"github.com/jackc/pgservicefile"
 )
 
 // Database holds the configuration data for setting up a connection to a
 // database.
 type Database struct {
 	Username string
+	// Password may be empty when a client certificate is used instead.
 	Password string
-	Host     string
-	Port     uint16
-	DBName   string
-	SSLMode  string
+	// Host is the host to connect to, or a comma separated list of hosts
+	// that are tried in order until one accepts the connection.
+	Host string
+	// Port is the port used for every host, unless Ports lists one port
+	// for each host. It defaults to DefaultPort.
+	Port    uint16
+	Ports   []uint16
+	DBName  string
+	SSLMode string
+	// SSLRootCert is the path to the certificate authorities used to
+	// verify the server, and SSLCert and SSLKey are the paths to the
+	// client certificate and its key.
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+	// TargetSessionAttrs selects which of the hosts is acceptable, such as
+	// read-write to find the primary.
+	TargetSessionAttrs string
+	// ApplicationName is shown for the connections in pg_stat_activity,
+	// defaulting to DefaultApplicationName.
+	ApplicationName string
 }
 
-func loadPassword() (string, error) {
-	password, ok := os.LookupEnv("POSTGRES_PASSWORD")
-	if ok {
-		return password, nil
+// DefaultPort is the port connected to unless one is configured.
+const DefaultPort = 5432
+
+// DefaultApplicationName is the application_name used for connections
+// unless one is configured.
+const DefaultApplicationName = "guestbook"
+
+// setting describes where a connection setting can be loaded from.
+type setting struct {
+	// key is the name of the setting in a service file.
+	key string
+	// env is the libpq environment variable for the setting.
+	env string
+	// legacy is the environment variable used before libpq's variables
+	// were supported, which still works for existing deployments.
+	legacy string
+}
+
+var (
+	userSetting     = setting{key: "user", env: "PGUSER", legacy: "POSTGRES_USER"}
+	passwordSetting = setting{key: "password", env: "PGPASSWORD", legacy: "POSTGRES_PASSWORD"}
+	hostSetting     = setting{key: "host", env: "PGHOST", legacy: "POSTGRES_HOST"}
+	portSetting     = setting{key: "port", env: "PGPORT", legacy: "POSTGRES_PORT"}
+	dbnameSetting   = setting{key: "dbname", env: "PGDATABASE", legacy: "POSTGRES_DB"}
+	sslmodeSetting  = setting{key: "sslmode", env: "PGSSLMODE", legacy: "POSTGRES_SSLMODE"}
+
+	sslrootcertSetting = setting{key: "sslrootcert", env: "PGSSLROOTCERT"}
+	sslcertSetting     = setting{key: "sslcert", env: "PGSSLCERT"}
+	sslkeySetting      = setting{key: "sslkey", env: "PGSSLKEY"}
+	targetSetting      = setting{key: "target_session_attrs", env: "PGTARGETSESSIONATTRS"}
+	appNameSetting     = setting{key: "application_name", env: "PGAPPNAME"}
+)
+
+// settings looks up connection settings, following the same precedence
+// as libpq: the service named by PGSERVICE, then the PG* variables, then
+// the legacy POSTGRES_* variables.
+type settings struct {
+	service map[string]string
+}
+
+func loadSettings() (settings, error) {
+	name, ok := os.LookupEnv("PGSERVICE")
+	if !ok || name == "" {
+		return settings{}, nil
 	}
 
-	passwordFile, ok := os.LookupEnv("POSTGRES_PASSWORD_FILE")
+	path, ok := os.LookupEnv("PGSERVICEFILE")
 	if !ok {
-		return "", fmt.Errorf("no POSTGRES_PASSWORD or POSTGRES_PASSWORD_FILE env var set")
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return settings{}, fmt.Errorf("failed to find service file: %w", err)
+		}
+
+		path = filepath.Join(home, ".pg_service.conf")
+	}
+
+	file, err := pgservicefile.ReadServicefile(path)
+	if err != nil {
+		return settings{}, fmt.Errorf("failed to read service file: %w", err)
 	}
 
-	data, err := os.ReadFile(passwordFile)
+	service, err := file.GetService(name)
 	if err != nil {
-		return "", fmt.Errorf("failed to read from password file: %w", err)
+		return settings{}, fmt.Errorf("failed to find service %q: %w", name, err)
 	}
 
-	return strings.TrimSpace(string(data)), nil
+	return settings{service: service.Settings}, nil
 }
 
-// NewDatabase creates a database configuration based on the environment
-// variables required. If any env variables are not set or are invalid then
-// this method will throw an error.
-func NewDatabase() (*Database, error) {
-	username, ok := os.LookupEnv("POSTGRES_USER")
-	if !ok {
-		return nil, fmt.Errorf("no POSTGRES_USER env variable set")
+func (s settings) lookup(setting setting) (string, bool) {
+	if value, ok := s.service[setting.key]; ok {
+		return value, true
 	}
 
-	password, err := loadPassword()
-	if err != nil {
-		return nil, fmt.Errorf("loading password: %w", err)
+	if value, ok := os.LookupEnv(setting.env); ok {
+		return value, true
 	}
 
-	host, ok := os.LookupEnv("POSTGRES_HOST")
+	if setting.legacy == "" {
+		return "", false
+	}
+
+	return os.LookupEnv(setting.legacy)
+}
+
+func (s settings) require(setting setting) (string, error) {
+	value, ok := s.lookup(setting)
 	if !ok {
-		return nil, fmt.Errorf("no POSTGRES_HOST env variable set")
+		return "", fmt.Errorf("no %s env variable set", setting.env)
+	}
+
+	return value, nil
+}
+
+// password looks up the password like any other setting, then in the
+// legacy POSTGRES_PASSWORD_FILE secret, and finally in the password file
+// named by PGPASSFILE, defaulting to ~/.pgpass. It's empty if there's no
+// password anywhere.
+func (s settings) password(cfg *Database) (string, error) {
+	if password, ok := s.lookup(passwordSetting); ok {
+		return password, nil
+	}
+
+	if _, ok := os.LookupEnv("POSTGRES_PASSWORD_FILE"); ok {
+		return Secret("POSTGRES_PASSWORD")
 	}
 
-	portStr, ok := os.LookupEnv("POSTGRES_PORT")
+	path, ok := s.service["passfile"]
 	if !ok {
-		return nil, fmt.Errorf("no POSTGRES_PORT env variable set")
+		path, ok = os.LookupEnv("PGPASSFILE")
+	}
+	if !ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+
+		path = filepath.Join(home, ".pgpass")
+	}
+
+	file, err := pgpassfile.ReadPassfile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+
+	if file != nil {
+		for i, host := range cfg.hosts() {
+			// libpq matches sockets against localhost entries
+			if isSocketDir(host) {
+				host = "localhost"
+			}
+
+			password := file.FindPassword(
+				host, strconv.Itoa(int(cfg.port(i))), cfg.DBName, cfg.Username,
+			)
+			if password != "" {
+				return password, nil
+			}
+		}
 	}
 
-	port, err := strconv.Atoi(portStr)
+	return "", nil
+}
+
+// NewDatabase creates a database configuration from the same sources
+// libpq uses, in order of precedence:
+//
+//  1. the service named by PGSERVICE, read from PGSERVICEFILE or
+//     ~/.pg_service.conf
+//  2. the PGUSER, PGPASSWORD, PGHOST, PGPORT, PGDATABASE, PGSSLMODE,
+//     PGSSLROOTCERT, PGSSLCERT, PGSSLKEY, PGTARGETSESSIONATTRS and
+//     PGAPPNAME env variables
+//  3. the older POSTGRES_USER, POSTGRES_PASSWORD(_FILE), POSTGRES_HOST,
+//     POSTGRES_PORT, POSTGRES_DB and POSTGRES_SSLMODE env variables
+//  4. for the password only, the file named by PGPASSFILE, or ~/.pgpass
+//
+// As with libpq, PGHOST may list several hosts separated by commas, and
+// PGPORT either a single port for all of them or one port for each.
+//
+// If any required setting is missing or invalid then an error is returned.
+// The password is required unless a client certificate is configured. The
+// remaining settings are optional, and default to libpq's defaults when
+// unset, such as port 5432.
+func NewDatabase() (*Database, error) {
+	s, err := loadSettings()
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert port to int: %w", err)
+		return nil, err
 	}
 
-	dbname, ok := os.LookupEnv("POSTGRES_DB")
-	if !ok {
-		return nil, fmt.Errorf("no POSTGRES_DATABASE env variable set")
+	username, err := s.require(userSetting)
+	if err != nil {
+		return nil, err
 	}
 
-	sslmode, ok := os.LookupEnv("POSTGRES_SSLMODE")
-	if !ok {
-		return nil, fmt.Errorf("no SSLMode env variable set")
+	host, err := s.require(hostSetting)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []uint16{DefaultPort}
+	if portStr, ok := s.lookup(portSetting); ok {
+		ports, err = parsePorts(portStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dbname, err := s.require(dbnameSetting)
+	if err != nil {
+		return nil, err
 	}
 
 	config := &Database{
 		Username: username,
-		Password: password,
 		Host:     host,
-		Port:     uint16(port),
+		Port:     ports[0],
 		DBName:   dbname,
-		SSLMode:  sslmode,
+	}
+
+	if len(ports) > 1 {
+		config.Ports = ports
+	}
+
+	config.SSLMode, _ = s.lookup(sslmodeSetting)
+	config.SSLRootCert, _ = s.lookup(sslrootcertSetting)
+	config.SSLCert, _ = s.lookup(sslcertSetting)
+	config.SSLKey, _ = s.lookup(sslkeySetting)
+	config.TargetSessionAttrs, _ = s.lookup(targetSetting)
+	config.ApplicationName, _ = s.lookup(appNameSetting)
+
+	config.Password, err = s.password(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// A client certificate can authenticate on its own
+	if config.Password == "" && config.SSLCert == "" {
+		return nil, fmt.Errorf("no %s env variable set", passwordSetting.env)
 	}
 
 	err = config.Validate()
@@ -93,21 +271,138 @@ func NewDatabase() (*Database, error) {
 	return config, nil
 }
 
-// URL generates a database connection url from the configuration fields.
+// DatabaseURL returns the connection url for the database. DATABASE_URL
+// is used as is when it's set, and takes precedence over everything read
+// by NewDatabase.
+func DatabaseURL() (string, error) {
+	if url, ok := os.LookupEnv("DATABASE_URL"); ok && url != "" {
+		return url, nil
+	}
+
+	cfg, err := NewDatabase()
+	if err != nil {
+		return "", fmt.Errorf("loading database config: %w", err)
+	}
+
+	return cfg.URL(), nil
+}
+
+// parsePorts parses a single port, or a comma separated list of ports.
+func parsePorts(value string) ([]uint16, error) {
+	var ports []uint16
+
+	for _, part := range strings.Split(value, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert port to int: %w", err)
+		}
+
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d, must be between 1 and 65535", port)
+		}
+
+		ports = append(ports, uint16(port))
+	}
+
+	return ports, nil
+}
+
+// hosts returns each of the hosts to connect to.
+func (c *Database) hosts() []string {
+	hosts := strings.Split(c.Host, ",")
+	for i, host := range hosts {
+		hosts[i] = strings.TrimSpace(host)
+	}
+
+	return hosts
+}
+
+// port returns the port for the i'th host.
+func (c *Database) port(i int) uint16 {
+	if i < len(c.Ports) {
+		return c.Ports[i]
+	}
+
+	if c.Port == 0 {
+		return DefaultPort
+	}
+
+	return c.Port
+}
+
+// URL generates a database connection url from the configuration fields,
+// escaping each of them. The url is understood by both pgx and libpq.
 func (c *Database) URL() string {
-	return fmt.Sprintf(
-		"postgresql://%s:%s@%s:%d/%s?sslmode=%s",
-		c.Username,
-		c.Password,
-		c.Host,
-		c.Port,
-		c.DBName,
-		c.SSLMode,
-	)
+	hosts := c.hosts()
+	ports := make([]string, len(hosts))
+	for i := range hosts {
+		ports[i] = strconv.Itoa(int(c.port(i)))
+	}
+
+	query := url.Values{}
+
+	// A host that's an absolute path is the directory of a Unix socket,
+	// which only the host parameter can hold. When there is one, every host
+	// goes there, so they're still tried in order.
+	var authority string
+	if slices.ContainsFunc(hosts, isSocketDir) {
+		query.Set("host", strings.Join(hosts, ","))
+		query.Set("port", strings.Join(ports, ","))
+	} else {
+		for i, host := range hosts {
+			hosts[i] = net.JoinHostPort(host, ports[i])
+		}
+		authority = strings.Join(hosts, ",")
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	set("sslmode", c.SSLMode)
+	set("sslrootcert", c.SSLRootCert)
+	set("sslcert", c.SSLCert)
+	set("sslkey", c.SSLKey)
+	set("target_session_attrs", c.TargetSessionAttrs)
+
+	if c.ApplicationName != "" {
+		query.Set("application_name", c.ApplicationName)
+	} else {
+		query.Set("application_name", DefaultApplicationName)
+	}
+
+	u := url.URL{
+		Scheme:   "postgresql",
+		User:     c.userinfo(),
+		Host:     authority,
+		Path:     "/" + c.DBName,
+		RawPath:  "/" + url.PathEscape(c.DBName),
+		RawQuery: query.Encode(),
+	}
+
+	return u.String()
+}
+
+// isSocketDir reports whether the host is the directory of a Unix socket,
+// as libpq decides.
+func isSocketDir(host string) bool {
+	return strings.HasPrefix(host, "/")
+}
+
+// userinfo returns the url's user, with a password only if there is one.
+func (c *Database) userinfo() *url.Userinfo {
+	if c.Password == "" {
+		return url.User(c.Username)
+	}
+
+	return url.UserPassword(c.Username, c.Password)
 }
 
 // Validate checks a Database configuration to ensure it's values are
-// valid for connecting to a database.
+// valid for connecting to a database. A zero Port means DefaultPort, and
+// the password may be empty when a client certificate is set.
 func (c *Database) Validate() error {
 	if c.DBName == "" {
 		return fmt.Errorf("invalid database name")
@@ -121,17 +416,47 @@ func (c *Database) Validate() error {
 		return fmt.Errorf("invalid username")
 	}
 
-	if c.Password == "" {
+	if c.Password == "" && c.SSLCert == "" {
 		return fmt.Errorf("invalid password")
 	}
 
-	if c.Port == 0 {
+	if slices.Contains(c.Ports, 0) {
 		return fmt.Errorf("invalid port")
 	}
 
-	if c.SSLMode == "" {
+	if len(c.Ports) > 0 && len(c.Ports) != len(c.hosts()) {
+		return fmt.Errorf("number of ports doesn't match the number of hosts")
+	}
+
+	if slices.Contains(c.hosts(), "") {
+		return fmt.Errorf("invalid host")
+	}
+
+	switch c.SSLMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
 		return fmt.Errorf("invalid sslmode")
 	}
 
+	switch c.TargetSessionAttrs {
+	case "", "any", "read-write", "read-only", "primary", "standby", "prefer-standby":
+	default:
+		return fmt.Errorf("invalid target_session_attrs")
+	}
+
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		return fmt.Errorf("sslcert and sslkey must be set together")
+	}
+
+	for _, file := range []string{c.SSLRootCert, c.SSLCert, c.SSLKey} {
+		if file == "" {
+			continue
+		}
+
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("invalid certificate file: %w", err)
+		}
+	}
+
 	return nil
 }
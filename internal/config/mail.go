@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Mail holds the configuration for receiving messages by email.
+type Mail struct {
+	// Addr is the address the SMTP server listens on. Mail is only
+	// received when it's set.
+	Addr string
+	// Domain is the domain guestbooks' addresses are at, which the MX
+	// record points to the server.
+	Domain string
+}
+
+// NewMail creates a Mail configuration from the environment.
+//
+// SMTP_ADDR enables receiving mail, listening on the address, for
+// addresses at SMTP_DOMAIN.
+func NewMail() (*Mail, error) {
+	cfg := &Mail{
+		Addr:   os.Getenv("SMTP_ADDR"),
+		Domain: strings.ToLower(strings.TrimSpace(os.Getenv("SMTP_DOMAIN"))),
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate mail config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Enabled reports whether mail is received.
+func (c *Mail) Enabled() bool {
+	return c.Addr != ""
+}
+
+// Validate checks a Mail configuration to ensure its values are valid for
+// receiving mail.
+func (c *Mail) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return fmt.Errorf("invalid smtp address: %w", err)
+	}
+
+	if c.Domain == "" {
+		return fmt.Errorf("smtp domain is required")
+	}
+
+	if strings.ContainsAny(c.Domain, "@ /:") {
+		return fmt.Errorf("invalid smtp domain")
+	}
+
+	return nil
+}
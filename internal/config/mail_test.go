@@ -0,0 +1,69 @@
+package config_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/config"
+)
+
+func TestNewMail(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Env         map[string]string
+		ExpectedCfg *config.Mail
+		ExpectedErr string
+	}{
+		{
+			Description: "disabled",
+			ExpectedCfg: &config.Mail{},
+		},
+		{
+			Description: "enabled",
+			Env: map[string]string{
+				"SMTP_ADDR":   ":2525",
+				"SMTP_DOMAIN": " Guestbook.Example.com ",
+			},
+			ExpectedCfg: &config.Mail{Addr: ":2525", Domain: "guestbook.example.com"},
+		},
+		{
+			Description: "missing domain",
+			Env:         map[string]string{"SMTP_ADDR": ":25"},
+			ExpectedErr: "failed to validate mail config: smtp domain is required",
+		},
+		{
+			Description: "invalid domain",
+			Env:         map[string]string{"SMTP_ADDR": ":25", "SMTP_DOMAIN": "guestbook@example.com"},
+			ExpectedErr: "failed to validate mail config: invalid smtp domain",
+		},
+		{
+			Description: "address without port",
+			Env:         map[string]string{"SMTP_ADDR": "localhost", "SMTP_DOMAIN": "example.com"},
+			ExpectedErr: "failed to validate mail config: invalid smtp address: address localhost: missing port in address",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			for _, env := range []string{"SMTP_ADDR", "SMTP_DOMAIN"} {
+				t.Setenv(env, "")
+				os.Unsetenv(env)
+			}
+
+			for key, value := range tc.Env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := config.NewMail()
+			if tc.ExpectedErr != "" {
+				assert.EqualError(t, err, tc.ExpectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedCfg, cfg)
+		})
+	}
+}
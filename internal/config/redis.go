@@ -0,0 +1,320 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisAddr = "localhost:6379"
+	defaultRedisPort = "6379"
+)
+
+// Redis holds the configuration for connecting to Redis, or a compatible
+// server such as Dragonfly, either directly, through Sentinel or as a
+// cluster.
+type Redis struct {
+	// Addrs are the addresses of the server, of the sentinels when
+	// MasterName is set, or of the cluster's seed nodes.
+	Addrs    []string
+	Username string
+	Password string
+	DB       int
+	// MasterName is the name of the master monitored by Sentinel.
+	MasterName       string
+	SentinelUsername string
+	SentinelPassword string
+	Cluster          bool
+	// TLS enables TLS, optionally verifying the server with the CA file,
+	// and authenticating with the client certificate and key files.
+	TLS           bool
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSServerName string
+}
+
+// NewRedis creates a Redis configuration from the environment.
+//
+// The server is found from REDIS_URL, in the form
+// redis://[user[:password]@]host[:port][,host[:port]...][/db], with
+// rediss:// enabling TLS. Without it, REDIS_ADDR lists the addresses,
+// defaulting to localhost:6379.
+//
+// REDIS_USERNAME, REDIS_PASSWORD and REDIS_DB override the url, and the
+// password can be read from REDIS_PASSWORD_FILE. REDIS_TLS, REDIS_TLS_CA_FILE,
+// REDIS_TLS_CERT_FILE, REDIS_TLS_KEY_FILE and REDIS_TLS_SERVER_NAME
+// configure TLS. REDIS_SENTINEL_MASTER selects Sentinel, with
+// REDIS_SENTINEL_USERNAME and REDIS_SENTINEL_PASSWORD, and REDIS_CLUSTER
+// selects cluster mode.
+func NewRedis() (*Redis, error) {
+	cfg := &Redis{}
+
+	if redisURL, ok := os.LookupEnv("REDIS_URL"); ok && redisURL != "" {
+		if err := cfg.parseURL(redisURL); err != nil {
+			return nil, fmt.Errorf("failed to parse REDIS_URL: %w", err)
+		}
+	} else {
+		addrs, ok := os.LookupEnv("REDIS_ADDR")
+		if !ok || addrs == "" {
+			addrs = defaultRedisAddr
+		}
+
+		cfg.Addrs = splitAddrs(addrs)
+	}
+
+	if username, ok := os.LookupEnv("REDIS_USERNAME"); ok {
+		cfg.Username = username
+	}
+
+	password, err := OptionalSecret("REDIS_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+
+	if password != "" {
+		cfg.Password = password
+	}
+
+	if db, ok := os.LookupEnv("REDIS_DB"); ok {
+		cfg.DB, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert REDIS_DB to int: %w", err)
+		}
+	}
+
+	cfg.MasterName = os.Getenv("REDIS_SENTINEL_MASTER")
+	cfg.SentinelUsername = os.Getenv("REDIS_SENTINEL_USERNAME")
+
+	cfg.SentinelPassword, err = OptionalSecret("REDIS_SENTINEL_PASSWORD")
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Cluster, err = envBool("REDIS_CLUSTER"); err != nil {
+		return nil, err
+	}
+
+	tlsEnabled, err := envBool("REDIS_TLS")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.TLSCAFile = os.Getenv("REDIS_TLS_CA_FILE")
+	cfg.TLSCertFile = os.Getenv("REDIS_TLS_CERT_FILE")
+	cfg.TLSKeyFile = os.Getenv("REDIS_TLS_KEY_FILE")
+	cfg.TLSServerName = os.Getenv("REDIS_TLS_SERVER_NAME")
+
+	// Any TLS setting implies TLS, as does a rediss:// url.
+	cfg.TLS = cfg.TLS || tlsEnabled ||
+		cfg.TLSCAFile != "" || cfg.TLSCertFile != "" || cfg.TLSServerName != ""
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate redis config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func (c *Redis) parseURL(redisURL string) error {
+	// url.Parse only accepts a single host, so the list of hosts is split
+	// out before parsing the rest of the url.
+	scheme, rest, ok := strings.Cut(redisURL, "://")
+	if !ok {
+		return fmt.Errorf("invalid url")
+	}
+
+	authority, path := rest, ""
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		authority, path = rest[:i], rest[i:]
+	}
+
+	userinfo, hosts := "", authority
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		userinfo, hosts = authority[:i+1], authority[i+1:]
+	}
+
+	u, err := url.Parse(scheme + "://" + userinfo + "redis" + path)
+	if urlErr := (*url.Error)(nil); errors.As(err, &urlErr) {
+		// The url.Error includes the url, and so the password.
+		return urlErr.Err
+	} else if err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.TLS = true
+	default:
+		return fmt.Errorf("invalid scheme %q", u.Scheme)
+	}
+
+	if u.User != nil {
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+
+	c.Addrs = splitAddrs(hosts)
+
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		c.DB, err = strconv.Atoi(db)
+		if err != nil {
+			return fmt.Errorf("invalid database number %q", db)
+		}
+	}
+
+	return nil
+}
+
+// splitAddrs splits a comma separated list of addresses, adding the default
+// port to any without one.
+func splitAddrs(value string) []string {
+	var addrs []string
+
+	for _, addr := range strings.Split(value, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(strings.Trim(addr, "[]"), defaultRedisPort)
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
+
+func envBool(name string) (bool, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	return b, nil
+}
+
+// Mode describes how the client connects, for diagnostics.
+func (c *Redis) Mode() string {
+	switch {
+	case c.MasterName != "":
+		return "sentinel"
+	case c.Cluster:
+		return "cluster"
+	default:
+		return "standalone"
+	}
+}
+
+// Validate checks a Redis configuration to ensure its values are valid
+// for connecting.
+func (c *Redis) Validate() error {
+	if len(c.Addrs) == 0 {
+		return fmt.Errorf("no redis addresses")
+	}
+
+	if c.MasterName != "" && c.Cluster {
+		return fmt.Errorf("sentinel and cluster mode can't be used together")
+	}
+
+	if len(c.Addrs) > 1 && c.Mode() == "standalone" {
+		return fmt.Errorf("multiple addresses need sentinel or cluster mode")
+	}
+
+	if c.DB < 0 {
+		return fmt.Errorf("invalid database number")
+	}
+
+	if c.Cluster && c.DB != 0 {
+		return fmt.Errorf("cluster mode only supports database 0")
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls cert and key files must be set together")
+	}
+
+	return nil
+}
+
+// TLSConfig loads the TLS configuration, returning nil when TLS is off.
+// Without a server name, each node is verified against its own host.
+func (c *Redis) TLSConfig() (*tls.Config, error) {
+	if !c.TLS {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.TLSServerName,
+	}
+
+	if c.TLSCAFile != "" {
+		data, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca file: %w", err)
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in tls ca file")
+		}
+	}
+
+	if c.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// Client creates a client for the configured mode. The client connects
+// lazily, on its first command.
+func (c *Redis) Client() (redis.UniversalClient, error) {
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            c.Addrs,
+		ClientName:       DefaultApplicationName,
+		DB:               c.DB,
+		Username:         c.Username,
+		Password:         c.Password,
+		SentinelUsername: c.SentinelUsername,
+		SentinelPassword: c.SentinelPassword,
+		MasterName:       c.MasterName,
+		TLSConfig:        tlsConfig,
+	}
+
+	switch c.Mode() {
+	case "sentinel":
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case "cluster":
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return redis.NewClient(opts.Simple()), nil
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Secret loads a secret value from the named env variable, falling back to
+// the file referenced by the same name suffixed with _FILE. This follows the
+// same convention as POSTGRES_PASSWORD so that docker secrets can be used.
+func Secret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if ok {
+		return value, nil
+	}
+
+	file, ok := os.LookupEnv(name + "_FILE")
+	if !ok {
+		return "", fmt.Errorf("no %s or %s_FILE env var set", name, name)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from %s file: %w", name, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// OptionalSecret loads a secret in the same way as Secret, but returns an
+// empty value rather than an error when neither env variable is set.
+func OptionalSecret(name string) (string, error) {
+	_, ok := os.LookupEnv(name)
+	_, fileOK := os.LookupEnv(name + "_FILE")
+	if !ok && !fileOK {
+		return "", nil
+	}
+
+	return Secret(name)
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultServerAddr   = ":8080"
+	defaultACMEHTTPAddr = ":80"
+)
+
+// Server holds the configuration for the guestbook's own listeners.
+type Server struct {
+	// Addr is the address to listen on, for TCP and, with HTTP/3, UDP.
+	Addr string
+	// TLSCertFile and TLSKeyFile enable TLS when set, which is needed for
+	// HTTP/2 and HTTP/3.
+	TLSCertFile string
+	TLSKeyFile  string
+	// ACMEDomains enables TLS with certificates obtained through ACME for
+	// the domains, as an alternative to certificate files.
+	ACMEDomains []string
+	// ACMEEmail is the contact address registered with the CA.
+	ACMEEmail string
+	// ACMEDirectoryURL is the CA's directory, defaulting to Let's Encrypt.
+	ACMEDirectoryURL string
+	// ACMEHTTPAddr is the address answering HTTP-01 challenges, which
+	// redirects any other request to HTTPS.
+	ACMEHTTPAddr string
+	// HTTP3 enables listening for HTTP/3 over QUIC alongside TCP.
+	HTTP3 bool
+	// HTTP3Port is the UDP port advertised to clients, when it differs
+	// from the port listened on, such as behind a port mapping.
+	HTTP3Port int
+	// TrustedProxies are the networks of the reverse proxies in front of
+	// the server, whose X-Forwarded-For headers are believed.
+	TrustedProxies []netip.Prefix
+	// ForwardAuthAddr is the address the forward auth endpoint listens on,
+	// which only the proxy should be able to reach. It isn't served when
+	// empty.
+	ForwardAuthAddr string
+}
+
+// NewServer creates a Server configuration from the environment.
+//
+// LISTEN_ADDR sets the address, defaulting to :8080. TLS_CERT_FILE and
+// TLS_KEY_FILE enable TLS, and with it, HTTP3 enables HTTP/3 with
+// HTTP3_PORT overriding the port advertised to clients.
+//
+// ACME_DOMAINS, a comma separated list, enables TLS through ACME instead,
+// with ACME_EMAIL as the contact address, ACME_DIRECTORY_URL overriding
+// the CA and ACME_HTTP_ADDR the address for HTTP-01, defaulting to :80.
+//
+// TRUSTED_PROXIES lists the addresses and networks of reverse proxies,
+// separated by commas. Without it, clients are taken to connect directly.
+// FORWARD_AUTH_ADDR enables the forward auth endpoint on its own address.
+func NewServer() (*Server, error) {
+	cfg := &Server{
+		Addr:             os.Getenv("LISTEN_ADDR"),
+		TLSCertFile:      os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:       os.Getenv("TLS_KEY_FILE"),
+		ACMEEmail:        os.Getenv("ACME_EMAIL"),
+		ACMEDirectoryURL: os.Getenv("ACME_DIRECTORY_URL"),
+		ACMEHTTPAddr:     os.Getenv("ACME_HTTP_ADDR"),
+		ForwardAuthAddr:  os.Getenv("FORWARD_AUTH_ADDR"),
+	}
+
+	if cfg.Addr == "" {
+		cfg.Addr = defaultServerAddr
+	}
+
+	for _, domain := range strings.Split(os.Getenv("ACME_DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			cfg.ACMEDomains = append(cfg.ACMEDomains, domain)
+		}
+	}
+
+	if cfg.ACME() && cfg.ACMEHTTPAddr == "" {
+		cfg.ACMEHTTPAddr = defaultACMEHTTPAddr
+	}
+
+	var err error
+	if cfg.HTTP3, err = envBool("HTTP3"); err != nil {
+		return nil, err
+	}
+
+	if cfg.TrustedProxies, err = parsePrefixes(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		return nil, fmt.Errorf("failed to parse TRUSTED_PROXIES: %w", err)
+	}
+
+	if port, ok := os.LookupEnv("HTTP3_PORT"); ok && port != "" {
+		cfg.HTTP3Port, err = strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert HTTP3_PORT to int: %w", err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate server config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// TLS reports whether the server is configured to serve over TLS.
+func (c *Server) TLS() bool {
+	return c.TLSCertFile != "" || c.ACME()
+}
+
+// ACME reports whether certificates are obtained through ACME.
+func (c *Server) ACME() bool {
+	return len(c.ACMEDomains) > 0
+}
+
+// Validate checks a Server configuration to ensure its values are valid
+// for listening.
+func (c *Server) Validate() error {
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return fmt.Errorf("invalid listen address: %w", err)
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls cert and key files must be set together")
+	}
+
+	if c.TLSCertFile != "" && c.ACME() {
+		return fmt.Errorf("tls cert files and acme can't be used together")
+	}
+
+	if c.ACME() {
+		if _, _, err := net.SplitHostPort(c.ACMEHTTPAddr); err != nil {
+			return fmt.Errorf("invalid acme http address: %w", err)
+		}
+	}
+
+	if c.ACMEDirectoryURL != "" {
+		u, err := url.Parse(c.ACMEDirectoryURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid acme directory url")
+		}
+	}
+
+	if c.ForwardAuthAddr != "" {
+		if _, _, err := net.SplitHostPort(c.ForwardAuthAddr); err != nil {
+			return fmt.Errorf("invalid forward auth address: %w", err)
+		}
+
+		if c.ForwardAuthAddr == c.Addr {
+			return fmt.Errorf("forward auth must listen apart from the server")
+		}
+	}
+
+	if c.HTTP3 && !c.TLS() {
+		return fmt.Errorf("http3 needs tls")
+	}
+
+	if c.HTTP3Port < 0 || c.HTTP3Port > 65535 {
+		return fmt.Errorf("invalid http3 port")
+	}
+
+	return nil
+}
+
+// TLSConfig loads the certificate files, returning nil when they aren't
+// set, as when TLS is off or certificates come from ACME.
+func (c *Server) TLSConfig() (*tls.Config, error) {
+	if c.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}
+
+// parsePrefixes parses a comma separated list of networks in CIDR
+// notation, where a lone address is a network of its own.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", entry)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
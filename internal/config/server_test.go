@@ -0,0 +1,172 @@
+package config_test
+
+import (
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/config"
+)
+
+func unsetServerEnv(t *testing.T) {
+	t.Helper()
+
+	for _, env := range []string{
+		"LISTEN_ADDR", "TLS_CERT_FILE", "TLS_KEY_FILE", "HTTP3", "HTTP3_PORT",
+		"ACME_DOMAINS", "ACME_EMAIL", "ACME_DIRECTORY_URL", "ACME_HTTP_ADDR",
+		"TRUSTED_PROXIES", "FORWARD_AUTH_ADDR",
+	} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Env         map[string]string
+		ExpectedCfg *config.Server
+		ExpectedErr string
+	}{
+		{
+			Description: "defaults",
+			ExpectedCfg: &config.Server{Addr: ":8080"},
+		},
+		{
+			Description: "tls with http3",
+			Env: map[string]string{
+				"LISTEN_ADDR":   ":8443",
+				"TLS_CERT_FILE": "cert.pem",
+				"TLS_KEY_FILE":  "key.pem",
+				"HTTP3":         "true",
+				"HTTP3_PORT":    "443",
+			},
+			ExpectedCfg: &config.Server{
+				Addr:        ":8443",
+				TLSCertFile: "cert.pem",
+				TLSKeyFile:  "key.pem",
+				HTTP3:       true,
+				HTTP3Port:   443,
+			},
+		},
+		{
+			Description: "acme",
+			Env: map[string]string{
+				"LISTEN_ADDR":        ":443",
+				"ACME_DOMAINS":       "guestbook.example.com, www.guestbook.example.com,",
+				"ACME_EMAIL":         "admin@example.com",
+				"ACME_DIRECTORY_URL": "https://localhost:14000/dir",
+				"HTTP3":              "true",
+			},
+			ExpectedCfg: &config.Server{
+				Addr:             ":443",
+				ACMEDomains:      []string{"guestbook.example.com", "www.guestbook.example.com"},
+				ACMEEmail:        "admin@example.com",
+				ACMEDirectoryURL: "https://localhost:14000/dir",
+				ACMEHTTPAddr:     ":80",
+				HTTP3:            true,
+			},
+		},
+		{
+			Description: "trusted proxies",
+			Env: map[string]string{
+				"TRUSTED_PROXIES": "172.16.0.0/12, 192.0.2.1,fd00::1/8",
+			},
+			ExpectedCfg: &config.Server{
+				Addr: ":8080",
+				TrustedProxies: []netip.Prefix{
+					netip.MustParsePrefix("172.16.0.0/12"),
+					netip.MustParsePrefix("192.0.2.1/32"),
+					netip.MustParsePrefix("fd00::/8"),
+				},
+			},
+		},
+		{
+			Description: "invalid trusted proxy",
+			Env:         map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, traefik"},
+			ExpectedErr: `failed to parse TRUSTED_PROXIES: invalid address "traefik"`,
+		},
+		{
+			Description: "forward auth",
+			Env:         map[string]string{"FORWARD_AUTH_ADDR": ":8081"},
+			ExpectedCfg: &config.Server{Addr: ":8080", ForwardAuthAddr: ":8081"},
+		},
+		{
+			Description: "forward auth on the server's address",
+			Env:         map[string]string{"FORWARD_AUTH_ADDR": ":8080"},
+			ExpectedErr: "failed to validate server config: forward auth must listen apart from the server",
+		},
+		{
+			Description: "acme with certificate files",
+			Env: map[string]string{
+				"TLS_CERT_FILE": "cert.pem",
+				"TLS_KEY_FILE":  "key.pem",
+				"ACME_DOMAINS":  "guestbook.example.com",
+			},
+			ExpectedErr: "failed to validate server config: tls cert files and acme can't be used together",
+		},
+		{
+			Description: "invalid acme directory",
+			Env: map[string]string{
+				"ACME_DOMAINS":       "guestbook.example.com",
+				"ACME_DIRECTORY_URL": "localhost:14000/dir",
+			},
+			ExpectedErr: "failed to validate server config: invalid acme directory url",
+		},
+		{
+			Description: "http3 without tls",
+			Env:         map[string]string{"HTTP3": "1"},
+			ExpectedErr: "failed to validate server config: http3 needs tls",
+		},
+		{
+			Description: "cert without key",
+			Env:         map[string]string{"TLS_CERT_FILE": "cert.pem"},
+			ExpectedErr: "failed to validate server config: tls cert and key files must be set together",
+		},
+		{
+			Description: "address without port",
+			Env:         map[string]string{"LISTEN_ADDR": "localhost"},
+			ExpectedErr: "failed to validate server config: invalid listen address: address localhost: missing port in address",
+		},
+		{
+			Description: "invalid http3 flag",
+			Env:         map[string]string{"HTTP3": "sometimes"},
+			ExpectedErr: `failed to parse HTTP3: strconv.ParseBool: parsing "sometimes": invalid syntax`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			unsetServerEnv(t)
+
+			for key, value := range tc.Env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := config.NewServer()
+			if tc.ExpectedErr != "" {
+				assert.EqualError(t, err, tc.ExpectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedCfg, cfg)
+		})
+	}
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	cert, key := writeCertificate(t)
+
+	cfg := config.Server{Addr: ":8443", TLSCertFile: cert, TLSKeyFile: key}
+
+	tlsConfig, err := cfg.TLSConfig()
+	assert.NoError(t, err)
+	assert.Len(t, tlsConfig.Certificates, 1)
+
+	tlsConfig, err = (&config.Server{Addr: ":8080"}).TLSConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+}
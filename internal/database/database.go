@@ -6,92 +6,102 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
-	"os"
 
 	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/dreamsofcode-io/guestbook/internal/config"
 )
 
-var (
-	ErrMissingMigrationsPath = errors.New("MIGRATIONS_PATH env missing")
-	ErrMissingDatabaseURL    = errors.New("DATABASE_URL env missing")
-)
-
-func loadConfigFromURL() (*pgxpool.Config, error) {
-	dbURL, ok := os.LookupEnv("DATABASE_URL")
-	if !ok {
-		return nil, fmt.Errorf("Must set DATABASE_URL env var")
-	}
-
-	config, err := pgxpool.ParseConfig(dbURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
-	}
-
-	return config, nil
-}
+var ErrMissingMigrationsPath = errors.New("MIGRATIONS_PATH env missing")
 
+// loadConfig parses the connection url for the pool from
+// config.DatabaseURL. The migrations run over the pool, so they always
+// connect to the same database.
 func loadConfig() (*pgxpool.Config, error) {
-	cfg, err := config.NewDatabase()
+	url, err := config.DatabaseURL()
 	if err != nil {
-		return loadConfigFromURL()
+		return nil, err
 	}
 
-	return pgxpool.ParseConfig(fmt.Sprintf(
-		"user=%s password=%s host=%s port=%d dbname=%s sslmode=%s",
-		cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.DBName, cfg.SSLMode,
-	))
-}
-
-func dbURL() (string, error) {
-	cfg, err := config.NewDatabase()
+	cfg, err := pgxpool.ParseConfig(url)
 	if err != nil {
-		dbURL, ok := os.LookupEnv("DATABASE_URL")
-		if !ok {
-			return "", fmt.Errorf("Must set DATABASE_URL env var")
-		}
-
-		return dbURL, nil
+		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	return cfg.URL(), nil
+	return cfg, nil
 }
 
+// DefaultMigrationsTable is the table the guestbook's own server records
+// the applied migrations in.
+const DefaultMigrationsTable = "schema_migrations"
+
 func Connect(ctx context.Context, logger *slog.Logger, migrations fs.FS) (*pgxpool.Pool, error) {
-	config, err := loadConfig()
+	cfg, err := loadConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	conn, err := pgxpool.NewWithConfig(ctx, config)
+	conn, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to database: %w", err)
 	}
 
 	logger.Debug("Running migrations")
 
-	url, err := dbURL()
-	if err != nil {
+	if err := Migrate(ctx, conn, migrations, DefaultMigrationsTable); err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	source, err := iofs.New(migrations, "migrations")
+	return conn, nil
+}
+
+// Migrate applies the migrations at the root of the filesystem that
+// haven't been yet, recording them in the table. They run over the pool,
+// so they connect with exactly its settings. If the context is cancelled,
+// it stops once the migration being applied is done.
+func Migrate(ctx context.Context, pool *pgxpool.Pool, migrations fs.FS, table string) error {
+	db := stdlib.OpenDBFromPool(pool)
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to connect for migrations: %w", err)
+	}
+
+	source, err := iofs.New(migrations, ".")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create source: %w", err)
+		return fmt.Errorf("failed to create source: %w", err)
 	}
 
-	migrator, err := migrate.NewWithSourceInstance("iofs", source, url)
+	target, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{MigrationsTable: table})
 	if err != nil {
-		return nil, fmt.Errorf("migrate new: %s", err)
+		return fmt.Errorf("failed to create driver: %w", err)
 	}
 
+	migrator, err := migrate.NewWithInstance("iofs", source, "pgx5", target)
+	if err != nil {
+		return fmt.Errorf("migrate new: %w", err)
+	}
+	defer migrator.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			migrator.GracefulStop <- true
+		case <-done:
+		}
+	}()
+
 	if err := migrator.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	return conn, nil
+	return ctx.Err()
 }
@@ -0,0 +1,3 @@
+// Package field defines the custom form fields an owner can add to their
+// guestbook, and validates the values visitors submit for them.
+package field
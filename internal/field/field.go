@@ -0,0 +1,249 @@
+package field
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxFields is the most custom fields a guestbook can define.
+const MaxFields = 10
+
+// defaultMaxLength applies to text fields that don't set their own limit.
+const defaultMaxLength = 100
+
+// Type is the kind of input rendered for a field.
+type Type string
+
+const (
+	Text   Type = "text"
+	Number Type = "number"
+	Select Type = "select"
+)
+
+var nameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// Definition describes a single custom field.
+type Definition struct {
+	Name      string   `json:"name"`
+	Label     string   `json:"label"`
+	Type      Type     `json:"type"`
+	Required  bool     `json:"required,omitempty"`
+	MaxLength int      `json:"maxLength,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Options   []string `json:"options,omitempty"`
+
+	// pattern is Pattern compiled when the definition is parsed.
+	pattern *regexp.Regexp
+}
+
+// InputName is the name of the form input for the field.
+func (d Definition) InputName() string {
+	return "field_" + d.Name
+}
+
+// Limit is the maximum length of a text value.
+func (d Definition) Limit() int {
+	if d.MaxLength > 0 {
+		return d.MaxLength
+	}
+
+	return defaultMaxLength
+}
+
+func (d *Definition) validate() error {
+	if !nameRe.MatchString(d.Name) {
+		return fmt.Errorf("invalid field name %q", d.Name)
+	}
+
+	if strings.TrimSpace(d.Label) == "" {
+		return fmt.Errorf("field %s has no label", d.Name)
+	}
+
+	switch d.Type {
+	case Text:
+		if d.Pattern != "" {
+			re, err := compilePattern(d.Pattern)
+			if err != nil {
+				return fmt.Errorf("field %s has an invalid pattern: %w", d.Name, err)
+			}
+			d.pattern = re
+		}
+	case Number:
+		if d.Min != nil && d.Max != nil && *d.Min > *d.Max {
+			return fmt.Errorf("field %s has min greater than max", d.Name)
+		}
+	case Select:
+		if len(d.Options) == 0 {
+			return fmt.Errorf("field %s has no options", d.Name)
+		}
+	default:
+		return fmt.Errorf("field %s has unknown type %q", d.Name, d.Type)
+	}
+
+	return nil
+}
+
+// compilePattern compiles a field's pattern to match the whole value, as
+// browsers do with the form's pattern attribute.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// ParseDefinitions decodes and validates a guestbook's field definitions.
+func ParseDefinitions(data []byte) ([]Definition, error) {
+	var defs []Definition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("failed to decode fields: %w", err)
+	}
+
+	if len(defs) > MaxFields {
+		return nil, fmt.Errorf("at most %d fields are allowed", MaxFields)
+	}
+
+	seen := map[string]bool{}
+	for i := range defs {
+		def := &defs[i]
+		if err := def.validate(); err != nil {
+			return nil, err
+		}
+
+		if seen[def.Name] {
+			return nil, fmt.Errorf("duplicate field name %q", def.Name)
+		}
+		seen[def.Name] = true
+	}
+
+	return defs, nil
+}
+
+// ValidationError describes a submitted value that didn't meet its
+// field's definition.
+type ValidationError struct {
+	Label  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", e.Label, e.Reason)
+}
+
+// Validate checks the submitted values against the definitions, returning
+// the values to store keyed by field name. Fields that aren't defined are
+// ignored.
+func Validate(defs []Definition, get func(name string) string) (map[string]any, error) {
+	values := map[string]any{}
+
+	for _, def := range defs {
+		raw := strings.TrimSpace(get(def.InputName()))
+
+		if raw == "" {
+			if def.Required {
+				return nil, &ValidationError{Label: def.Label, Reason: "is required"}
+			}
+			continue
+		}
+
+		value, err := def.parse(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		values[def.Name] = value
+	}
+
+	return values, nil
+}
+
+func (d Definition) parse(raw string) (any, error) {
+	switch d.Type {
+	case Number:
+		num, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, &ValidationError{Label: d.Label, Reason: "must be a number"}
+		}
+
+		if d.Min != nil && num < *d.Min {
+			return nil, &ValidationError{
+				Label: d.Label, Reason: fmt.Sprintf("must be at least %g", *d.Min),
+			}
+		}
+
+		if d.Max != nil && num > *d.Max {
+			return nil, &ValidationError{
+				Label: d.Label, Reason: fmt.Sprintf("must be at most %g", *d.Max),
+			}
+		}
+
+		return num, nil
+	case Select:
+		for _, option := range d.Options {
+			if option == raw {
+				return raw, nil
+			}
+		}
+
+		return nil, &ValidationError{Label: d.Label, Reason: "is not a valid option"}
+	default:
+		if utf8.RuneCountInString(raw) > d.Limit() {
+			return nil, &ValidationError{
+				Label: d.Label, Reason: fmt.Sprintf("must be at most %d characters", d.Limit()),
+			}
+		}
+
+		if d.Pattern != "" {
+			re := d.pattern
+			if re == nil {
+				// The definition wasn't parsed, so the pattern hasn't been
+				// compiled yet
+				var err error
+				if re, err = compilePattern(d.Pattern); err != nil {
+					return nil, &ValidationError{Label: d.Label, Reason: "is not in the expected format"}
+				}
+			}
+
+			if !re.MatchString(raw) {
+				return nil, &ValidationError{Label: d.Label, Reason: "is not in the expected format"}
+			}
+		}
+
+		return raw, nil
+	}
+}
+
+// Value is a stored field value paired with its label for display.
+type Value struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Value any    `json:"value"`
+}
+
+// Values decodes stored values, returning them in definition order. Values
+// for fields that have since been removed are dropped.
+func Values(defs []Definition, data []byte) []Value {
+	stored := map[string]any{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil
+	}
+
+	res := []Value{}
+	for _, def := range defs {
+		value, ok := stored[def.Name]
+		if !ok {
+			continue
+		}
+
+		res = append(res, Value{
+			Name:  def.Name,
+			Label: def.Label,
+			Value: value,
+		})
+	}
+
+	return res
+}
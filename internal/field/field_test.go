@@ -0,0 +1,126 @@
+package field_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/field"
+)
+
+func TestParseDefinitions(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Input       string
+		ExpectedErr string
+	}{
+		{
+			Description: "valid definitions",
+			Input:       `[{"name":"table","label":"Table number","type":"number","min":1,"max":40}]`,
+		},
+		{
+			Description: "invalid name",
+			Input:       `[{"name":"Table Number","label":"Table","type":"text"}]`,
+			ExpectedErr: `invalid field name "Table Number"`,
+		},
+		{
+			Description: "unknown type",
+			Input:       `[{"name":"table","label":"Table","type":"date"}]`,
+			ExpectedErr: `field table has unknown type "date"`,
+		},
+		{
+			Description: "select without options",
+			Input:       `[{"name":"side","label":"Side","type":"select"}]`,
+			ExpectedErr: "field side has no options",
+		},
+		{
+			Description: "duplicate names",
+			Input:       `[{"name":"a","label":"A","type":"text"},{"name":"a","label":"A","type":"text"}]`,
+			ExpectedErr: `duplicate field name "a"`,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Description, func(t *testing.T) {
+			_, err := field.ParseDefinitions([]byte(test.Input))
+			if test.ExpectedErr != "" {
+				assert.EqualError(t, err, test.ExpectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	defs, err := field.ParseDefinitions([]byte(`[
+		{"name":"table","label":"Table number","type":"number","required":true,"min":1,"max":40},
+		{"name":"from","label":"Where are you from","type":"text","maxLength":5},
+		{"name":"side","label":"Side","type":"select","options":["bride","groom"]},
+		{"name":"room","label":"Room","type":"text","pattern":"\\d+"}
+	]`))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		Description    string
+		Form           url.Values
+		ExpectedValues map[string]any
+		ExpectedErr    string
+	}{
+		{
+			Description: "valid values",
+			Form: url.Values{
+				"field_table": {"12"}, "field_from": {"Leeds"}, "field_side": {"groom"},
+			},
+			ExpectedValues: map[string]any{"table": 12.0, "from": "Leeds", "side": "groom"},
+		},
+		{
+			Description:    "optional fields can be left out",
+			Form:           url.Values{"field_table": {"3"}},
+			ExpectedValues: map[string]any{"table": 3.0},
+		},
+		{
+			Description: "missing required field",
+			Form:        url.Values{},
+			ExpectedErr: "Table number is required",
+		},
+		{
+			Description: "number out of range",
+			Form:        url.Values{"field_table": {"41"}},
+			ExpectedErr: "Table number must be at most 40",
+		},
+		{
+			Description: "text too long",
+			Form:        url.Values{"field_table": {"1"}, "field_from": {"Manchester"}},
+			ExpectedErr: "Where are you from must be at most 5 characters",
+		},
+		{
+			Description: "unknown option",
+			Form:        url.Values{"field_table": {"1"}, "field_side": {"neither"}},
+			ExpectedErr: "Side is not a valid option",
+		},
+		{
+			Description:    "pattern matching the whole value",
+			Form:           url.Values{"field_table": {"1"}, "field_room": {"204"}},
+			ExpectedValues: map[string]any{"table": 1.0, "room": "204"},
+		},
+		{
+			Description: "pattern matching only part of the value",
+			Form:        url.Values{"field_table": {"1"}, "field_room": {"abc1"}},
+			ExpectedErr: "Room is not in the expected format",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Description, func(t *testing.T) {
+			values, err := field.Validate(defs, test.Form.Get)
+			if test.ExpectedErr != "" {
+				assert.EqualError(t, err, test.ExpectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.ExpectedValues, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Package fingerprint identifies visitors by the headers their browser
+// sends, so that their messages can be linked even when their address
+// changes.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// headers are combined to form the fingerprint. They're stable for a
+// browser but vary between browsers, languages and platforms.
+var headers = []string{
+	"User-Agent",
+	"Accept-Language",
+	"Accept-Encoding",
+	"Sec-Ch-Ua",
+	"Sec-Ch-Ua-Platform",
+}
+
+// Of returns the fingerprint of the browser that made the request, as 32
+// hex characters.
+func Of(r *http.Request) string {
+	h := sha256.New()
+
+	for _, name := range headers {
+		h.Write([]byte(r.Header.Get(name)))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// OfSender returns the fingerprint of an email sender, so that messages
+// left by email can be linked by their address in the same way.
+func OfSender(address string) string {
+	h := sha256.New()
+	h.Write([]byte("mail\x00"))
+	h.Write([]byte(strings.ToLower(address)))
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Valid reports whether s has the form of a fingerprint.
+func Valid(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
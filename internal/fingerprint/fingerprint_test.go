@@ -0,0 +1,43 @@
+package fingerprint_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/fingerprint"
+)
+
+func TestOf(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	r.Header.Set("Accept-Language", "en-GB")
+
+	same := httptest.NewRequest("POST", "/g/other", nil)
+	same.RemoteAddr = "198.51.100.7:4000"
+	same.Header.Set("User-Agent", "Mozilla/5.0")
+	same.Header.Set("Accept-Language", "en-GB")
+
+	other := httptest.NewRequest("GET", "/", nil)
+	other.Header.Set("User-Agent", "Mozilla/5.0")
+	other.Header.Set("Accept-Language", "fr-FR")
+
+	fp := fingerprint.Of(r)
+	assert.True(t, fingerprint.Valid(fp))
+	assert.Equal(t, fp, fingerprint.Of(same))
+	assert.NotEqual(t, fp, fingerprint.Of(other))
+}
+
+func TestOfSender(t *testing.T) {
+	fp := fingerprint.OfSender("Grandma@example.com")
+	assert.True(t, fingerprint.Valid(fp))
+	assert.Equal(t, fp, fingerprint.OfSender("grandma@example.com"))
+	assert.NotEqual(t, fp, fingerprint.OfSender("grandpa@example.com"))
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, fingerprint.Valid("0123456789abcdef0123456789abcdef"))
+	assert.False(t, fingerprint.Valid("0123456789abcdef"))
+	assert.False(t, fingerprint.Valid("0123456789abcdef0123456789abcdeg"))
+}
@@ -0,0 +1,172 @@
+// Package forwardauth implements Traefik's ForwardAuth contract, so the
+// guestbook's ban list, rate limits and maintenance mode protect any
+// service behind the proxy.
+//
+// Traefik sends the forward auth request with the original request's
+// headers, and describes it with the X-Forwarded-Method, X-Forwarded-Proto,
+// X-Forwarded-Host, X-Forwarded-Uri and X-Forwarded-For headers. A 2xx
+// response lets the request through, and any other response is sent to the
+// client instead.
+//
+// A middleware using the endpoint is configured with labels such as:
+//
+//	traefik.http.middlewares.protect.forwardauth.address=http://guestbook:8081/forward-auth
+//
+// The endpoint has to be served apart from the guestbook, on an address
+// only the proxy can reach, as anyone able to call it could describe any
+// request they like. The client is the address resolved through the
+// trusted proxies, so Traefik has to be one of them.
+//
+// Paths can be exempted from maintenance mode, so that admins can still
+// sign in, by listing their prefixes in exempt query parameters of the
+// address, either repeated or separated by commas.
+package forwardauth
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dreamsofcode-io/guestbook/internal/maintenance"
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+)
+
+// Limiter limits the rate of events for each key.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (middleware.Usage, bool)
+}
+
+// Policy applies a rate limit to each client's requests.
+type Policy struct {
+	Name string
+	// Methods are the request methods the policy applies to, or every
+	// method when empty.
+	Methods []string
+	Limiter Limiter
+}
+
+func (p Policy) matches(method string) bool {
+	return len(p.Methods) == 0 || slices.Contains(p.Methods, method)
+}
+
+// Handler answers forward auth requests. Each check is skipped when its
+// func is nil, and a check that fails lets the request through rather than
+// taking every service down with it.
+type Handler struct {
+	Logger *slog.Logger
+	// Banned reports whether the client's address is banned.
+	Banned func(ctx context.Context, ip net.IP) (bool, error)
+	// Maintenance returns the maintenance status.
+	Maintenance func(ctx context.Context) (maintenance.Status, error)
+	// Admin reports whether the request is from a signed in admin, who
+	// isn't affected by maintenance mode.
+	Admin func(r *http.Request) bool
+	// Policies are the rate limits each request is checked against.
+	Policies []Policy
+	// OnLimit, if set, is called with each request that is rejected for
+	// exceeding a rate limit.
+	OnLimit func(r *http.Request)
+}
+
+// Upstream rebuilds the request being authorized from the X-Forwarded
+// headers.
+func Upstream(r *http.Request) *http.Request {
+	upstream := r.Clone(r.Context())
+
+	if method := r.Header.Get("X-Forwarded-Method"); method != "" {
+		upstream.Method = method
+	}
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		upstream.URL.Scheme = proto
+	}
+
+	if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+		upstream.Host = host
+		upstream.URL.Host = host
+	}
+
+	if uri := r.Header.Get("X-Forwarded-Uri"); uri != "" {
+		if u, err := url.ParseRequestURI(uri); err == nil {
+			upstream.URL.Path = u.Path
+			upstream.URL.RawPath = u.RawPath
+			upstream.URL.RawQuery = u.RawQuery
+			upstream.RequestURI = uri
+		}
+	}
+
+	return upstream
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upstream := Upstream(r)
+	ctx := r.Context()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	clientIP := middleware.ClientIP(upstream)
+
+	if ip := net.ParseIP(clientIP); ip != nil && h.Banned != nil {
+		banned, err := h.Banned(ctx, ip)
+		if err != nil {
+			h.Logger.Error("failed to check ban list", slog.Any("error", err))
+		} else if banned {
+			http.Error(w, "You're not able to access this site.", http.StatusForbidden)
+			return
+		}
+	}
+
+	if h.Maintenance != nil && !exempt(r, upstream) {
+		status, err := h.Maintenance(ctx)
+		if err != nil {
+			h.Logger.Error("failed to check maintenance status", slog.Any("error", err))
+		} else if status.Enabled && (h.Admin == nil || !h.Admin(upstream)) {
+			w.Header().Set("Retry-After", "300")
+			http.Error(w, status.Message, http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	for _, policy := range h.Policies {
+		if !policy.matches(upstream.Method) {
+			continue
+		}
+
+		usage, allowed := policy.Limiter.Allow(ctx, "forwardauth:"+policy.Name+":"+clientIP)
+		usage.WriteHeaders(w)
+
+		if !allowed {
+			if h.OnLimit != nil {
+				h.OnLimit(upstream)
+			}
+
+			retry := int64(math.Ceil(usage.Resets.Seconds()))
+			w.Header().Set("Retry-After", strconv.FormatInt(max(retry, 1), 10))
+			http.Error(w, "Too many requests, please try again later.", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// exempt reports whether the upstream path is exempt from maintenance, as
+// listed in the exempt query parameters of the forward auth address.
+func exempt(r *http.Request, upstream *http.Request) bool {
+	for _, value := range r.URL.Query()["exempt"] {
+		for _, prefix := range strings.Split(value, ",") {
+			prefix = strings.TrimSpace(prefix)
+			if prefix != "" && strings.HasPrefix(upstream.URL.Path, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,218 @@
+package forwardauth_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/forwardauth"
+	"github.com/dreamsofcode-io/guestbook/internal/maintenance"
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+)
+
+// limiter allows a fixed number of events for each key.
+type limiter struct {
+	max    int64
+	events map[string]int64
+}
+
+func (l *limiter) Allow(ctx context.Context, key string) (middleware.Usage, bool) {
+	l.events[key]++
+
+	usage := middleware.Usage{Used: l.events[key], Limit: l.max, Resets: time.Second * 30}
+	return usage, usage.Used <= l.max
+}
+
+// proxies trusts the peer of test requests as the proxy sending forward
+// auth requests, and the network behind it.
+var proxies = middleware.Proxies{
+	netip.MustParsePrefix("192.0.2.1/32"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+}
+
+func request(target string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("X-Forwarded-Method", "GET")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "service.example.com")
+	req.Header.Set("X-Forwarded-Uri", "/page?q=1")
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	return proxies.Resolve(req)
+}
+
+func TestUpstream(t *testing.T) {
+	upstream := forwardauth.Upstream(request("/forward-auth", map[string]string{
+		"X-Forwarded-Method": "POST",
+		"X-Forwarded-Uri":    "/g/party/?tag=go",
+	}))
+
+	assert.Equal(t, "POST", upstream.Method)
+	assert.Equal(t, "https://service.example.com/g/party/?tag=go", upstream.URL.String())
+	assert.Equal(t, "service.example.com", upstream.Host)
+	assert.Equal(t, "203.0.113.7", middleware.ClientIP(upstream))
+}
+
+func TestHandler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	banned := func(ctx context.Context, ip net.IP) (bool, error) {
+		return ip.Equal(net.ParseIP("198.51.100.1")), nil
+	}
+	down := func(ctx context.Context) (maintenance.Status, error) {
+		return maintenance.Status{Enabled: true, Message: "Back at 5pm"}, nil
+	}
+	broken := func(ctx context.Context) (maintenance.Status, error) {
+		return maintenance.Status{}, errors.New("redis is down")
+	}
+	admin := func(r *http.Request) bool {
+		_, err := r.Cookie("admin")
+		return err == nil
+	}
+
+	testCases := []struct {
+		Description    string
+		Handler        forwardauth.Handler
+		Request        *http.Request
+		Repeat         int
+		ExpectedCode   int
+		ExpectedBody   string
+		ExpectedRetry  string
+		ExpectedLimits bool
+	}{
+		{
+			Description:  "allowed",
+			Handler:      forwardauth.Handler{Logger: logger, Banned: banned},
+			Request:      request("/forward-auth", nil),
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Description: "banned",
+			Handler:     forwardauth.Handler{Logger: logger, Banned: banned},
+			Request: request("/forward-auth", map[string]string{
+				"X-Forwarded-For": "198.51.100.1, 10.0.0.1",
+			}),
+			ExpectedCode: http.StatusForbidden,
+			ExpectedBody: "You're not able to access this site.\n",
+		},
+		{
+			Description:   "maintenance",
+			Handler:       forwardauth.Handler{Logger: logger, Maintenance: down, Admin: admin},
+			Request:       request("/forward-auth", nil),
+			ExpectedCode:  http.StatusServiceUnavailable,
+			ExpectedBody:  "Back at 5pm\n",
+			ExpectedRetry: "300",
+		},
+		{
+			Description: "admins skip maintenance",
+			Handler:     forwardauth.Handler{Logger: logger, Maintenance: down, Admin: admin},
+			Request: request("/forward-auth", map[string]string{
+				"Cookie": "admin=1",
+			}),
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Description: "exempt paths skip maintenance",
+			Handler:     forwardauth.Handler{Logger: logger, Maintenance: down, Admin: admin},
+			Request: request("/forward-auth?exempt=/login,/logout", map[string]string{
+				"X-Forwarded-Uri": "/login?next=/admin",
+			}),
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Description: "repeated exempt paths",
+			Handler:     forwardauth.Handler{Logger: logger, Maintenance: down, Admin: admin},
+			Request: request("/forward-auth?exempt=/login&exempt=/static/", map[string]string{
+				"X-Forwarded-Uri": "/static/css/style.css",
+			}),
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Description:  "failing checks let requests through",
+			Handler:      forwardauth.Handler{Logger: logger, Maintenance: broken},
+			Request:      request("/forward-auth", nil),
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Description: "within the rate limit",
+			Handler: forwardauth.Handler{Logger: logger, Policies: []forwardauth.Policy{
+				{Name: "write", Methods: []string{"POST"}, Limiter: &limiter{max: 1, events: map[string]int64{}}},
+			}},
+			Request: request("/forward-auth", map[string]string{
+				"X-Forwarded-Method": "POST",
+			}),
+			ExpectedCode:   http.StatusOK,
+			ExpectedLimits: true,
+		},
+		{
+			Description: "over the rate limit",
+			Handler: forwardauth.Handler{Logger: logger, Policies: []forwardauth.Policy{
+				{Name: "write", Methods: []string{"POST"}, Limiter: &limiter{max: 1, events: map[string]int64{}}},
+			}},
+			Request: request("/forward-auth", map[string]string{
+				"X-Forwarded-Method": "POST",
+			}),
+			Repeat:         1,
+			ExpectedCode:   http.StatusTooManyRequests,
+			ExpectedBody:   "Too many requests, please try again later.\n",
+			ExpectedRetry:  "30",
+			ExpectedLimits: true,
+		},
+		{
+			Description: "policies only apply to their methods",
+			Handler: forwardauth.Handler{Logger: logger, Policies: []forwardauth.Policy{
+				{Name: "write", Methods: []string{"POST"}, Limiter: &limiter{max: 1, events: map[string]int64{}}},
+			}},
+			Request:      request("/forward-auth", nil),
+			Repeat:       1,
+			ExpectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			for range tc.Repeat {
+				tc.Handler.ServeHTTP(httptest.NewRecorder(), tc.Request)
+			}
+
+			w := httptest.NewRecorder()
+			tc.Handler.ServeHTTP(w, tc.Request)
+
+			assert.Equal(t, tc.ExpectedCode, w.Code)
+			assert.Equal(t, tc.ExpectedBody, w.Body.String())
+			assert.Equal(t, tc.ExpectedRetry, w.Header().Get("Retry-After"))
+			assert.Equal(t, tc.ExpectedLimits, w.Header().Get("X-RateLimit-Limit") != "")
+		})
+	}
+}
+
+func TestHandlerOnLimit(t *testing.T) {
+	var limited []string
+
+	handler := forwardauth.Handler{
+		Logger: slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		Policies: []forwardauth.Policy{
+			{Name: "read", Limiter: &limiter{max: 0, events: map[string]int64{}}},
+		},
+		OnLimit: func(r *http.Request) {
+			limited = append(limited, r.URL.Path)
+		},
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), request("/forward-auth", nil))
+
+	assert.Equal(t, []string{"/page"}, limited)
+}
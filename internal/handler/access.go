@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+type accessPage struct {
+	Guestbook repository.Guestbook
+	Path      string
+	Failed    bool
+}
+
+// Access renders the page where visitors enter a guestbook's access code.
+func (h *Guestbook) Access(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	if access.Visibility(gb.Visibility) != access.AccessCode {
+		http.Redirect(w, r, basePath(gb)+"/", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	h.render(w, gb, "access.html", accessPage{
+		Guestbook: gb,
+		Path:      basePath(gb),
+	})
+}
+
+// EnterCode checks a submitted access code, granting the visitor a signed
+// cookie if it's correct.
+func (h *Guestbook) EnterCode(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if access.Visibility(gb.Visibility) != access.AccessCode ||
+		!h.gate.VerifyCode(gb, r.PostForm.Get("code")) {
+		w.Header().Add("Content-Type", "text/html")
+		w.Header().Set("X-Robots-Tag", "noindex")
+		h.render(w, gb, "access.html", accessPage{
+			Guestbook: gb,
+			Path:      basePath(gb),
+			Failed:    true,
+		})
+		return
+	}
+
+	h.gate.Grant(w, gb)
+	http.Redirect(w, r, basePath(gb)+"/", http.StatusSeeOther)
+}
+
+// Role returns how the account the request is signed in as relates to the
+// guestbook, for the gate. Admins are treated as owners.
+func (h *Guestbook) Role(r *http.Request, gb repository.Guestbook) access.Role {
+	accountID, ok := h.sessions.AccountID(r)
+	if !ok {
+		return access.Anonymous
+	}
+
+	if gb.OwnerID.Valid && gb.OwnerID.UUID == accountID {
+		return access.Owner
+	}
+
+	account, err := h.repo.FindAccount(r.Context(), accountID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			h.logger.ErrorContext(r.Context(), "failed to find account", slog.Any("error", err))
+		}
+		return access.Stranger
+	}
+
+	if account.Admin {
+		return access.Owner
+	}
+
+	invited, err := h.repo.IsGuestbookViewer(r.Context(), repository.IsGuestbookViewerParams{
+		GuestbookID: gb.ID,
+		AccountID:   accountID,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find viewer", slog.Any("error", err))
+		return access.Stranger
+	}
+
+	if invited {
+		return access.Invited
+	}
+
+	return access.Stranger
+}
+
+// InviteViewer lets the account with the submitted email view the
+// guestbook when it requires signing in.
+func (h *Guestbook) InviteViewer(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	email := strings.TrimSpace(strings.ToLower(r.PostForm.Get("email")))
+
+	account, err := h.repo.FindAccountByEmail(r.Context(), email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Redirect(
+			w, r, basePath(gb)+"/dashboard?viewerError="+url.QueryEscape("No account uses that email"),
+			http.StatusSeeOther,
+		)
+		return
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find account", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = h.repo.InsertGuestbookViewer(r.Context(), repository.InsertGuestbookViewerParams{
+		GuestbookID: gb.ID,
+		AccountID:   account.ID,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to insert viewer", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/dashboard", http.StatusSeeOther)
+}
+
+// RemoveViewer takes back an account's invitation to view the guestbook.
+func (h *Guestbook) RemoveViewer(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	accountID, err := uuid.Parse(r.PostForm.Get("account"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = h.repo.DeleteGuestbookViewer(r.Context(), repository.DeleteGuestbookViewerParams{
+		GuestbookID: gb.ID,
+		AccountID:   accountID,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to delete viewer", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/dashboard", http.StatusSeeOther)
+}
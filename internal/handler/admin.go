@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/dreamsofcode-io/guestbook/internal/maintenance"
+	"github.com/dreamsofcode-io/guestbook/internal/quota"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+type adminAccount struct {
+	repository.Account
+	Usage []quota.Line
+}
+
+type adminPage struct {
+	Accounts    []adminAccount
+	Plans       []string
+	Maintenance maintenance.Status
+	Error       string
+}
+
+// maxMaintenanceMessage is the longest message shown during maintenance.
+const maxMaintenanceMessage = 256
+
+// requireAdmin ensures the request comes from an admin, sending anyone
+// else to sign in.
+func (h *Guestbook) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	accountID, ok := h.signedIn(w, r)
+	if !ok {
+		return false
+	}
+
+	account, err := h.repo.FindAccount(r.Context(), accountID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find account", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	if !account.Admin {
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// IsAdmin reports whether the request is from a signed in admin.
+func (h *Guestbook) IsAdmin(r *http.Request) bool {
+	accountID, ok := h.sessions.AccountID(r)
+	if !ok {
+		return false
+	}
+
+	account, err := h.repo.FindAccount(r.Context(), accountID)
+	if err != nil {
+		return false
+	}
+
+	return account.Admin
+}
+
+// Admin lists every account along with its plan and usage this period.
+func (h *Guestbook) Admin(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	accounts, err := h.repo.FindAccounts(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find accounts", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	counts, err := h.repo.CountGuestbooksGroupedByOwner(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to count guestbooks", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := h.repo.FindUsageByPeriod(r.Context(), quota.Period(time.Now()))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find usage", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	guestbooks := map[uuid.UUID]int64{}
+	for _, c := range counts {
+		guestbooks[c.OwnerID.UUID] = c.Count
+	}
+
+	used := map[uuid.UUID]map[quota.Metric]int64{}
+	for _, u := range usage {
+		if used[u.AccountID] == nil {
+			used[u.AccountID] = map[quota.Metric]int64{}
+		}
+		used[u.AccountID][quota.Metric(u.Metric)] = u.Amount
+	}
+
+	rows := make([]adminAccount, 0, len(accounts))
+	for _, account := range accounts {
+		rows = append(rows, adminAccount{
+			Account: account,
+			Usage: quota.Report(
+				limits(account), guestbooks[account.ID], used[account.ID],
+			),
+		})
+	}
+
+	status, err := h.mode.Status(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find maintenance status", slog.Any("error", err))
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	h.themes.Base().ExecuteTemplate(w, "admin.html", adminPage{
+		Accounts:    rows,
+		Plans:       quota.PlanNames(),
+		Maintenance: status,
+		Error:       r.URL.Query().Get("error"),
+	})
+}
+
+// UpdateMaintenance turns maintenance mode on or off, which is enforced by
+// the forward auth endpoint.
+func (h *Guestbook) UpdateMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status := maintenance.Status{
+		Enabled: r.PostForm.Get("enabled") == "on",
+		Message: strings.TrimSpace(r.PostForm.Get("message")),
+	}
+
+	if len(status.Message) > maxMaintenanceMessage {
+		http.Redirect(
+			w, r, "/admin?error="+url.QueryEscape("the maintenance message is too long"),
+			http.StatusSeeOther,
+		)
+		return
+	}
+
+	if err := h.mode.Set(r.Context(), status); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to set maintenance status", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("maintenance mode changed", slog.Bool("enabled", status.Enabled))
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// parseOverride reads a limit override from a form value. A blank value
+// removes the override, and "unlimited" lifts the limit entirely.
+func parseOverride(value string) (pgtype.Int8, error) {
+	value = strings.TrimSpace(value)
+
+	switch value {
+	case "":
+		return pgtype.Int8{}, nil
+	case "unlimited":
+		return pgtype.Int8{Int64: quota.Unlimited, Valid: true}, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return pgtype.Int8{}, fmt.Errorf("invalid limit %q", value)
+	}
+
+	return pgtype.Int8{Int64: n, Valid: true}, nil
+}
+
+// formatOverride is the inverse of parseOverride, for filling in forms.
+func formatOverride(v pgtype.Int8) string {
+	switch {
+	case !v.Valid:
+		return ""
+	case v.Int64 == quota.Unlimited:
+		return "unlimited"
+	}
+
+	return strconv.FormatInt(v.Int64, 10)
+}
+
+// UpdatePlan changes an account's plan and its overrides of the plan's
+// limits.
+func (h *Guestbook) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	plan := r.PostForm.Get("plan")
+	if _, ok := quota.Plans[plan]; !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	params := repository.UpdateAccountPlanParams{ID: id, Plan: plan}
+
+	for name, dst := range map[string]*pgtype.Int8{
+		"guestbooks":    &params.QuotaGuestbooks,
+		"messages":      &params.QuotaMessages,
+		"storage_bytes": &params.QuotaStorageBytes,
+		"api_calls":     &params.QuotaApiCalls,
+	} {
+		if *dst, err = parseOverride(r.PostForm.Get(name)); err != nil {
+			http.Redirect(
+				w, r, "/admin?error="+url.QueryEscape(err.Error()), http.StatusSeeOther,
+			)
+			return
+		}
+	}
+
+	if err := h.repo.UpdateAccountPlan(r.Context(), params); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to update plan", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info(
+		"updated account plan",
+		slog.String("account", id.String()), slog.String("plan", plan),
+	)
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
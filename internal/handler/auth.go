@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/dreamsofcode-io/guestbook/internal/auth"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// minPasswordLength is the shortest password accepted at sign up.
+const minPasswordLength = 8
+
+// Auth handles signing owners up, in and out.
+type Auth struct {
+	logger   *slog.Logger
+	tmpl     *template.Template
+	repo     *repository.Queries
+	sessions *auth.Sessions
+}
+
+func NewAuth(
+	logger *slog.Logger, db *pgxpool.Pool, tmpl *template.Template,
+	sessions *auth.Sessions,
+) *Auth {
+	return &Auth{
+		logger:   logger,
+		tmpl:     tmpl,
+		repo:     repository.New(db),
+		sessions: sessions,
+	}
+}
+
+type loginPage struct {
+	Next   string
+	Email  string
+	Failed bool
+}
+
+type signupPage struct {
+	Email string
+	Error string
+}
+
+// isUniqueViolation reports whether err is from a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// safeNext only allows redirecting back to a local path, to avoid the
+// login page being used as an open redirect.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") ||
+		strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+
+	return next
+}
+
+func (h *Auth) Login(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "text/html")
+	h.tmpl.ExecuteTemplate(w, "login.html", loginPage{
+		Next: safeNext(r.URL.Query().Get("next")),
+	})
+}
+
+func (h *Auth) SignIn(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	email := strings.TrimSpace(strings.ToLower(r.PostForm.Get("email")))
+	password := r.PostForm.Get("password")
+	next := safeNext(r.PostForm.Get("next"))
+
+	account, err := h.repo.FindAccountByEmail(r.Context(), email)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		h.logger.ErrorContext(r.Context(), "failed to find account", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !auth.CheckPassword(account.PasswordHash, password) {
+		w.Header().Add("Content-Type", "text/html")
+		h.tmpl.ExecuteTemplate(w, "login.html", loginPage{
+			Next:   next,
+			Email:  email,
+			Failed: true,
+		})
+		return
+	}
+
+	h.sessions.SignIn(w, account.ID)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (h *Auth) SignOut(w http.ResponseWriter, r *http.Request) {
+	h.sessions.SignOut(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *Auth) Signup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "text/html")
+	h.tmpl.ExecuteTemplate(w, "signup.html", signupPage{})
+}
+
+// CreateAccount signs up a new owner and sends them on to create their
+// first guestbook.
+func (h *Auth) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	email := strings.TrimSpace(strings.ToLower(r.PostForm.Get("email")))
+	password := r.PostForm.Get("password")
+
+	fail := func(msg string) {
+		w.Header().Add("Content-Type", "text/html")
+		h.tmpl.ExecuteTemplate(w, "signup.html", signupPage{
+			Email: email,
+			Error: msg,
+		})
+	}
+
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		fail("Please enter a valid email address")
+		return
+	}
+
+	if len(password) < minPasswordLength {
+		fail("Passwords must be at least 8 characters")
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to hash password", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to create account id", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	account, err := h.repo.InsertAccount(r.Context(), repository.InsertAccountParams{
+		ID:           id,
+		Email:        email,
+		PasswordHash: hash,
+		CreatedAt:    time.Now().UTC(),
+	})
+	if isUniqueViolation(err) {
+		fail("An account with that email already exists")
+		return
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to insert account", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.sessions.SignIn(w, account.ID)
+	http.Redirect(w, r, "/new", http.StatusSeeOther)
+}
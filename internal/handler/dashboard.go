@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+
+	"github.com/dreamsofcode-io/guestbook/internal/field"
+	"github.com/dreamsofcode-io/guestbook/internal/quota"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+type dashboardMessage struct {
+	repository.Guest
+	Text   string
+	Fields []field.Value
+}
+
+type dashboardPage struct {
+	Guestbook  repository.Guestbook
+	Path       string
+	Messages   []dashboardMessage
+	Fields     string
+	FieldError string
+	Blocked    []string
+	Themes     []string
+	Usage      []quota.Line
+	// Email is nil unless messages can be sent by email.
+	Email      *emailSettings
+	EmailError string
+	// Viewers are the accounts invited to view the guestbook when it
+	// requires signing in.
+	Viewers     []repository.FindGuestbookViewersRow
+	ViewerError string
+
+	SettingsError string
+}
+
+// requireOwner ensures the request comes from the guestbook's owner,
+// sending anyone else to sign in.
+func (h *Guestbook) requireOwner(
+	w http.ResponseWriter, r *http.Request, gb repository.Guestbook,
+) bool {
+	accountID, ok := h.sessions.AccountID(r)
+	if !ok {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.Path), http.StatusSeeOther)
+		return false
+	}
+
+	if !gb.OwnerID.Valid || gb.OwnerID.UUID != accountID {
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// open returns the readable text of a message, decrypting it if it was
+// left privately.
+func (h *Guestbook) open(guest repository.Guest) string {
+	if !guest.Private {
+		return guest.Message
+	}
+
+	if h.sealer == nil {
+		return "[private message, no key configured]"
+	}
+
+	text, err := h.sealer.Open(guest.SealedMessage, guest.ID[:])
+	if err != nil {
+		h.logger.Error(
+			"failed to open private message",
+			slog.Any("error", err), slog.String("id", guest.ID.String()),
+		)
+		return "[private message could not be decrypted]"
+	}
+
+	return string(text)
+}
+
+// openFields returns the custom field values of a message, decrypting
+// them if it was left privately. Private messages left before their fields
+// were sealed have them in the clear.
+func (h *Guestbook) openFields(guest repository.Guest) []byte {
+	if !guest.Private || guest.SealedFields == nil {
+		return guest.Fields
+	}
+
+	if h.sealer == nil {
+		return nil
+	}
+
+	fields, err := h.sealer.Open(guest.SealedFields, fieldsData(guest.ID))
+	if err != nil {
+		h.logger.Error(
+			"failed to open private fields",
+			slog.Any("error", err), slog.String("id", guest.ID.String()),
+		)
+		return nil
+	}
+
+	return fields
+}
+
+// fieldsData is the additional data the fields of a private message are
+// sealed with, binding them to the guest while keeping them from being
+// swapped with its message.
+func fieldsData(id uuid.UUID) []byte {
+	return append([]byte("fields:"), id[:]...)
+}
+
+// Dashboard shows the owner every message left in their guestbook,
+// including private messages.
+func (h *Guestbook) Dashboard(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	guests, err := h.repo.FindAllWithPrivate(r.Context(), repository.FindAllWithPrivateParams{
+		GuestbookID: gb.ID,
+		Limit:       500,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find guests", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	defs := h.definitions(gb)
+
+	messages := make([]dashboardMessage, 0, len(guests))
+	for _, guest := range guests {
+		messages = append(messages, dashboardMessage{
+			Guest:  guest,
+			Text:   h.open(guest),
+			Fields: field.Values(defs, h.openFields(guest)),
+		})
+	}
+
+	blocked, err := h.repo.FindBlockedTags(r.Context(), gb.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find blocked tags", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	account, err := h.repo.FindAccount(r.Context(), gb.OwnerID.UUID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find owner", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := h.usage(r.Context(), account)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to load usage", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	email, err := h.emailSettings(r.Context(), gb)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to load email settings", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	viewers, err := h.repo.FindGuestbookViewers(r.Context(), gb.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find viewers", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if defs == nil {
+		defs = []field.Definition{}
+	}
+
+	fields, _ := json.MarshalIndent(defs, "", "  ")
+
+	w.Header().Add("Content-Type", "text/html")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	h.themes.Base().ExecuteTemplate(w, "dashboard.html", dashboardPage{
+		Guestbook:  gb,
+		Path:       basePath(gb),
+		Messages:   messages,
+		Fields:     string(fields),
+		FieldError: r.URL.Query().Get("fieldError"),
+		Blocked:    blocked,
+		Themes:     h.themes.Names(),
+		Usage:      usage,
+		Email:      email,
+		EmailError: r.URL.Query().Get("emailError"),
+
+		Viewers:     viewers,
+		ViewerError: r.URL.Query().Get("viewerError"),
+
+		SettingsError: r.URL.Query().Get("settingsError"),
+	})
+}
+
+// UpdateFields replaces the guestbook's custom field definitions with the
+// submitted JSON, provided it's valid.
+func (h *Guestbook) UpdateFields(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	raw := r.PostForm.Get("fields")
+	if raw == "" {
+		raw = "[]"
+	}
+
+	defs, err := field.ParseDefinitions([]byte(raw))
+	if err != nil {
+		http.Redirect(
+			w, r, basePath(gb)+"/dashboard?fieldError="+url.QueryEscape(err.Error()),
+			http.StatusSeeOther,
+		)
+		return
+	}
+
+	if defs == nil {
+		defs = []field.Definition{}
+	}
+
+	data, err := json.Marshal(defs)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode fields", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = h.repo.UpdateGuestbookFields(r.Context(), repository.UpdateGuestbookFieldsParams{
+		ID:               gb.ID,
+		FieldDefinitions: data,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to update fields", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/dashboard", http.StatusSeeOther)
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+
+	goaway "github.com/TwiN/go-away"
+)
+
+// Filter checks a message before it's stored, returning an error to
+// reject it. The error's text is shown to the visitor.
+type Filter func(message string, ip net.IP) error
+
+// Profanity rejects messages containing profanity.
+func Profanity(message string, ip net.IP) error {
+	if goaway.IsProfane(message) {
+		return fmt.Errorf("Please don't use profanity. Your IP has been tracked %s", ip)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"html/template"
+
+	"github.com/dreamsofcode-io/guestbook/internal/tag"
+	"github.com/dreamsofcode-io/guestbook/internal/theme"
+)
+
+// Funcs returns the functions that templates rendered by the handlers
+// depend on. They must be added before the templates are parsed.
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"linkTags":      tag.Linkify,
+		"hasStylesheet": theme.HasStylesheet,
+		"override":      formatOverride,
+	}
+}
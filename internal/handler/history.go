@@ -0,0 +1,389 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/dreamsofcode-io/guestbook/internal/fingerprint"
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+	"github.com/dreamsofcode-io/guestbook/internal/moderation"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// maxHistory is the most of each kind of event shown in a history.
+const maxHistory = 500
+
+// maxReasonLength matches the size of the report reason column.
+const maxReasonLength = 256
+
+// RecordRateLimit records a request that was turned away by the rate
+// limiter, so that it shows up in the visitor's history.
+func (h *Guestbook) RecordRateLimit(r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 2*time.Second)
+	defer cancel()
+
+	err := h.repo.InsertRateLimitHit(ctx, repository.InsertRateLimitHitParams{
+		Ip:          net.ParseIP(middleware.ClientIP(r)),
+		Fingerprint: fingerprint.Of(r),
+		Path:        r.URL.Path,
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to record rate limit hit", slog.Any("error", err))
+	}
+}
+
+// Report flags a message for moderators to look at.
+func (h *Guestbook) Report(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.authorize(w, r, gb) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	guestID, err := uuid.Parse(r.PostForm.Get("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reason := strings.TrimSpace(r.PostForm.Get("reason"))
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to create report id", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	n, err := h.repo.InsertReport(r.Context(), repository.InsertReportParams{
+		ID:          id,
+		ReporterIp:  net.ParseIP(middleware.ClientIP(r)),
+		Reason:      reason,
+		CreatedAt:   time.Now().UTC(),
+		GuestID:     guestID,
+		GuestbookID: gb.ID,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to insert report", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if n == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/?reported=1", http.StatusSeeOther)
+}
+
+// historyReport is a report on one of the visitor's messages.
+type historyReport struct {
+	ReporterIp net.IP
+	Reason     string
+	CreatedAt  time.Time
+	Message    string
+}
+
+// historyDay is a day on the timeline of a visitor's activity.
+type historyDay struct {
+	Day      time.Time
+	Messages int
+	Hits     int
+	Reports  int
+}
+
+// Width returns the width of the day's bar as a percentage of the
+// busiest day.
+func (d historyDay) Width(busiest int) int {
+	if busiest == 0 {
+		return 0
+	}
+
+	return (d.Messages + d.Hits + d.Reports) * 100 / busiest
+}
+
+type historyPage struct {
+	IP          string
+	Fingerprint string
+	Query       string
+	Error       string
+	Total       int64
+	Messages    []moderationMatch
+	Hits        []repository.RateLimitHit
+	Reports     []historyReport
+	Bans        []repository.Ban
+	Timeline    []historyDay
+	Busiest     int
+
+	// Proxy is set when the network includes a trusted proxy, whose
+	// address was stored for everyone it forwarded before it was trusted.
+	Proxy bool
+}
+
+// parseNetwork parses an address or network, treating an address as a
+// network of just that address.
+func parseNetwork(value string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		return prefix.Masked(), true
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+// History shows moderators everything a visitor has done, identified by
+// their address, a network, or their browser fingerprint.
+func (h *Guestbook) History(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	page := historyPage{
+		IP:          strings.TrimSpace(r.URL.Query().Get("ip")),
+		Fingerprint: strings.TrimSpace(r.URL.Query().Get("fingerprint")),
+	}
+
+	render := func() {
+		w.Header().Add("Content-Type", "text/html")
+		w.Header().Set("X-Robots-Tag", "noindex")
+		h.themes.Base().ExecuteTemplate(w, "history.html", page)
+	}
+
+	var err error
+	var network netip.Prefix
+
+	switch {
+	case page.IP != "":
+		var ok bool
+		if network, ok = parseNetwork(page.IP); !ok {
+			page.Error = "Invalid address or network"
+			render()
+			return
+		}
+
+		page.IP = network.String()
+		page.Query = "ip:" + page.IP
+		page.Proxy = h.coversProxy(network)
+	case page.Fingerprint != "":
+		if !fingerprint.Valid(page.Fingerprint) {
+			page.Error = "Invalid fingerprint"
+			render()
+			return
+		}
+
+		page.Query = "fingerprint:" + page.Fingerprint
+	default:
+		render()
+		return
+	}
+
+	q, err := moderation.Parse(page.Query)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse history query", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+
+	if page.Total, err = moderation.Count(ctx, h.db, q); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to count messages", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	matches, err := moderation.Find(ctx, h.db, q, maxHistory)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find messages", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for _, m := range matches {
+		page.Messages = append(page.Messages, moderationMatch{
+			Match: m,
+			Text:  moderatorText(m.Message, m.Private),
+		})
+	}
+
+	if page.IP != "" {
+		err = h.networkHistory(ctx, network, &page)
+	} else {
+		err = h.fingerprintHistory(ctx, page.Fingerprint, &page)
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to load history", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	page.Timeline, page.Busiest = timeline(page)
+
+	render()
+}
+
+// coversProxy reports whether the network overlaps any of the trusted
+// proxies.
+func (h *Guestbook) coversProxy(network netip.Prefix) bool {
+	for _, proxy := range h.Proxies {
+		if proxy.Overlaps(network) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *Guestbook) networkHistory(
+	ctx context.Context, network netip.Prefix, page *historyPage,
+) error {
+	hits, err := h.repo.FindRateLimitHitsByNetwork(ctx, repository.FindRateLimitHitsByNetworkParams{
+		Network: network,
+		Limit:   maxHistory,
+	})
+	if err != nil {
+		return err
+	}
+
+	reports, err := h.repo.FindReportsByNetwork(ctx, repository.FindReportsByNetworkParams{
+		Network: network,
+		Limit:   maxHistory,
+	})
+	if err != nil {
+		return err
+	}
+
+	bans, err := h.repo.FindBansByNetwork(ctx, network)
+	if err != nil {
+		return err
+	}
+
+	page.Hits = hits
+	page.Bans = bans
+
+	for _, report := range reports {
+		page.Reports = append(page.Reports, historyReport{
+			ReporterIp: report.ReporterIp,
+			Reason:     report.Reason,
+			CreatedAt:  report.CreatedAt,
+			Message:    moderatorText(report.Message, report.Private),
+		})
+	}
+
+	return nil
+}
+
+func (h *Guestbook) fingerprintHistory(
+	ctx context.Context, fp string, page *historyPage,
+) error {
+	hits, err := h.repo.FindRateLimitHitsByFingerprint(ctx, repository.FindRateLimitHitsByFingerprintParams{
+		Fingerprint: fp,
+		Limit:       maxHistory,
+	})
+	if err != nil {
+		return err
+	}
+
+	reports, err := h.repo.FindReportsByFingerprint(ctx, repository.FindReportsByFingerprintParams{
+		Fingerprint: fp,
+		Limit:       maxHistory,
+	})
+	if err != nil {
+		return err
+	}
+
+	bans, err := h.repo.FindBansByFingerprint(ctx, fp)
+	if err != nil {
+		return err
+	}
+
+	page.Hits = hits
+	page.Bans = bans
+
+	for _, report := range reports {
+		page.Reports = append(page.Reports, historyReport{
+			ReporterIp: report.ReporterIp,
+			Reason:     report.Reason,
+			CreatedAt:  report.CreatedAt,
+			Message:    moderatorText(report.Message, report.Private),
+		})
+	}
+
+	return nil
+}
+
+// moderatorText returns the text of a message for moderators. Private
+// messages are only ever shown to the guestbook's owner, so moderators
+// see that one was left but never what it says.
+func moderatorText(message string, private bool) string {
+	if private {
+		return "[private]"
+	}
+
+	return message
+}
+
+// timeline groups the visitor's activity by day, most recent first,
+// along with the total of the busiest day.
+func timeline(page historyPage) ([]historyDay, int) {
+	days := map[time.Time]*historyDay{}
+
+	day := func(t time.Time) *historyDay {
+		t = t.UTC().Truncate(24 * time.Hour)
+		if days[t] == nil {
+			days[t] = &historyDay{Day: t}
+		}
+		return days[t]
+	}
+
+	for _, m := range page.Messages {
+		day(m.CreatedAt).Messages++
+	}
+
+	for _, hit := range page.Hits {
+		day(hit.CreatedAt).Hits++
+	}
+
+	for _, report := range page.Reports {
+		day(report.CreatedAt).Reports++
+	}
+
+	result := make([]historyDay, 0, len(days))
+	busiest := 0
+
+	for _, d := range days {
+		result = append(result, *d)
+		busiest = max(busiest, d.Messages+d.Hits+d.Reports)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Day.After(result[j].Day)
+	})
+
+	return result, busiest
+}
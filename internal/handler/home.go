@@ -1,65 +1,273 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"html/template"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 
-	goaway "github.com/TwiN/go-away"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	// "github.com/x-way/crawlerdetect"
 
-	"github.com/dreamsofcode-io/guestbook/internal/guest"
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+	"github.com/dreamsofcode-io/guestbook/internal/analytics"
+	"github.com/dreamsofcode-io/guestbook/internal/auth"
+	"github.com/dreamsofcode-io/guestbook/internal/field"
+	"github.com/dreamsofcode-io/guestbook/internal/fingerprint"
+	"github.com/dreamsofcode-io/guestbook/internal/maintenance"
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+	"github.com/dreamsofcode-io/guestbook/internal/plugin"
+	"github.com/dreamsofcode-io/guestbook/internal/presence"
+	"github.com/dreamsofcode-io/guestbook/internal/push"
 	"github.com/dreamsofcode-io/guestbook/internal/repository"
+	"github.com/dreamsofcode-io/guestbook/internal/seal"
+	"github.com/dreamsofcode-io/guestbook/internal/theme"
 )
 
+// defaultSlug is the slug of the guestbook served from the site root.
+const defaultSlug = "default"
+
+// maxMessageLength matches the size of the guest message column.
+const maxMessageLength = 256
+
 type Guestbook struct {
-	logger *slog.Logger
-	tmpl   *template.Template
-	repo   *repository.Queries
+	logger   *slog.Logger
+	themes   *theme.Set
+	db       *pgxpool.Pool
+	repo     *repository.Queries
+	gate     *access.Gate
+	sessions *auth.Sessions
+	sealer   *seal.Sealer
+	tracker  *analytics.Tracker
+	presence *presence.Hub
+	mode     *maintenance.Mode
+
+	// Filters are run against each message before it's stored.
+	Filters []Filter
+
+	// Plugins, if set, check each message that passes the filters, and may
+	// reject it or hold it for review.
+	Plugins *plugin.Host
+
+	// Push, if set, notifies owners of new messages and visitors of their
+	// messages being approved.
+	Push *push.Service
+
+	// Mail, if set, lets owners take messages by email.
+	Mail *Mail
+
+	// Proxies are the reverse proxies in front of the server. The history
+	// of their networks is that of every visitor recorded before they were
+	// trusted, so moderators are warned before acting on it.
+	Proxies middleware.Proxies
 }
 
+// New creates the guestbook handler. The sealer may be nil, in which case
+// visitors aren't offered the option of leaving private messages.
 func New(
-	logger *slog.Logger, db *pgxpool.Pool, tmpl *template.Template,
+	logger *slog.Logger, db *pgxpool.Pool, themes *theme.Set,
+	gate *access.Gate, sessions *auth.Sessions, sealer *seal.Sealer,
+	tracker *analytics.Tracker, hub *presence.Hub, mode *maintenance.Mode,
 ) *Guestbook {
 	return &Guestbook{
-		tmpl:   tmpl,
-		repo:   repository.New(db),
-		logger: logger,
+		themes:   themes,
+		db:       db,
+		repo:     repository.New(db),
+		logger:   logger,
+		gate:     gate,
+		sessions: sessions,
+		sealer:   sealer,
+		tracker:  tracker,
+		presence: hub,
+		mode:     mode,
+		Filters:  []Filter{Profanity},
 	}
 }
 
+type guestRow struct {
+	repository.Guest
+	Fields []field.Value
+}
+
 type indexPage struct {
-	Guests []repository.Guest
-	Total  int64
+	Guestbook    repository.Guestbook
+	Path         string
+	Indexable    bool
+	AllowPrivate bool
+	Fields       []field.Definition
+	Guests       []guestRow
+	Total        int64
+	Reported     bool
+	Held         bool
+	Push         *pushButton
 }
 
 type errorPage struct {
 	ErrorMessage string
 }
 
+// basePath returns the path prefix that the guestbook's pages are served
+// under.
+func basePath(gb repository.Guestbook) string {
+	if gb.Slug == defaultSlug {
+		return ""
+	}
+
+	return "/g/" + gb.Slug
+}
+
+// render executes the named template using the guestbook's theme.
+func (h *Guestbook) render(
+	w http.ResponseWriter, gb repository.Guestbook, name string, data any,
+) {
+	err := h.themes.ExecuteTemplate(w, gb.Theme, name, data)
+	if err != nil {
+		h.logger.Error(
+			"failed to render template",
+			slog.String("template", name), slog.Any("error", err),
+		)
+	}
+}
+
+// requestSlug returns the slug of the guestbook the request is for.
+func requestSlug(r *http.Request) string {
+	if slug := r.PathValue("slug"); slug != "" {
+		return slug
+	}
+
+	return defaultSlug
+}
+
+// SurrogateKeys returns the CDN surrogate keys for a guestbook page, so
+// everything cached about a guestbook can be purged together.
+func SurrogateKeys(r *http.Request) []string {
+	keys := []string{"guestbook:" + requestSlug(r)}
+	if tag := r.PathValue("tag"); tag != "" {
+		keys = append(keys, "tag:"+requestSlug(r)+":"+tag)
+	}
+
+	return keys
+}
+
+// load finds the guestbook the request is for, writing an error response
+// if it can't be found.
+func (h *Guestbook) load(w http.ResponseWriter, r *http.Request) (repository.Guestbook, bool) {
+	gb, err := h.repo.FindGuestbookBySlug(r.Context(), requestSlug(r))
+	if errors.Is(err, pgx.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return gb, false
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find guestbook", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return gb, false
+	}
+
+	return gb, true
+}
+
+// authorize enforces the guestbook's visibility, redirecting visitors to
+// the access code page or rejecting them if they aren't allowed to view it.
+func (h *Guestbook) authorize(
+	w http.ResponseWriter, r *http.Request, gb repository.Guestbook,
+) bool {
+	switch h.gate.Check(r, gb) {
+	case access.Allow:
+		visibility := access.Visibility(gb.Visibility)
+		if !visibility.Indexable() {
+			w.Header().Set("X-Robots-Tag", "noindex")
+		}
+		if visibility == access.AccessCode || visibility == access.LoginRequired {
+			// Only visitors who were let in may see the page, so shared
+			// caches must never keep it.
+			w.Header().Set("Cache-Control", "private, no-store")
+		}
+		return true
+	case access.NeedsCode:
+		http.Redirect(w, r, basePath(gb)+"/access", http.StatusSeeOther)
+	case access.Forbidden:
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.Path), http.StatusSeeOther)
+	}
+
+	return false
+}
+
+// definitions returns the guestbook's custom fields. Definitions are
+// validated when saved, so a failure here is logged and treated as having
+// no custom fields rather than breaking the page.
+func (h *Guestbook) definitions(gb repository.Guestbook) []field.Definition {
+	defs, err := field.ParseDefinitions(gb.FieldDefinitions)
+	if err != nil {
+		h.logger.Error(
+			"failed to parse field definitions",
+			slog.Any("error", err), slog.String("guestbook", gb.Slug),
+		)
+		return nil
+	}
+
+	return defs
+}
+
+// track queues a view of the guestbook to be recorded in the background,
+// so that a slow or unavailable redis doesn't hold up the page. Views are
+// dropped while the queue is full, as the counts only need to be close.
+func (h *Guestbook) track(r *http.Request, gb repository.Guestbook) {
+	h.tracker.Track(gb.ID, middleware.ClientIP(r), r.UserAgent())
+}
+
 func (h *Guestbook) Home(w http.ResponseWriter, r *http.Request) {
-	guests, err := h.repo.FindAll(r.Context(), 200)
+	gb, ok := h.load(w, r)
+	if !ok || !h.authorize(w, r, gb) {
+		return
+	}
+
+	h.track(r, gb)
+
+	guests, err := h.repo.FindAll(r.Context(), repository.FindAllParams{
+		GuestbookID: gb.ID,
+		Limit:       200,
+	})
 	if err != nil {
-		h.logger.Error("failed to find guests", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "failed to find guests", slog.Any("error", err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	count, err := h.repo.Count(r.Context())
+	count, err := h.repo.Count(r.Context(), gb.ID)
 	if err != nil {
-		h.logger.Error("failed to get count", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "failed to get count", slog.Any("error", err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	defs := h.definitions(gb)
+
+	rows := make([]guestRow, 0, len(guests))
+	for _, guest := range guests {
+		rows = append(rows, guestRow{
+			Guest:  guest,
+			Fields: field.Values(defs, guest.Fields),
+		})
+	}
+
 	w.Header().Add("Content-Type", "text/html")
-	h.tmpl.ExecuteTemplate(w, "index.html", indexPage{
-		Guests: guests,
-		Total:  count,
+	h.render(w, gb, "index.html", indexPage{
+		Guestbook:    gb,
+		Path:         basePath(gb),
+		Indexable:    access.Visibility(gb.Visibility).Indexable(),
+		AllowPrivate: h.sealer != nil,
+		Fields:       defs,
+		Guests:       rows,
+		Total:        count,
+		Reported:     r.URL.Query().Has("reported"),
+		Held:         r.URL.Query().Has("held"),
+		Push:         h.heldButton(r.URL.Query().Get("held")),
 	})
 }
 
@@ -69,8 +277,13 @@ func (h *Guestbook) Create(w http.ResponseWriter, r *http.Request) {
 	// 	return
 	// }
 	//
+	gb, ok := h.load(w, r)
+	if !ok || !h.authorize(w, r, gb) {
+		return
+	}
+
 	if err := r.ParseForm(); err != nil {
-		h.logger.Error("failed to parse form", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -81,50 +294,122 @@ func (h *Guestbook) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	message := strings.Join(msg, " ")
+	params, tags, err := h.prepare(r.Context(), gb, submission{
+		Message:     strings.Join(msg, " "),
+		Private:     r.PostForm.Get("private") != "",
+		Field:       r.PostForm.Get,
+		IP:          net.ParseIP(middleware.ClientIP(r)),
+		Fingerprint: fingerprint.Of(r),
+	})
 
-	if strings.TrimSpace(message) == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		h.tmpl.ExecuteTemplate(w, "error.html", errorPage{
-			ErrorMessage: "Blank messages don't count",
+	var rejected *rejection
+	if errors.As(err, &rejected) {
+		w.WriteHeader(rejected.status)
+		h.render(w, gb, "error.html", errorPage{
+			ErrorMessage: rejected.message,
 		})
 
+		return
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to prepare message", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	splits := strings.Split(r.RemoteAddr, ":")
-	ipStr := strings.Trim(strings.Join(splits[:len(splits)-1], ":"), "[]")
-	ip := net.ParseIP(ipStr)
-
-	if goaway.IsProfane(message) {
-		w.WriteHeader(http.StatusBadRequest)
-		h.tmpl.ExecuteTemplate(w, "error.html", errorPage{
-			ErrorMessage: fmt.Sprintf(
-				"Please don't use profanity. Your IP has been tracked %s",
-				ipStr,
-			),
+	err = h.insert(r.Context(), gb, params, tags)
+	if errors.Is(err, errQuotaExceeded) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		h.render(w, gb, "error.html", errorPage{
+			ErrorMessage: "This guestbook isn't accepting any more messages this month",
 		})
+
+		return
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to insert guest", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	guest, err := guest.NewGuest(message, ip)
-	if err != nil {
-		h.logger.Error("failed to create guest", slog.Any("error", err))
-		w.WriteHeader(http.StatusInternalServerError)
+	if params.Status == "pending" {
+		// The token lets the visitor ask to be told once it's approved
+		held := "/?held"
+		if h.Push != nil {
+			held = "/?" + url.Values{"held": {h.Push.GuestToken(params.ID)}}.Encode()
+		}
+
+		http.Redirect(w, r, basePath(gb)+held, http.StatusFound)
 		return
 	}
 
-	_, err = h.repo.Insert(r.Context(), repository.InsertParams{
-		ID:        guest.ID,
-		Message:   guest.Message,
-		CreatedAt: guest.CreatedAt,
-		Ip:        guest.IP,
-	})
+	http.Redirect(w, r, basePath(gb)+"/", http.StatusFound)
+}
+
+// blockedTag returns the first of the tags that has been blocked in the
+// guestbook, or an empty string if none are.
+func (h *Guestbook) blockedTag(
+	ctx context.Context, gb repository.Guestbook, tags []string,
+) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	blocked, err := h.repo.FindBlockedTags(ctx, gb.ID)
 	if err != nil {
-		h.logger.Error("failed to insert guest", slog.Any("error", err))
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return "", fmt.Errorf("find blocked tags: %w", err)
+	}
+
+	for _, t := range tags {
+		for _, b := range blocked {
+			if t == b {
+				return t, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// insert stores the guest along with its tags in a single transaction,
+// charging it to the guestbook's owner and queueing their notification.
+func (h *Guestbook) insert(
+	ctx context.Context, gb repository.Guestbook, params repository.InsertParams,
+	tags []string,
+) error {
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	repo := h.repo.WithTx(tx)
+
+	if _, err := repo.Insert(ctx, params); err != nil {
+		return fmt.Errorf("insert guest: %w", err)
+	}
+
+	for _, t := range tags {
+		err := repo.InsertGuestTag(ctx, repository.InsertGuestTagParams{
+			GuestID:     params.ID,
+			GuestbookID: params.GuestbookID,
+			Tag:         t,
+		})
+		if err != nil {
+			return fmt.Errorf("insert tag: %w", err)
+		}
+	}
+
+	if gb.OwnerID.Valid {
+		if err := charge(ctx, repo, gb.OwnerID.UUID, params); err != nil {
+			return err
+		}
+
+		if h.Push != nil {
+			n := newMessageNotification(gb, params)
+			if err := h.Push.NotifyAccount(ctx, repo, gb.OwnerID.UUID, n); err != nil {
+				return fmt.Errorf("notify owner: %w", err)
+			}
+		}
 	}
 
-	http.Redirect(w, r, "/", http.StatusFound)
+	return tx.Commit(ctx)
 }
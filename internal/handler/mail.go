@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dreamsofcode-io/guestbook/internal/fingerprint"
+	"github.com/dreamsofcode-io/guestbook/internal/mailtext"
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+	"github.com/dreamsofcode-io/guestbook/internal/smtpd"
+)
+
+var (
+	errOneGuestbook = &smtpd.Error{
+		Code: 452, Enhanced: "4.5.3", Message: "Send to one guestbook at a time",
+	}
+	errNotText = &smtpd.Error{
+		Code: 550, Enhanced: "5.6.0", Message: "Messages must be sent as plain text",
+	}
+	errMailTooLong = &smtpd.Error{
+		Code: 550, Enhanced: "5.3.4", Message: "Your message is too long",
+	}
+	errMailQuota = &smtpd.Error{
+		Code: 552, Enhanced: "5.2.2", Message: "This guestbook isn't accepting any more messages this month",
+	}
+)
+
+// Mail receives messages sent by email to <slug>@<domain>, for
+// guestbooks whose owners have turned it on. It's a smtpd.Backend.
+//
+// Messages go through the same checks as those left through the form, and
+// are always held for review, as the sender's address is easily forged.
+type Mail struct {
+	guestbook *Guestbook
+	domain    string
+
+	// BySender and ByIP limit how often each sender address and each
+	// client may send messages.
+	BySender *middleware.RateLimiter
+	ByIP     *middleware.RateLimiter
+}
+
+// NewMail creates the mail backend for the guestbooks, receiving mail for
+// the domain.
+func NewMail(guestbook *Guestbook, domain string, bySender, byIP *middleware.RateLimiter) *Mail {
+	return &Mail{
+		guestbook: guestbook,
+		domain:    domain,
+		BySender:  bySender,
+		ByIP:      byIP,
+	}
+}
+
+// Address returns the address messages are sent to for the guestbook.
+func (m *Mail) Address(gb repository.Guestbook) string {
+	return gb.Slug + "@" + m.domain
+}
+
+// Recipient accepts a guestbook's address when it takes messages by email
+// from the sender, and neither is sending too often.
+func (m *Mail) Recipient(ctx context.Context, env *smtpd.Envelope, to string) error {
+	at := strings.LastIndexByte(to, '@')
+	if at < 0 || !strings.EqualFold(to[at+1:], m.domain) {
+		return smtpd.ErrRelayDenied
+	}
+
+	// Each message can only go to one guestbook, so that it's checked
+	// against that guestbook's settings alone
+	if len(env.To) > 0 {
+		return errOneGuestbook
+	}
+
+	// Bounces aren't messages anyone left
+	if env.From == "" {
+		return smtpd.ErrSenderDenied
+	}
+
+	_, settings, err := m.find(ctx, strings.ToLower(to[:at]))
+	if err != nil {
+		return err
+	}
+
+	if !allowedSender(settings.AllowedSenders, env.From) {
+		return smtpd.ErrSenderDenied
+	}
+
+	ip := env.RemoteIP()
+
+	if _, ok := m.ByIP.Allow(ctx, "mail:ip:"+ip.String()); !ok {
+		m.recordLimit(ctx, ip, env.From)
+		return smtpd.ErrTooMany
+	}
+
+	if _, ok := m.BySender.Allow(ctx, "mail:from:"+strings.ToLower(env.From)); !ok {
+		m.recordLimit(ctx, ip, env.From)
+		return smtpd.ErrTooMany
+	}
+
+	return nil
+}
+
+// Deliver leaves the text of the message in the guestbook it was sent to,
+// held for review.
+func (m *Mail) Deliver(ctx context.Context, env *smtpd.Envelope) error {
+	to := env.To[0]
+	slug := strings.ToLower(to[:strings.LastIndexByte(to, '@')])
+
+	gb, settings, err := m.find(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailtext.Parse(env.Data)
+	if err != nil {
+		return errNotText
+	}
+
+	// The From header is what the author sees, so it has to be allowed as
+	// well as the envelope sender
+	if msg.From == "" || !allowedSender(settings.AllowedSenders, msg.From) {
+		return smtpd.ErrSenderDenied
+	}
+
+	text := msg.Text
+	if text == "" {
+		text = mailtext.Clean(msg.Subject)
+	}
+
+	if utf8.RuneCountInString(text) > maxMessageLength {
+		return errMailTooLong
+	}
+
+	h := m.guestbook
+
+	params, tags, err := h.prepare(ctx, gb, submission{
+		Message:     text,
+		Field:       func(string) string { return "" },
+		IP:          env.RemoteIP(),
+		Fingerprint: fingerprint.OfSender(env.From),
+		Hold:        true,
+	})
+
+	var rejected *rejection
+	if errors.As(err, &rejected) {
+		return &smtpd.Error{
+			Code:     550,
+			Enhanced: "5.7.1",
+			Message:  strings.Join(strings.Fields(rejected.message), " "),
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to prepare message: %w", err)
+	}
+
+	err = h.insert(ctx, gb, params, tags)
+	if errors.Is(err, errQuotaExceeded) {
+		return errMailQuota
+	} else if err != nil {
+		return fmt.Errorf("failed to insert guest: %w", err)
+	}
+
+	h.logger.InfoContext(ctx, "message received by email", slog.String("guestbook", gb.Slug))
+
+	return nil
+}
+
+// find returns the guestbook with the slug and its email settings,
+// failing with smtpd.ErrNoMailbox if it doesn't take messages by email.
+func (m *Mail) find(
+	ctx context.Context, slug string,
+) (repository.Guestbook, repository.GuestbookEmail, error) {
+	repo := m.guestbook.repo
+
+	gb, err := repo.FindGuestbookBySlug(ctx, slug)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return repository.Guestbook{}, repository.GuestbookEmail{}, smtpd.ErrNoMailbox
+	} else if err != nil {
+		return repository.Guestbook{}, repository.GuestbookEmail{}, fmt.Errorf("failed to find guestbook: %w", err)
+	}
+
+	settings, err := repo.FindGuestbookEmail(ctx, gb.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return repository.Guestbook{}, repository.GuestbookEmail{}, smtpd.ErrNoMailbox
+	} else if err != nil {
+		return repository.Guestbook{}, repository.GuestbookEmail{}, fmt.Errorf("failed to find email settings: %w", err)
+	}
+
+	return gb, settings, nil
+}
+
+// recordLimit records a sender being turned away for moderators, as
+// RecordRateLimit does for visitors.
+func (m *Mail) recordLimit(ctx context.Context, ip net.IP, from string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+
+	err := m.guestbook.repo.InsertRateLimitHit(ctx, repository.InsertRateLimitHitParams{
+		Ip:          ip,
+		Fingerprint: fingerprint.OfSender(from),
+		Path:        "smtp",
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		m.guestbook.logger.ErrorContext(ctx, "failed to record rate limit hit", slog.Any("error", err))
+	}
+}
+
+// allowedSender reports whether the address is on the allowlist, either
+// exactly or by an entry for its whole domain, such as "@example.com". An
+// empty allowlist allows anyone.
+func allowedSender(allowed []string, address string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	address = strings.ToLower(address)
+
+	for _, entry := range allowed {
+		entry = strings.ToLower(entry)
+
+		if strings.HasPrefix(entry, "@") {
+			if strings.HasSuffix(address, entry) {
+				return true
+			}
+		} else if address == entry {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseSenders splits the allowlist entered on the dashboard, one entry
+// per line, returning the first that isn't an address or a domain.
+func parseSenders(text string) ([]string, string) {
+	senders := []string{}
+
+	for _, line := range strings.Split(text, "\n") {
+		entry := strings.ToLower(strings.TrimSpace(line))
+		if entry == "" {
+			continue
+		}
+
+		domain, isDomain := strings.CutPrefix(entry, "@")
+		if isDomain {
+			if domain == "" || strings.ContainsAny(domain, "@ \t") {
+				return nil, line
+			}
+		} else if at := strings.LastIndexByte(entry, '@'); at <= 0 || at == len(entry)-1 ||
+			strings.ContainsAny(entry, " \t") {
+			return nil, line
+		}
+
+		senders = append(senders, entry)
+	}
+
+	return senders, ""
+}
+
+// emailSettings are the dashboard's view of a guestbook's email address.
+type emailSettings struct {
+	Address string
+	Enabled bool
+	// Senders is the allowlist, one entry per line.
+	Senders string
+}
+
+// emailSettings returns the guestbook's email settings, or nil if
+// messages can't be sent by email.
+func (h *Guestbook) emailSettings(
+	ctx context.Context, gb repository.Guestbook,
+) (*emailSettings, error) {
+	if h.Mail == nil {
+		return nil, nil
+	}
+
+	settings := &emailSettings{Address: h.Mail.Address(gb)}
+
+	email, err := h.repo.FindGuestbookEmail(ctx, gb.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return settings, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("find email settings: %w", err)
+	}
+
+	settings.Enabled = true
+	settings.Senders = strings.Join(email.AllowedSenders, "\n")
+
+	return settings, nil
+}
+
+// UpdateEmail turns messages by email on or off for the guestbook, and
+// sets who may send them.
+func (h *Guestbook) UpdateEmail(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	if h.Mail == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if r.PostForm.Get("enabled") == "" {
+		if err := h.repo.DeleteGuestbookEmail(r.Context(), gb.ID); err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to delete email settings", slog.Any("error", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, basePath(gb)+"/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	senders, invalid := parseSenders(r.PostForm.Get("senders"))
+	if invalid != "" {
+		message := fmt.Sprintf("%q isn't an address or @domain", strings.TrimSpace(invalid))
+		http.Redirect(
+			w, r, basePath(gb)+"/dashboard?emailError="+url.QueryEscape(message),
+			http.StatusSeeOther,
+		)
+		return
+	}
+
+	err := h.repo.UpsertGuestbookEmail(r.Context(), repository.UpsertGuestbookEmailParams{
+		GuestbookID:    gb.ID,
+		AllowedSenders: senders,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to update email settings", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/dashboard", http.StatusSeeOther)
+}
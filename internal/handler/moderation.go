@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dreamsofcode-io/guestbook/internal/moderation"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// maxPreview is the most matches shown when previewing a search.
+const maxPreview = 100
+
+type moderationMatch struct {
+	moderation.Match
+	Text string
+}
+
+type moderationPage struct {
+	Query    string
+	Error    string
+	Total    int64
+	Matches  []moderationMatch
+	Done     string
+	Affected string
+	Audit    []repository.FindAuditLogRow
+}
+
+// Moderation previews the messages matching a moderator's search.
+func (h *Guestbook) Moderation(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	page := moderationPage{
+		Query:    r.URL.Query().Get("q"),
+		Done:     r.URL.Query().Get("done"),
+		Affected: r.URL.Query().Get("affected"),
+	}
+
+	audit, err := h.repo.FindAuditLog(r.Context(), 20)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find audit log", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	page.Audit = audit
+
+	q, err := moderation.Parse(page.Query)
+	if err != nil && !errors.Is(err, moderation.ErrEmpty) {
+		page.Error = err.Error()
+	} else if err == nil {
+		page.Total, err = moderation.Count(r.Context(), h.db, q)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to count matches", slog.Any("error", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		matches, err := moderation.Find(r.Context(), h.db, q, maxPreview)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to find matches", slog.Any("error", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		for _, m := range matches {
+			page.Matches = append(page.Matches, moderationMatch{
+				Match: m,
+				Text:  moderatorText(m.Message, m.Private),
+			})
+		}
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	h.themes.Base().ExecuteTemplate(w, "moderation.html", page)
+}
+
+// Moderate applies a bulk action to every message matching a search,
+// recording it in the audit log.
+func (h *Guestbook) Moderate(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+
+	accountID, _ := h.sessions.AccountID(r)
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	q, err := moderation.Parse(r.PostForm.Get("q"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	action, err := moderation.ParseAction(r.PostForm.Get("action"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to create audit id", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := h.db.Begin(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to begin transaction", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// The authors of held messages are told when they're approved
+	var pending map[string][]uuid.UUID
+	if action == moderation.Approve && h.Push != nil {
+		pending, err = moderation.Pending(r.Context(), tx, q)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to find pending messages", slog.Any("error", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	affected, err := moderation.Apply(r.Context(), tx, q, action)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to apply action", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = h.repo.WithTx(tx).InsertAuditLog(r.Context(), repository.InsertAuditLogParams{
+		ID:        id,
+		AccountID: uuid.NullUUID{UUID: accountID, Valid: true},
+		Action:    string(action),
+		Query:     q.String(),
+		Affected:  affected,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to insert audit log", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for slug, ids := range pending {
+		err := h.Push.NotifyGuests(r.Context(), h.repo.WithTx(tx), ids, approvedNotification(slug))
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "failed to notify authors", slog.Any("error", err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to commit action", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info(
+		"applied moderation action",
+		slog.String("action", string(action)),
+		slog.String("query", q.String()),
+		slog.Int64("affected", affected),
+	)
+
+	values := url.Values{
+		"q":        {q.String()},
+		"done":     {string(action)},
+		"affected": {strconv.FormatInt(affected, 10)},
+	}
+
+	http.Redirect(w, r, "/admin/moderation?"+values.Encode(), http.StatusSeeOther)
+}
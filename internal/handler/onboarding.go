@@ -0,0 +1,370 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+	"github.com/dreamsofcode-io/guestbook/internal/quota"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+	"github.com/dreamsofcode-io/guestbook/internal/slug"
+)
+
+// maxTitleLength matches the size of the guestbook title column.
+const maxTitleLength = 128
+
+type accountPage struct {
+	Account    repository.Account
+	Guestbooks []repository.Guestbook
+	Usage      []quota.Line
+	Push       *pushButton
+}
+
+type newGuestbookPage struct {
+	Title      string
+	Slug       string
+	Visibility string
+	Error      string
+}
+
+type sharePage struct {
+	Guestbook repository.Guestbook
+	Path      string
+	URL       string
+	EmbedCode string
+}
+
+// signedIn returns the signed in account, sending anyone else to sign in.
+func (h *Guestbook) signedIn(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	accountID, ok := h.sessions.AccountID(r)
+	if !ok {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.Path), http.StatusSeeOther)
+	}
+
+	return accountID, ok
+}
+
+// Account lists the guestbooks owned by the signed in account.
+func (h *Guestbook) Account(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.signedIn(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := h.repo.FindAccount(r.Context(), accountID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find account", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	guestbooks, err := h.repo.FindGuestbooksByOwner(
+		r.Context(), uuid.NullUUID{UUID: accountID, Valid: true},
+	)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find guestbooks", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	usage, err := h.usage(r.Context(), account)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to load usage", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	h.themes.Base().ExecuteTemplate(w, "account.html", accountPage{
+		Account:    account,
+		Guestbooks: guestbooks,
+		Usage:      usage,
+		Push:       h.ownerButton(),
+	})
+}
+
+func (h *Guestbook) NewGuestbook(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.signedIn(w, r); !ok {
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	h.themes.Base().ExecuteTemplate(w, "new.html", newGuestbookPage{
+		Visibility: string(access.Public),
+	})
+}
+
+// settings validates the title, visibility and access code submitted for
+// a guestbook, returning the access code hash to store. The existing hash
+// is kept if no new code was entered.
+func settings(
+	title string, visibility string, code string, existingHash string,
+) (string, error) {
+	if title == "" || utf8.RuneCountInString(title) > maxTitleLength {
+		return "", fmt.Errorf("titles must be between 1 and %d characters", maxTitleLength)
+	}
+
+	v, err := access.ParseVisibility(visibility)
+	if err != nil {
+		return "", fmt.Errorf("please choose who can see the guestbook")
+	}
+
+	if code == "" {
+		if v == access.AccessCode && existingHash == "" {
+			return "", fmt.Errorf("please choose an access code")
+		}
+		return existingHash, nil
+	}
+
+	return access.HashCode(code)
+}
+
+// CreateGuestbook creates a guestbook owned by the signed in account, then
+// sends them to its share page.
+func (h *Guestbook) CreateGuestbook(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.signedIn(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	page := newGuestbookPage{
+		Title:      strings.TrimSpace(r.PostForm.Get("title")),
+		Slug:       slug.Normalize(r.PostForm.Get("slug")),
+		Visibility: r.PostForm.Get("visibility"),
+	}
+
+	fail := func(err error) {
+		page.Error = err.Error()
+		w.Header().Add("Content-Type", "text/html")
+		h.themes.Base().ExecuteTemplate(w, "new.html", page)
+	}
+
+	if err := slug.Validate(page.Slug); err != nil {
+		fail(err)
+		return
+	}
+
+	hash, err := settings(page.Title, page.Visibility, r.PostForm.Get("code"), "")
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to create guestbook id", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	gb, err := h.insertGuestbook(r.Context(), repository.InsertGuestbookParams{
+		ID:             id,
+		Slug:           page.Slug,
+		Title:          page.Title,
+		Visibility:     page.Visibility,
+		AccessCodeHash: hash,
+		OwnerID:        uuid.NullUUID{UUID: accountID, Valid: true},
+		CreatedAt:      time.Now().UTC(),
+	})
+
+	var rejected *rejection
+	if errors.As(err, &rejected) {
+		fail(rejected)
+		return
+	} else if isUniqueViolation(err) {
+		fail(fmt.Errorf("that slug is already taken"))
+		return
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to insert guestbook", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/dashboard/share", http.StatusSeeOther)
+}
+
+// insertGuestbook creates the guestbook if its owner's plan allows another,
+// returning a *rejection if it doesn't. The owner's account is locked
+// while their guestbooks are counted, so that concurrent requests can't
+// both take the last one.
+func (h *Guestbook) insertGuestbook(
+	ctx context.Context, params repository.InsertGuestbookParams,
+) (repository.Guestbook, error) {
+	tx, err := h.db.Begin(ctx)
+	if err != nil {
+		return repository.Guestbook{}, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	repo := h.repo.WithTx(tx)
+
+	account, err := repo.FindAccountForUpdate(ctx, params.OwnerID.UUID)
+	if err != nil {
+		return repository.Guestbook{}, fmt.Errorf("find account: %w", err)
+	}
+
+	owned, err := repo.CountGuestbooksByOwner(ctx, params.OwnerID)
+	if err != nil {
+		return repository.Guestbook{}, fmt.Errorf("count guestbooks: %w", err)
+	}
+
+	if limit := limits(account).Guestbooks; !quota.Allows(limit, owned, 1) {
+		return repository.Guestbook{}, reject(
+			http.StatusForbidden, fmt.Sprintf("your plan allows up to %d guestbooks", limit),
+		)
+	}
+
+	gb, err := repo.InsertGuestbook(ctx, params)
+	if err != nil {
+		return repository.Guestbook{}, fmt.Errorf("insert guestbook: %w", err)
+	}
+
+	return gb, tx.Commit(ctx)
+}
+
+// UpdateSettings saves changes to a guestbook's title and visibility.
+func (h *Guestbook) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	title := strings.TrimSpace(r.PostForm.Get("title"))
+	visibility := r.PostForm.Get("visibility")
+
+	hash, err := settings(title, visibility, r.PostForm.Get("code"), gb.AccessCodeHash)
+	if err != nil {
+		http.Redirect(
+			w, r, basePath(gb)+"/dashboard?settingsError="+url.QueryEscape(err.Error()),
+			http.StatusSeeOther,
+		)
+		return
+	}
+
+	err = h.repo.UpdateGuestbookSettings(r.Context(), repository.UpdateGuestbookSettingsParams{
+		ID:             gb.ID,
+		Title:          title,
+		Visibility:     visibility,
+		AccessCodeHash: hash,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to update settings", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/dashboard", http.StatusSeeOther)
+}
+
+// siteURL returns the scheme and host the request was made to, taking
+// into account TLS terminated by the proxy.
+func siteURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
+
+// Share shows the owner the links they can use to share their guestbook.
+func (h *Guestbook) Share(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	link := siteURL(r) + basePath(gb) + "/"
+	embed := fmt.Sprintf(
+		`<iframe src="%s" title="%s" width="100%%" height="600" style="border:0"></iframe>`,
+		siteURL(r)+basePath(gb)+"/embed", strings.ReplaceAll(gb.Title, `"`, "&quot;"),
+	)
+
+	w.Header().Add("Content-Type", "text/html")
+	h.themes.Base().ExecuteTemplate(w, "share.html", sharePage{
+		Guestbook: gb,
+		Path:      basePath(gb),
+		URL:       link,
+		EmbedCode: embed,
+	})
+}
+
+// QRCode renders a QR code linking to the guestbook.
+func (h *Guestbook) QRCode(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	png, err := qrcode.Encode(siteURL(r)+basePath(gb)+"/", qrcode.Medium, 512)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode qr code", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+type embedPage struct {
+	Guestbook repository.Guestbook
+	Path      string
+	Guests    []guestRow
+}
+
+// Embed renders a compact listing of the guestbook that can be placed on
+// other sites in an iframe.
+func (h *Guestbook) Embed(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.authorize(w, r, gb) {
+		return
+	}
+
+	h.track(r, gb)
+
+	guests, err := h.repo.FindAll(r.Context(), repository.FindAllParams{
+		GuestbookID: gb.ID,
+		Limit:       50,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find guests", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]guestRow, 0, len(guests))
+	for _, guest := range guests {
+		rows = append(rows, guestRow{Guest: guest})
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors *")
+	h.render(w, gb, "embed.html", embedPage{
+		Guestbook: gb,
+		Path:      basePath(gb),
+		Guests:    rows,
+	})
+}
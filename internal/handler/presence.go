@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Presence streams the number of people viewing the guestbook as server
+// sent events, counting the stream itself as a viewer while it's open.
+func (h *Guestbook) Presence(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.authorize(w, r, gb) {
+		return
+	}
+
+	viewerID := uuid.NewString()
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+		defer cancel()
+
+		h.presence.Leave(ctx, gb.ID, viewerID)
+	}()
+
+	ticker := time.NewTicker(h.presence.Interval())
+	defer ticker.Stop()
+
+	for {
+		count, err := h.presence.Heartbeat(r.Context(), gb.ID, viewerID)
+		if err != nil {
+			h.logger.Warn("failed to send heartbeat", slog.Any("error", err))
+		} else {
+			fmt.Fprintf(w, "event: presence\ndata: %d\n\n", count)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.presence.Closed():
+			return
+		case <-ticker.C:
+		}
+	}
+}
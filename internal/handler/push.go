@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dreamsofcode-io/guestbook/internal/push"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// maxPreviewLength is how much of a message is shown in a notification.
+const maxPreviewLength = 120
+
+// pushButton offers to turn on notifications. Visitors subscribe with the
+// token for the message they left, and owners without one.
+type pushButton struct {
+	Key   string
+	Token string
+	Label string
+}
+
+type subscribeRequest struct {
+	Subscription struct {
+		Endpoint string `json:"endpoint"`
+		Keys     struct {
+			P256dh string `json:"p256dh"`
+			Auth   string `json:"auth"`
+		} `json:"keys"`
+	} `json:"subscription"`
+	Token string `json:"token"`
+}
+
+// Subscribe stores a browser's push subscription. With a token for a
+// message it's subscribed to news of that message, and otherwise to the
+// new messages in the signed in account's guestbooks.
+func (h *Guestbook) Subscribe(w http.ResponseWriter, r *http.Request) {
+	if h.Push == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Only scripts on the site can send JSON without a preflight
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var req subscribeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sub, ok := parseSubscription(req)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if req.Token != "" {
+		guestID, ok := h.Push.VerifyGuestToken(req.Token)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		err = h.Push.SubscribeGuest(r.Context(), guestID, sub)
+	} else {
+		accountID, ok := h.sessions.AccountID(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		err = h.Push.SubscribeAccount(r.Context(), accountID, sub)
+	}
+
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to subscribe", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseSubscription checks a subscription is one the guestbook can send
+// to.
+func parseSubscription(req subscribeRequest) (push.Subscription, bool) {
+	u, err := url.Parse(req.Subscription.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return push.Subscription{}, false
+	}
+
+	p256dh, err := push.Decode(req.Subscription.Keys.P256dh)
+	if err != nil || len(p256dh) != 65 {
+		return push.Subscription{}, false
+	}
+
+	auth, err := push.Decode(req.Subscription.Keys.Auth)
+	if err != nil || len(auth) != 16 {
+		return push.Subscription{}, false
+	}
+
+	return push.Subscription{
+		Endpoint: req.Subscription.Endpoint,
+		P256dh:   p256dh,
+		Auth:     auth,
+	}, true
+}
+
+// ServiceWorker serves the script that shows notifications.
+func (h *Guestbook) ServiceWorker(w http.ResponseWriter, r *http.Request) {
+	if h.Push == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/javascript")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(push.ServiceWorker)
+}
+
+// ownerButton offers owners notifications of new messages, if push is
+// enabled.
+func (h *Guestbook) ownerButton() *pushButton {
+	if h.Push == nil {
+		return nil
+	}
+
+	return &pushButton{
+		Key:   h.Push.PublicKey(),
+		Label: "Notify me of new messages",
+	}
+}
+
+// heldButton offers the visitor who left a held message a notification
+// once it's approved, if the token for it is valid.
+func (h *Guestbook) heldButton(token string) *pushButton {
+	if h.Push == nil || token == "" {
+		return nil
+	}
+
+	if _, ok := h.Push.VerifyGuestToken(token); !ok {
+		return nil
+	}
+
+	return &pushButton{
+		Key:   h.Push.PublicKey(),
+		Token: token,
+		Label: "Notify me when it's approved",
+	}
+}
+
+// newMessageNotification tells the owner about a message. Private messages
+// and those held for review aren't previewed.
+func newMessageNotification(
+	gb repository.Guestbook, params repository.InsertParams,
+) push.Notification {
+	n := push.Notification{
+		Title: "New message in " + gb.Title,
+		Body:  preview(params.Message),
+		URL:   basePath(gb) + "/",
+	}
+
+	switch {
+	case params.Status == "pending":
+		n.Title = "Message held for review in " + gb.Title
+		n.Body = "A message is waiting for a moderator"
+	case params.Private:
+		n.Title = "New private note in " + gb.Title
+		n.Body = "Someone left you a private note"
+		n.URL = basePath(gb) + "/dashboard"
+	}
+
+	return n
+}
+
+// approvedNotification tells visitors their held messages were approved.
+func approvedNotification(slug string) push.Notification {
+	path := "/g/" + slug + "/"
+	if slug == defaultSlug {
+		path = "/"
+	}
+
+	return push.Notification{
+		Title: "Your message was approved",
+		Body:  "It's now shown in the guestbook",
+		URL:   path,
+	}
+}
+
+func preview(message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	if utf8.RuneCountInString(message) <= maxPreviewLength {
+		return message
+	}
+
+	runes := []rune(message)
+	return string(runes[:maxPreviewLength-1]) + "…"
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/dreamsofcode-io/guestbook/internal/quota"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// errQuotaExceeded is returned when a message would take the guestbook's
+// owner over one of their plan's limits.
+var errQuotaExceeded = errors.New("quota exceeded")
+
+func override(v pgtype.Int8) *int64 {
+	if !v.Valid {
+		return nil
+	}
+
+	return &v.Int64
+}
+
+// limits returns the limits that apply to the account, taking its
+// overrides into account.
+func limits(account repository.Account) quota.Limits {
+	return quota.Resolve(account.Plan, quota.Overrides{
+		Guestbooks:   override(account.QuotaGuestbooks),
+		Messages:     override(account.QuotaMessages),
+		StorageBytes: override(account.QuotaStorageBytes),
+		APICalls:     override(account.QuotaApiCalls),
+	})
+}
+
+// charge counts a new message against its guestbook owner's usage for
+// the current period, failing with errQuotaExceeded if it takes them over
+// a limit. It must be called in the transaction inserting the message, so
+// that the message is rolled back along with the usage.
+func charge(
+	ctx context.Context, repo *repository.Queries, ownerID uuid.UUID,
+	params repository.InsertParams,
+) error {
+	account, err := repo.FindAccount(ctx, ownerID)
+	if err != nil {
+		return fmt.Errorf("find owner: %w", err)
+	}
+
+	l := limits(account)
+	period := quota.Period(time.Now())
+
+	usage := map[quota.Metric]int64{
+		quota.Messages: 1,
+		quota.Storage: int64(
+			len(params.Message) + len(params.SealedMessage) +
+				len(params.Fields) + len(params.SealedFields),
+		),
+	}
+
+	for metric, n := range usage {
+		used, err := repo.IncrementUsage(ctx, repository.IncrementUsageParams{
+			AccountID: ownerID,
+			Period:    period,
+			Metric:    string(metric),
+			Amount:    n,
+		})
+		if err != nil {
+			return fmt.Errorf("increment %s: %w", metric, err)
+		}
+
+		if !quota.Allows(l.Metric(metric), used-n, n) {
+			return fmt.Errorf("%s: %w", metric, errQuotaExceeded)
+		}
+	}
+
+	return nil
+}
+
+// usage reports the account's usage in the current period against its
+// limits.
+func (h *Guestbook) usage(
+	ctx context.Context, account repository.Account,
+) ([]quota.Line, error) {
+	guestbooks, err := h.repo.CountGuestbooksByOwner(
+		ctx, uuid.NullUUID{UUID: account.ID, Valid: true},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("count guestbooks: %w", err)
+	}
+
+	rows, err := h.repo.FindUsage(ctx, repository.FindUsageParams{
+		AccountID: account.ID,
+		Period:    quota.Period(time.Now()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("find usage: %w", err)
+	}
+
+	used := map[quota.Metric]int64{}
+	for _, row := range rows {
+		used[quota.Metric(row.Metric)] = row.Amount
+	}
+
+	return quota.Report(limits(account), guestbooks, used), nil
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+type statsPage struct {
+	Guestbook repository.Guestbook
+	Path      string
+	Indexable bool
+	Total     int64
+	Tags      []repository.CountTagsRow
+	Traffic   []repository.GuestbookTraffic
+}
+
+// Stats shows a summary of the activity in a guestbook.
+func (h *Guestbook) Stats(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.authorize(w, r, gb) {
+		return
+	}
+
+	count, err := h.repo.Count(r.Context(), gb.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to get count", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	tags, err := h.repo.CountTags(r.Context(), repository.CountTagsParams{
+		GuestbookID: gb.ID,
+		Limit:       50,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to count tags", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	traffic, err := h.repo.FindTraffic(r.Context(), repository.FindTrafficParams{
+		GuestbookID: gb.ID,
+		Day:         time.Now().UTC().AddDate(0, 0, -30),
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find traffic", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	h.render(w, gb, "stats.html", statsPage{
+		Guestbook: gb,
+		Path:      basePath(gb),
+		Indexable: access.Visibility(gb.Visibility).Indexable(),
+		Total:     count,
+		Tags:      tags,
+		Traffic:   traffic,
+	})
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dreamsofcode-io/guestbook/internal/field"
+	"github.com/dreamsofcode-io/guestbook/internal/guest"
+	"github.com/dreamsofcode-io/guestbook/internal/plugin"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+	"github.com/dreamsofcode-io/guestbook/internal/tag"
+)
+
+// submission is a message left for a guestbook, through the form or by
+// email.
+type submission struct {
+	Message string
+	Private bool
+	// Field returns the value entered for a custom field by its input
+	// name.
+	Field       func(string) string
+	IP          net.IP
+	Fingerprint string
+	// Hold holds the message for review, whatever the plugins decide.
+	Hold bool
+}
+
+// rejection is returned when a submission isn't accepted, with the reason
+// shown to whoever left it.
+type rejection struct {
+	status  int
+	message string
+}
+
+func (e *rejection) Error() string {
+	return e.message
+}
+
+func reject(status int, message string) error {
+	return &rejection{status: status, message: message}
+}
+
+// prepare checks a submission against the guestbook's fields, blocked
+// tags, bans, filters and plugins, returning the guest to insert along
+// with its tags. Submissions that aren't accepted return a *rejection.
+func (h *Guestbook) prepare(
+	ctx context.Context, gb repository.Guestbook, s submission,
+) (repository.InsertParams, []string, error) {
+	message := s.Message
+
+	if strings.TrimSpace(message) == "" {
+		return repository.InsertParams{}, nil, reject(http.StatusBadRequest, "Blank messages don't count")
+	}
+
+	if s.Private && h.sealer == nil {
+		return repository.InsertParams{}, nil, reject(http.StatusBadRequest, "Private messages aren't available")
+	}
+
+	if s.Private && utf8.RuneCountInString(message) > maxMessageLength {
+		return repository.InsertParams{}, nil, reject(http.StatusBadRequest, "Your message is too long")
+	}
+
+	defs := h.definitions(gb)
+	values, err := field.Validate(defs, s.Field)
+
+	var invalid *field.ValidationError
+	if errors.As(err, &invalid) {
+		return repository.InsertParams{}, nil, reject(http.StatusBadRequest, invalid.Error())
+	}
+
+	// Tags aren't taken from private messages, as they're publicly listed
+	tags := []string{}
+	if !s.Private {
+		tags = tag.Parse(message)
+	}
+
+	blocked, err := h.blockedTag(ctx, gb, tags)
+	if err != nil {
+		return repository.InsertParams{}, nil, fmt.Errorf("check blocked tags: %w", err)
+	}
+
+	if blocked != "" {
+		return repository.InsertParams{}, nil, reject(
+			http.StatusBadRequest, fmt.Sprintf("The tag #%s isn't allowed here", blocked),
+		)
+	}
+
+	fields, err := json.Marshal(values)
+	if err != nil {
+		return repository.InsertParams{}, nil, fmt.Errorf("encode fields: %w", err)
+	}
+
+	banned, err := h.repo.IsBanned(ctx, s.IP)
+	if err != nil {
+		return repository.InsertParams{}, nil, fmt.Errorf("check ban: %w", err)
+	}
+
+	if banned {
+		return repository.InsertParams{}, nil, reject(
+			http.StatusForbidden, "You're not able to leave messages here",
+		)
+	}
+
+	for _, filter := range h.Filters {
+		if err := filter(message, s.IP); err != nil {
+			return repository.InsertParams{}, nil, reject(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	status := "approved"
+	if s.Hold {
+		status = "pending"
+	}
+
+	if h.Plugins != nil {
+		verdict := h.Plugins.Check(ctx, plugin.Input{
+			Message:     message,
+			Private:     s.Private,
+			IP:          s.IP.String(),
+			Fingerprint: s.Fingerprint,
+			Tags:        tags,
+			Fields:      field.Values(defs, fields),
+			Guestbook: plugin.Guestbook{
+				Slug:       gb.Slug,
+				Title:      gb.Title,
+				Visibility: gb.Visibility,
+				Theme:      gb.Theme,
+				Fields:     defs,
+			},
+		})
+
+		switch verdict.Action {
+		case plugin.Reject:
+			reason := verdict.Reason
+			if reason == "" {
+				reason = "Your message wasn't accepted"
+			}
+
+			return repository.InsertParams{}, nil, reject(http.StatusBadRequest, reason)
+		case plugin.Review:
+			h.logger.Info(
+				"message held for review",
+				slog.String("guestbook", gb.Slug), slog.String("plugin", verdict.Plugin),
+			)
+			status = "pending"
+		}
+	}
+
+	guest, err := guest.NewGuest(message, s.IP)
+	if err != nil {
+		return repository.InsertParams{}, nil, fmt.Errorf("create guest: %w", err)
+	}
+
+	params := repository.InsertParams{
+		ID:          guest.ID,
+		Message:     guest.Message,
+		CreatedAt:   guest.CreatedAt,
+		Ip:          guest.IP,
+		GuestbookID: gb.ID,
+		Fields:      fields,
+		Fingerprint: s.Fingerprint,
+		Status:      status,
+	}
+
+	// Private messages are only stored encrypted, bound to the guest's ID,
+	// along with their fields
+	if s.Private {
+		sealed, err := h.sealer.Seal([]byte(guest.Message), guest.ID[:])
+		if err != nil {
+			return repository.InsertParams{}, nil, fmt.Errorf("seal message: %w", err)
+		}
+
+		sealedFields, err := h.sealer.Seal(fields, fieldsData(guest.ID))
+		if err != nil {
+			return repository.InsertParams{}, nil, fmt.Errorf("seal fields: %w", err)
+		}
+
+		params.Message = ""
+		params.Private = true
+		params.SealedMessage = sealed
+		params.Fields = []byte("{}")
+		params.SealedFields = sealedFields
+	}
+
+	return params, tags, nil
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+	"github.com/dreamsofcode-io/guestbook/internal/field"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+	"github.com/dreamsofcode-io/guestbook/internal/tag"
+)
+
+type tagPage struct {
+	Guestbook repository.Guestbook
+	Path      string
+	Indexable bool
+	Tag       string
+	Guests    []guestRow
+}
+
+// Tag lists the messages in a guestbook that use a tag.
+func (h *Guestbook) Tag(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.authorize(w, r, gb) {
+		return
+	}
+
+	h.track(r, gb)
+
+	name, ok := tag.Normalize(r.PathValue("tag"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	blocked, err := h.repo.FindBlockedTags(r.Context(), gb.ID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find blocked tags", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if slices.Contains(blocked, name) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	guests, err := h.repo.FindAllByTag(r.Context(), repository.FindAllByTagParams{
+		GuestbookID: gb.ID,
+		Tag:         name,
+		Limit:       200,
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to find guests by tag", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	defs := h.definitions(gb)
+
+	rows := make([]guestRow, 0, len(guests))
+	for _, guest := range guests {
+		rows = append(rows, guestRow{
+			Guest:  guest,
+			Fields: field.Values(defs, guest.Fields),
+		})
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	h.render(w, gb, "tag.html", tagPage{
+		Guestbook: gb,
+		Path:      basePath(gb),
+		Indexable: access.Visibility(gb.Visibility).Indexable(),
+		Tag:       name,
+		Guests:    rows,
+	})
+}
+
+// BlockTag stops a tag from being used in new messages and hides it from
+// tag pages and stats.
+func (h *Guestbook) BlockTag(w http.ResponseWriter, r *http.Request) {
+	h.updateBlockedTag(w, r, func(gb repository.Guestbook, name string) error {
+		return h.repo.BlockTag(r.Context(), repository.BlockTagParams{
+			GuestbookID: gb.ID,
+			Tag:         name,
+		})
+	})
+}
+
+// UnblockTag allows a previously blocked tag to be used again.
+func (h *Guestbook) UnblockTag(w http.ResponseWriter, r *http.Request) {
+	h.updateBlockedTag(w, r, func(gb repository.Guestbook, name string) error {
+		return h.repo.UnblockTag(r.Context(), repository.UnblockTagParams{
+			GuestbookID: gb.ID,
+			Tag:         name,
+		})
+	})
+}
+
+func (h *Guestbook) updateBlockedTag(
+	w http.ResponseWriter, r *http.Request,
+	update func(gb repository.Guestbook, name string) error,
+) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	name, ok := tag.Normalize(r.PostForm.Get("tag"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := update(gb, name); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to update blocked tag", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/dashboard", http.StatusSeeOther)
+}
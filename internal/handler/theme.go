@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+	"github.com/dreamsofcode-io/guestbook/internal/theme"
+)
+
+// Stylesheet serves the guestbook's custom CSS to those allowed to view
+// the guestbook, as it can say as much about it as the page does. It's
+// sanitized again on the way out so that rules added since it was saved
+// still apply.
+func (h *Guestbook) Stylesheet(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.authorize(w, r, gb) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(theme.SanitizeCSS(gb.CustomCss)))
+}
+
+// UpdateTheme saves the guestbook's chosen theme and custom CSS.
+func (h *Guestbook) UpdateTheme(w http.ResponseWriter, r *http.Request) {
+	gb, ok := h.load(w, r)
+	if !ok || !h.requireOwner(w, r, gb) {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to parse form", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	name := r.PostForm.Get("theme")
+	if !h.themes.Has(name) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err := h.repo.UpdateGuestbookTheme(r.Context(), repository.UpdateGuestbookThemeParams{
+		ID:        gb.ID,
+		Theme:     name,
+		CustomCss: theme.SanitizeCSS(r.PostForm.Get("css")),
+	})
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to update theme", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, basePath(gb)+"/dashboard", http.StatusSeeOther)
+}
@@ -0,0 +1,4 @@
+// Package jobs runs background work queued in Postgres, so that it
+// survives restarts, is retried when it fails, and is shared between
+// replicas, with each job claimed by a single one.
+package jobs
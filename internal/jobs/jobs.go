@@ -0,0 +1,210 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+const (
+	// DefaultMaxAttempts is how many times a job is run before it's given
+	// up on.
+	DefaultMaxAttempts = 8
+
+	// DefaultLease is how long a job may run before it's considered
+	// abandoned, such as by a replica that stopped, and run again.
+	DefaultLease = time.Minute * 5
+
+	firstRetry = time.Second * 30
+	maxRetry   = time.Hour
+)
+
+// Store is the subset of queries the queue needs.
+type Store interface {
+	ClaimJob(ctx context.Context, leaseUntil time.Time) (repository.Job, error)
+	RetryJob(ctx context.Context, arg repository.RetryJobParams) error
+	DeleteJob(ctx context.Context, id int64) error
+}
+
+// Inserter is the query jobs are enqueued with, so they can be enqueued
+// in the same transaction as the change they follow from.
+type Inserter interface {
+	InsertJob(ctx context.Context, arg repository.InsertJobParams) error
+}
+
+// Enqueue queues a job of the kind, with the payload encoded as JSON, to
+// be run as soon as possible.
+func Enqueue(ctx context.Context, db Inserter, kind string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	err = db.InsertJob(ctx, repository.InsertJobParams{
+		Kind:    kind,
+		Payload: data,
+		RunAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	return nil
+}
+
+// Func runs a job with its payload. Returning an error runs it again
+// later, unless the error is Permanent.
+type Func func(ctx context.Context, payload []byte) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error as one retrying won't fix, so the job is given
+// up on straight away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Queue runs the jobs queued for the kinds it handles.
+type Queue struct {
+	logger   *slog.Logger
+	store    Store
+	handlers map[string]Func
+
+	MaxAttempts int
+	Lease       time.Duration
+}
+
+func New(logger *slog.Logger, store Store) *Queue {
+	return &Queue{
+		logger:      logger,
+		store:       store,
+		handlers:    map[string]Func{},
+		MaxAttempts: DefaultMaxAttempts,
+		Lease:       DefaultLease,
+	}
+}
+
+// Handle sets the function jobs of the kind are run with. It must be
+// called before the queue is run.
+func (q *Queue) Handle(kind string, fn Func) {
+	q.handlers[kind] = fn
+}
+
+// Run works through the queued jobs every interval until the context is
+// done.
+func (q *Queue) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				ran, err := q.Work(ctx)
+				if err != nil {
+					q.logger.Error("failed to run job", slog.Any("error", err))
+				}
+
+				if !ran || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Work claims and runs the next job that's due, reporting whether there
+// was one. A job that fails is retried with an exponential backoff,
+// until it's failed MaxAttempts times.
+func (q *Queue) Work(ctx context.Context) (bool, error) {
+	job, err := q.store.ClaimJob(ctx, time.Now().UTC().Add(q.Lease))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	fn, ok := q.handlers[job.Kind]
+	if !ok {
+		err = Permanent(fmt.Errorf("unknown job kind %q", job.Kind))
+	} else {
+		jobCtx, cancel := context.WithTimeout(ctx, q.Lease)
+		err = fn(jobCtx, job.Payload)
+		cancel()
+	}
+
+	if err == nil {
+		if err := q.store.DeleteJob(ctx, job.ID); err != nil {
+			return true, fmt.Errorf("failed to delete job: %w", err)
+		}
+
+		return true, nil
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) || int(job.Attempts) >= q.MaxAttempts {
+		q.logger.Error(
+			"giving up on job",
+			slog.String("kind", job.Kind),
+			slog.Int64("id", job.ID),
+			slog.Int("attempts", int(job.Attempts)),
+			slog.Any("error", err),
+		)
+
+		if err := q.store.DeleteJob(ctx, job.ID); err != nil {
+			return true, fmt.Errorf("failed to delete job: %w", err)
+		}
+
+		return true, nil
+	}
+
+	q.logger.Warn(
+		"job failed, retrying",
+		slog.String("kind", job.Kind),
+		slog.Int64("id", job.ID),
+		slog.Int("attempts", int(job.Attempts)),
+		slog.Any("error", err),
+	)
+
+	err = q.store.RetryJob(ctx, repository.RetryJobParams{
+		ID:        job.ID,
+		RunAt:     time.Now().UTC().Add(Backoff(int(job.Attempts))),
+		LastError: err.Error(),
+	})
+	if err != nil {
+		return true, fmt.Errorf("failed to retry job: %w", err)
+	}
+
+	return true, nil
+}
+
+// Backoff returns how long to wait before running a job again after it's
+// failed the number of attempts: 30 seconds, doubling each time up to an
+// hour.
+func Backoff(attempts int) time.Duration {
+	delay := firstRetry
+	for i := 1; i < attempts && delay < maxRetry; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetry)
+}
@@ -0,0 +1,194 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dreamsofcode-io/guestbook/internal/jobs"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// memoryStore queues jobs in memory, in the order they were inserted.
+type memoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   []repository.Job
+}
+
+func (s *memoryStore) InsertJob(ctx context.Context, arg repository.InsertJobParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	s.jobs = append(s.jobs, repository.Job{
+		ID:        s.nextID,
+		Kind:      arg.Kind,
+		Payload:   arg.Payload,
+		RunAt:     arg.RunAt,
+		CreatedAt: time.Now(),
+	})
+
+	return nil
+}
+
+func (s *memoryStore) ClaimJob(ctx context.Context, leaseUntil time.Time) (repository.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, job := range s.jobs {
+		if job.RunAt.After(time.Now()) {
+			continue
+		}
+
+		s.jobs[i].RunAt = leaseUntil
+		s.jobs[i].Attempts++
+
+		return s.jobs[i], nil
+	}
+
+	return repository.Job{}, pgx.ErrNoRows
+}
+
+func (s *memoryStore) RetryJob(ctx context.Context, arg repository.RetryJobParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, job := range s.jobs {
+		if job.ID == arg.ID {
+			s.jobs[i].RunAt = arg.RunAt
+			s.jobs[i].LastError = arg.LastError
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) DeleteJob(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, job := range s.jobs {
+		if job.ID == id {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// due makes every queued job due now, as if its retry delay had passed.
+func (s *memoryStore) due() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.jobs {
+		s.jobs[i].RunAt = time.Now()
+	}
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestWork(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Kind        string
+		Err         error
+		Attempts    int
+		Remaining   int
+		LastError   string
+	}{
+		{
+			Description: "success",
+			Kind:        "greet",
+			Attempts:    1,
+			Remaining:   0,
+		},
+		{
+			Description: "failure is retried",
+			Kind:        "greet",
+			Err:         errors.New("unavailable"),
+			Attempts:    1,
+			Remaining:   1,
+			LastError:   "unavailable",
+		},
+		{
+			Description: "failure is given up on after the last attempt",
+			Kind:        "greet",
+			Err:         errors.New("unavailable"),
+			Attempts:    3,
+			Remaining:   0,
+		},
+		{
+			Description: "permanent failure isn't retried",
+			Kind:        "greet",
+			Err:         jobs.Permanent(errors.New("no such guest")),
+			Attempts:    1,
+			Remaining:   0,
+		},
+		{
+			Description: "unknown kind isn't retried",
+			Kind:        "unknown",
+			Attempts:    1,
+			Remaining:   0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			ctx := context.Background()
+			store := &memoryStore{}
+
+			queue := jobs.New(discard, store)
+			queue.MaxAttempts = 3
+
+			var payloads []string
+			queue.Handle("greet", func(ctx context.Context, payload []byte) error {
+				payloads = append(payloads, string(payload))
+				return tc.Err
+			})
+
+			require.NoError(t, jobs.Enqueue(ctx, store, tc.Kind, map[string]string{"name": "dreams"}))
+
+			for range tc.Attempts {
+				store.due()
+
+				ran, err := queue.Work(ctx)
+				require.NoError(t, err)
+				assert.True(t, ran)
+			}
+
+			ran, err := queue.Work(ctx)
+			assert.NoError(t, err)
+			assert.False(t, ran)
+
+			require.Len(t, store.jobs, tc.Remaining)
+			if tc.Remaining > 0 {
+				assert.Equal(t, tc.LastError, store.jobs[0].LastError)
+				assert.True(t, store.jobs[0].RunAt.After(time.Now()))
+			}
+
+			if tc.Kind == "greet" {
+				assert.Len(t, payloads, tc.Attempts)
+				assert.Equal(t, `{"name":"dreams"}`, payloads[0])
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second*30, jobs.Backoff(1))
+	assert.Equal(t, time.Minute, jobs.Backoff(2))
+	assert.Equal(t, time.Minute*16, jobs.Backoff(6))
+	assert.Equal(t, time.Hour, jobs.Backoff(8))
+	assert.Equal(t, time.Hour, jobs.Backoff(100))
+}
@@ -0,0 +1,10 @@
+// Package listener creates the sockets the server listens on, so that
+// restarts don't drop connections.
+//
+// Sockets passed in by systemd socket activation, through LISTEN_FDS, are
+// used in place of listening anew whenever their address matches the one
+// asked for. The same mechanism hands the sockets over to a new process
+// for a graceful upgrade: Upgrade starts the executable again with the
+// sockets, and waits until it reports being ready before the old process
+// stops accepting connections and drains the ones it has.
+package listener
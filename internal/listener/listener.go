@@ -0,0 +1,373 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// firstFD is the first descriptor passed, after stdin, stdout and stderr.
+const firstFD = 3
+
+// readyEnv holds the descriptor a process started by Upgrade reports being
+// ready on.
+const readyEnv = "LISTEN_READY_FD"
+
+// ErrUpgrading is returned by Upgrade while an upgrade is already under
+// way.
+var ErrUpgrading = errors.New("upgrade already in progress")
+
+// filer is implemented by the listeners and connections that can be
+// passed on to another process.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Set is the sockets a process listens on, including any it inherited.
+// It's safe for concurrent use.
+type Set struct {
+	mu        sync.Mutex
+	inherited []*os.File
+	active    []filer
+	ready     *os.File
+	upgrading bool
+}
+
+// Inherit creates a Set with the sockets passed to the process, either by
+// systemd or by the process that started it through Upgrade, and removes
+// the variables describing them from the environment so they aren't
+// passed on to any other process.
+//
+// As in systemd, the sockets start at descriptor 3 and LISTEN_FDS is how
+// many there are. When LISTEN_PID is set it must match the process, which
+// it isn't for sockets passed by Upgrade, as the pid isn't known before
+// the process starts.
+func Inherit() (*Set, error) {
+	defer func() {
+		for _, env := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", readyEnv} {
+			os.Unsetenv(env)
+		}
+	}()
+
+	s := &Set{}
+
+	if fd := os.Getenv(readyEnv); fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil || n < firstFD {
+			return nil, fmt.Errorf("invalid %s: %q", readyEnv, fd)
+		}
+
+		s.ready = os.NewFile(uintptr(n), "ready")
+	}
+
+	fds := os.Getenv("LISTEN_FDS")
+	if fds == "" {
+		return s, nil
+	}
+
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return s, nil
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS: %q", fds)
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := range n {
+		name := "inherited"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		s.inherited = append(s.inherited, os.NewFile(uintptr(firstFD+i), name))
+	}
+
+	return s, nil
+}
+
+// Listen returns a stream listener on the address, such as for TCP, using
+// an inherited socket if one matches.
+func (s *Set) Listen(network, addr string) (net.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.inherited {
+		if f == nil {
+			continue
+		}
+
+		l, err := net.FileListener(f)
+		if err != nil {
+			continue
+		}
+
+		if !matches(network, addr, l.Addr()) {
+			l.Close()
+			continue
+		}
+
+		s.take(i)
+		s.active = append(s.active, l.(filer))
+
+		return l, nil
+	}
+
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.active = append(s.active, l.(filer))
+
+	return l, nil
+}
+
+// ListenPacket returns a packet listener on the address, such as for UDP,
+// using an inherited socket if one matches.
+func (s *Set) ListenPacket(network, addr string) (net.PacketConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.inherited {
+		if f == nil {
+			continue
+		}
+
+		conn, err := net.FilePacketConn(f)
+		if err != nil {
+			continue
+		}
+
+		if !matches(network, addr, conn.LocalAddr()) {
+			conn.Close()
+			continue
+		}
+
+		s.take(i)
+		s.active = append(s.active, conn.(filer))
+
+		return conn, nil
+	}
+
+	conn, err := net.ListenPacket(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.active = append(s.active, conn.(filer))
+
+	return conn, nil
+}
+
+// take closes the inherited file once it's been turned into a listener,
+// which holds a descriptor of its own.
+func (s *Set) take(i int) {
+	s.inherited[i].Close()
+	s.inherited[i] = nil
+}
+
+// Ready reports to the process that started this one through Upgrade, if
+// any, that it's listening and the old process can stop.
+func (s *Set) Ready() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ready == nil {
+		return nil
+	}
+
+	defer func() {
+		s.ready.Close()
+		s.ready = nil
+	}()
+
+	if _, err := s.ready.Write([]byte{1}); err != nil {
+		return fmt.Errorf("failed to report ready: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes any inherited sockets that weren't used.
+func (s *Set) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.inherited {
+		if f != nil {
+			f.Close()
+			s.inherited[i] = nil
+		}
+	}
+
+	return nil
+}
+
+// Upgrade starts the executable again with the same arguments, passing it
+// the sockets being listened on, and waits until it's ready. Once it
+// returns without an error, the caller should stop accepting connections
+// and drain those it has. If the new process fails to become ready before
+// the context is done, it's killed.
+func (s *Set) Upgrade(ctx context.Context) error {
+	s.mu.Lock()
+	if s.upgrading {
+		s.mu.Unlock()
+		return ErrUpgrading
+	}
+
+	s.upgrading = true
+
+	files := make([]*os.File, 0, len(s.active))
+	for _, l := range s.active {
+		f, err := l.File()
+		if err != nil {
+			s.mu.Unlock()
+			closeAll(files)
+			s.finishUpgrade()
+			return fmt.Errorf("failed to get listener file: %w", err)
+		}
+
+		files = append(files, f)
+	}
+	s.mu.Unlock()
+
+	defer s.finishUpgrade()
+	defer closeAll(files)
+
+	path, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create ready pipe: %w", err)
+	}
+	defer r.Close()
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(
+		environ(),
+		"LISTEN_FDS="+strconv.Itoa(len(files)),
+		readyEnv+"="+strconv.Itoa(firstFD+len(files)),
+	)
+
+	err = cmd.Start()
+	w.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		if errors.Is(err, io.EOF) {
+			err = errors.New("new process exited before it was ready")
+		}
+
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+
+		return fmt.Errorf("failed to upgrade: %w", err)
+	}
+
+	// The new process outlives this one, so it's never waited on.
+	cmd.Process.Release()
+
+	return nil
+}
+
+func (s *Set) finishUpgrade() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.upgrading = false
+}
+
+func closeAll(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// environ returns the environment without the variables describing
+// inherited sockets.
+func environ() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		switch name {
+		case "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", readyEnv:
+			continue
+		}
+
+		env = append(env, kv)
+	}
+
+	return env
+}
+
+// matches reports whether a socket bound to got satisfies a request to
+// listen on the network and address, such as :8080 being satisfied by a
+// socket on [::]:8080.
+func matches(network, addr string, got net.Addr) bool {
+	if !strings.HasPrefix(got.Network(), strings.TrimRight(network, "46")) {
+		return false
+	}
+
+	var wantIP, gotIP net.IP
+	var wantPort, gotPort int
+
+	switch got := got.(type) {
+	case *net.TCPAddr:
+		want, err := net.ResolveTCPAddr(network, addr)
+		if err != nil {
+			return false
+		}
+
+		wantIP, wantPort, gotIP, gotPort = want.IP, want.Port, got.IP, got.Port
+	case *net.UDPAddr:
+		want, err := net.ResolveUDPAddr(network, addr)
+		if err != nil {
+			return false
+		}
+
+		wantIP, wantPort, gotIP, gotPort = want.IP, want.Port, got.IP, got.Port
+	default:
+		return false
+	}
+
+	if wantPort != gotPort {
+		return false
+	}
+
+	if wantIP == nil || wantIP.IsUnspecified() {
+		return gotIP.IsUnspecified()
+	}
+
+	return wantIP.Equal(gotIP)
+}
@@ -0,0 +1,126 @@
+package listener_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dreamsofcode-io/guestbook/internal/listener"
+)
+
+// TestMain runs the test binary as the new process when it's started by
+// Upgrade.
+func TestMain(m *testing.M) {
+	switch os.Getenv("LISTENER_TEST_CHILD") {
+	case "serve":
+		os.Exit(child())
+	case "fail":
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
+}
+
+// child listens on the address it's given, which fails unless it reuses
+// the inherited socket, then answers a single connection.
+func child() int {
+	s, err := listener.Inherit()
+	if err != nil {
+		return 1
+	}
+
+	l, err := s.Listen("tcp", os.Getenv("LISTENER_TEST_ADDR"))
+	if err != nil {
+		return 1
+	}
+
+	if err := s.Ready(); err != nil {
+		return 1
+	}
+
+	conn, err := l.Accept()
+	if err != nil {
+		return 1
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("new process"))
+
+	return 0
+}
+
+func TestUpgrade(t *testing.T) {
+	s, err := listener.Inherit()
+	require.NoError(t, err)
+	defer s.Close()
+
+	l, err := s.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Setenv("LISTENER_TEST_CHILD", "serve")
+	t.Setenv("LISTENER_TEST_ADDR", l.Addr().String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	require.NoError(t, s.Upgrade(ctx))
+
+	// Connections are only accepted by the new process once this one stops
+	require.NoError(t, l.Close())
+	assert.Equal(t, "new process", read(t, l.Addr().String()))
+}
+
+// read connects to the address and reads until it's closed.
+func read(t *testing.T, addr string) string {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second * 10))
+
+	data, err := io.ReadAll(conn)
+	assert.NoError(t, err)
+
+	return string(data)
+}
+
+func TestUpgradeFailure(t *testing.T) {
+	s, err := listener.Inherit()
+	require.NoError(t, err)
+	defer s.Close()
+
+	l, err := s.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Setenv("LISTENER_TEST_CHILD", "fail")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	err = s.Upgrade(ctx)
+	assert.EqualError(t, err, "failed to upgrade: new process exited before it was ready")
+
+	// The listener is kept, so it can be upgraded again
+	t.Setenv("LISTENER_TEST_CHILD", "serve")
+	t.Setenv("LISTENER_TEST_ADDR", l.Addr().String())
+	require.NoError(t, s.Upgrade(ctx))
+
+	require.NoError(t, l.Close())
+	assert.Equal(t, "new process", read(t, l.Addr().String()))
+}
+
+func TestReady(t *testing.T) {
+	s, err := listener.Inherit()
+	require.NoError(t, err)
+
+	// Without a process waiting, there's nobody to tell
+	assert.NoError(t, s.Ready())
+}
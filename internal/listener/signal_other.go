@@ -0,0 +1,9 @@
+//go:build !unix
+
+package listener
+
+import "os"
+
+// NotifyUpgrade does nothing, as there's no signal for upgrades outside
+// of unix.
+func NotifyUpgrade(c chan<- os.Signal) {}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package listener
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// NotifyUpgrade relays SIGUSR2, the signal asking for an upgrade, to c.
+func NotifyUpgrade(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR2)
+}
@@ -0,0 +1,3 @@
+// Package mailtext extracts the plain text someone wrote from an email,
+// decoding its MIME structure and dropping quoted replies and signatures.
+package mailtext
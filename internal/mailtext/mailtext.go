@@ -0,0 +1,214 @@
+package mailtext
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// maxDepth is how deeply multipart messages may nest.
+const maxDepth = 5
+
+var (
+	// ErrNoText is returned for messages without a plain text part.
+	ErrNoText = errors.New("mailtext: message has no plain text")
+
+	// ErrCharset is returned for text in a character set that isn't
+	// supported.
+	ErrCharset = errors.New("mailtext: unsupported character set")
+)
+
+// Message is what was written in an email.
+type Message struct {
+	// From is the address in the From header, which unlike the envelope
+	// sender is what the author sees.
+	From    string
+	Subject string
+	// Text is the body, without quoted replies or the signature.
+	Text string
+}
+
+var decoder = mime.WordDecoder{CharsetReader: charsetReader}
+
+// Parse extracts the text from a message.
+func Parse(data []byte) (Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return Message{}, fmt.Errorf("mailtext: invalid message: %w", err)
+	}
+
+	var m Message
+
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		m.From = from.Address
+	}
+
+	m.Subject, err = decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		m.Subject = msg.Header.Get("Subject")
+	}
+
+	text, err := plainText(msg.Header, msg.Body, 0)
+	if err != nil {
+		return Message{}, err
+	}
+
+	m.Text = Clean(text)
+
+	return m, nil
+}
+
+// header is what's needed of a message's or part's header.
+type header interface {
+	Get(key string) string
+}
+
+// plainText returns the first plain text in the entity that isn't an
+// attachment.
+func plainText(h header, body io.Reader, depth int) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		// Without a valid type, it's taken as plain text per RFC 2045
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxDepth {
+			return "", ErrNoText
+		}
+
+		return multipartText(body, params["boundary"], depth)
+	}
+
+	if mediaType != "text/plain" {
+		return "", ErrNoText
+	}
+
+	data, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return "", fmt.Errorf("mailtext: failed to decode body: %w", err)
+	}
+
+	return decodeCharset(params["charset"], data)
+}
+
+func multipartText(body io.Reader, boundary string, depth int) (string, error) {
+	if boundary == "" {
+		return "", ErrNoText
+	}
+
+	r := multipart.NewReader(body, boundary)
+	for {
+		part, err := r.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return "", ErrNoText
+		} else if err != nil {
+			return "", fmt.Errorf("mailtext: invalid multipart message: %w", err)
+		}
+
+		disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		if disposition == "attachment" {
+			continue
+		}
+
+		text, err := plainText(part.Header, part, depth+1)
+		if errors.Is(err, ErrNoText) {
+			continue
+		}
+
+		return text, err
+	}
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	return body
+}
+
+// decodeCharset converts text to UTF-8. Besides UTF-8 itself, only Latin-1
+// is decoded, which covers the mail clients that don't send UTF-8.
+func decodeCharset(charset string, data []byte) (string, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return strings.ToValidUTF8(string(data), "�"), nil
+	case "iso-8859-1", "latin1":
+		return latin1(data), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrCharset, charset)
+}
+
+func latin1(data []byte) string {
+	var b strings.Builder
+	b.Grow(len(data))
+
+	for _, c := range data {
+		b.WriteRune(rune(c))
+	}
+
+	return b.String()
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := decodeCharset(charset, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.NewReader(text), nil
+}
+
+// Clean drops the quoted reply, with the line introducing it, and the
+// signature from text, and collapses it onto a single line.
+func Clean(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var kept []string
+	for i, line := range lines {
+		if line == "-- " || line == "--" {
+			break
+		}
+
+		if strings.HasPrefix(line, ">") || introducesQuote(lines[i:]) {
+			continue
+		}
+
+		kept = append(kept, line)
+	}
+
+	return strings.Join(strings.Fields(strings.Join(kept, " ")), " ")
+}
+
+// introducesQuote reports whether the first line is one like "On Monday,
+// Guest wrote:" followed by a quote.
+func introducesQuote(lines []string) bool {
+	if !strings.HasSuffix(strings.TrimSpace(lines[0]), ":") {
+		return false
+	}
+
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) != "" {
+			return strings.HasPrefix(line, ">")
+		}
+	}
+
+	return false
+}
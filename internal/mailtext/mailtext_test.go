@@ -0,0 +1,146 @@
+package mailtext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/mailtext"
+)
+
+// crlf joins lines as they're sent over SMTP.
+func crlf(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Message     []byte
+		Expected    mailtext.Message
+		ExpectedErr error
+	}{
+		{
+			Description: "plain text",
+			Message: crlf(
+				"From: Grandma <grandma@example.com>",
+				"Subject: Congratulations",
+				"",
+				"What a lovely",
+				"wedding!",
+			),
+			Expected: mailtext.Message{
+				From:    "grandma@example.com",
+				Subject: "Congratulations",
+				Text:    "What a lovely wedding!",
+			},
+		},
+		{
+			Description: "reply with signature",
+			Message: crlf(
+				"From: grandma@example.com",
+				"Subject: Re: Our guestbook",
+				"",
+				"Thank you for having us.",
+				"",
+				"On Sat, 12 Oct 2024, Host <party@guestbook.example.com> wrote:",
+				"> You can leave a message by replying",
+				"",
+				"-- ",
+				"Sent from my phone",
+			),
+			Expected: mailtext.Message{
+				From:    "grandma@example.com",
+				Subject: "Re: Our guestbook",
+				Text:    "Thank you for having us.",
+			},
+		},
+		{
+			Description: "alternative with quoted-printable text",
+			Message: crlf(
+				"From: grandma@example.com",
+				"Subject: =?UTF-8?Q?Sch=C3=B6n?=",
+				"MIME-Version: 1.0",
+				`Content-Type: multipart/alternative; boundary="b1"`,
+				"",
+				"--b1",
+				`Content-Type: text/plain; charset="utf-8"`,
+				"Content-Transfer-Encoding: quoted-printable",
+				"",
+				"Sch=C3=B6ne Feier, vielen Dank f=",
+				"=C3=BCr alles!",
+				"--b1",
+				`Content-Type: text/html; charset="utf-8"`,
+				"",
+				"<p>Sch&ouml;ne Feier</p>",
+				"--b1--",
+			),
+			Expected: mailtext.Message{
+				From:    "grandma@example.com",
+				Subject: "Schön",
+				Text:    "Schöne Feier, vielen Dank für alles!",
+			},
+		},
+		{
+			Description: "mixed with an attachment first",
+			Message: crlf(
+				"From: grandma@example.com",
+				`Content-Type: multipart/mixed; boundary="outer"`,
+				"",
+				"--outer",
+				"Content-Type: text/plain",
+				"Content-Disposition: attachment; filename=notes.txt",
+				"",
+				"Not this",
+				"--outer",
+				`Content-Type: multipart/alternative; boundary="inner"`,
+				"",
+				"--inner",
+				"Content-Type: text/plain; charset=iso-8859-1",
+				"Content-Transfer-Encoding: base64",
+				"",
+				"Qm9uIGFubml2ZXJzYWlyZSwgYuli6Q==",
+				"--inner--",
+				"--outer--",
+			),
+			Expected: mailtext.Message{
+				From: "grandma@example.com",
+				Text: "Bon anniversaire, bébé",
+			},
+		},
+		{
+			Description: "html only",
+			Message: crlf(
+				"From: grandma@example.com",
+				"Content-Type: text/html",
+				"",
+				"<p>Hello</p>",
+			),
+			ExpectedErr: mailtext.ErrNoText,
+		},
+		{
+			Description: "unsupported charset",
+			Message: crlf(
+				"From: grandma@example.com",
+				"Content-Type: text/plain; charset=koi8-r",
+				"",
+				"Hello",
+			),
+			ExpectedErr: mailtext.ErrCharset,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			msg, err := mailtext.Parse(tc.Message)
+			if tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, msg)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package maintenance records whether the site is down for maintenance. The
+// state is kept in redis, so every instance, and every service checked
+// through the forward auth endpoint, sees the same state.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const key = "maintenance"
+
+// DefaultMessage is shown to visitors when maintenance is enabled without
+// a message.
+const DefaultMessage = "Down for maintenance, back soon."
+
+// Status is whether maintenance is under way, and the message to show
+// visitors in the meantime.
+type Status struct {
+	Enabled bool
+	Message string
+}
+
+// Mode reads and changes the maintenance status.
+type Mode struct {
+	store redis.Cmdable
+}
+
+// New creates a Mode stored in redis.
+func New(store redis.Cmdable) *Mode {
+	return &Mode{store: store}
+}
+
+// Status returns the current maintenance status.
+func (m *Mode) Status(ctx context.Context) (Status, error) {
+	message, err := m.store.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return Status{}, nil
+	} else if err != nil {
+		return Status{}, fmt.Errorf("failed to get maintenance status: %w", err)
+	}
+
+	if message == "" {
+		message = DefaultMessage
+	}
+
+	return Status{Enabled: true, Message: message}, nil
+}
+
+// Set changes the maintenance status.
+func (m *Mode) Set(ctx context.Context, status Status) error {
+	var err error
+	if status.Enabled {
+		err = m.store.Set(ctx, key, status.Message, 0).Err()
+	} else {
+		err = m.store.Del(ctx, key).Err()
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to set maintenance status: %w", err)
+	}
+
+	return nil
+}
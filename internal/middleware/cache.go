@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CachePolicy describes how responses to a class of routes may be cached
+// by browsers and shared caches such as a CDN.
+type CachePolicy struct {
+	// Control is the Cache-Control header sent with successful responses.
+	// Other responses are always sent with no-store.
+	Control string
+	// Vary lists the request headers that can change the response.
+	Vary []string
+	// Keys returns the surrogate keys for a response, so a CDN can purge
+	// every cached response about the same thing at once.
+	Keys func(r *http.Request) []string
+}
+
+var (
+	// NoStore is for forms, dashboards and anything else personal or
+	// changed by a request.
+	NoStore = CachePolicy{Control: "no-store"}
+	// Listing is for public pages that change as messages are left, which
+	// shared caches may keep for a short while. Browsers always revalidate.
+	Listing = CachePolicy{
+		Control: "public, max-age=0, s-maxage=60, stale-while-revalidate=30",
+		Vary:    []string{"Cookie"},
+	}
+	// Asset is for static files that can change between deploys.
+	Asset = CachePolicy{Control: "public, max-age=3600"}
+	// Immutable is for fingerprinted assets, whose URL changes whenever
+	// their content does.
+	Immutable = CachePolicy{Control: "public, max-age=31536000, immutable"}
+)
+
+// WithKeys returns a copy of the policy using keys for surrogate keys.
+func (p CachePolicy) WithKeys(keys func(r *http.Request) []string) CachePolicy {
+	p.Keys = keys
+	return p
+}
+
+// Middleware sets the policy's headers on the response. A Cache-Control
+// header set by the handler itself is left alone.
+func (p CachePolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, header := range p.Vary {
+			w.Header().Add("Vary", header)
+		}
+
+		if p.Keys != nil {
+			if keys := p.Keys(r); len(keys) > 0 {
+				w.Header().Set("Surrogate-Key", strings.Join(keys, " "))
+			}
+		}
+
+		cw := &cacheWriter{ResponseWriter: w, control: p.Control}
+		next.ServeHTTP(cw, r)
+
+		// A handler that writes nothing still sends an implicit 200.
+		if !cw.written {
+			cw.setControl(http.StatusOK)
+		}
+	})
+}
+
+// Fingerprinted uses Immutable for requests that name a version of the
+// resource with a v query parameter, and the fallback policy otherwise.
+func Fingerprinted(fallback CachePolicy) Middleware {
+	immutable := fallback
+	immutable.Control = Immutable.Control
+
+	return func(next http.Handler) http.Handler {
+		versioned := immutable.Middleware(next)
+		unversioned := fallback.Middleware(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("v") != "" {
+				versioned.ServeHTTP(w, r)
+				return
+			}
+
+			unversioned.ServeHTTP(w, r)
+		})
+	}
+}
+
+// cacheWriter sets the Cache-Control header once the status is known.
+type cacheWriter struct {
+	http.ResponseWriter
+	control string
+	written bool
+}
+
+func (w *cacheWriter) WriteHeader(statusCode int) {
+	if !w.written {
+		w.written = true
+		w.setControl(statusCode)
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the headers before flushing, as flushing through Unwrap
+// would skip WriteHeader.
+func (w *cacheWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *cacheWriter) setControl(statusCode int) {
+	header := w.Header()
+	if header.Get("Cache-Control") != "" {
+		return
+	}
+
+	if statusCode >= http.StatusMultipleChoices && statusCode != http.StatusNotModified {
+		header.Set("Cache-Control", NoStore.Control)
+		return
+	}
+
+	header.Set("Cache-Control", w.control)
+}
@@ -0,0 +1,130 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+)
+
+func TestCachePolicy(t *testing.T) {
+	keys := func(r *http.Request) []string {
+		return []string{"guestbook:" + r.URL.Query().Get("slug"), "listing"}
+	}
+
+	testCases := []struct {
+		Description     string
+		Policy          middleware.CachePolicy
+		Target          string
+		Handler         http.HandlerFunc
+		ExpectedControl string
+		ExpectedVary    []string
+		ExpectedKeys    string
+	}{
+		{
+			Description:     "no store",
+			Policy:          middleware.NoStore,
+			Target:          "/",
+			Handler:         func(w http.ResponseWriter, r *http.Request) {},
+			ExpectedControl: "no-store",
+		},
+		{
+			Description: "listing with keys",
+			Policy:      middleware.Listing.WithKeys(keys),
+			Target:      "/?slug=party",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello"))
+			},
+			ExpectedControl: middleware.Listing.Control,
+			ExpectedVary:    []string{"Cookie"},
+			ExpectedKeys:    "guestbook:party listing",
+		},
+		{
+			Description: "errors are not cached",
+			Policy:      middleware.Immutable,
+			Target:      "/",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			ExpectedControl: "no-store",
+		},
+		{
+			Description: "redirects are not cached",
+			Policy:      middleware.Listing,
+			Target:      "/",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/access", http.StatusSeeOther)
+			},
+			ExpectedControl: "no-store",
+			ExpectedVary:    []string{"Cookie"},
+		},
+		{
+			Description: "handler overrides policy",
+			Policy:      middleware.Listing,
+			Target:      "/",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "private, no-store")
+				w.Write([]byte("secret"))
+			},
+			ExpectedControl: "private, no-store",
+			ExpectedVary:    []string{"Cookie"},
+		},
+		{
+			Description: "flushed responses",
+			Policy:      middleware.Asset,
+			Target:      "/",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NewResponseController(w).Flush()
+			},
+			ExpectedControl: middleware.Asset.Control,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tc.Target, nil)
+
+			tc.Policy.Middleware(tc.Handler).ServeHTTP(w, req)
+
+			assert.Equal(t, tc.ExpectedControl, w.Result().Header.Get("Cache-Control"))
+			assert.Equal(t, tc.ExpectedVary, w.Result().Header.Values("Vary"))
+			assert.Equal(t, tc.ExpectedKeys, w.Result().Header.Get("Surrogate-Key"))
+		})
+	}
+}
+
+func TestFingerprinted(t *testing.T) {
+	handler := middleware.Fingerprinted(middleware.Asset)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	testCases := []struct {
+		Description     string
+		Target          string
+		ExpectedControl string
+	}{
+		{
+			Description:     "versioned",
+			Target:          "/theme.css?v=1700000000",
+			ExpectedControl: middleware.Immutable.Control,
+		},
+		{
+			Description:     "unversioned",
+			Target:          "/theme.css",
+			ExpectedControl: middleware.Asset.Control,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", tc.Target, nil))
+
+			assert.Equal(t, tc.ExpectedControl, w.Result().Header.Get("Cache-Control"))
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+)
+
+// record returns a middleware that appends its name to the trace on the
+// way in and out of the handler.
+func record(trace *[]string, name string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name+" in")
+			next.ServeHTTP(w, r)
+			*trace = append(*trace, name+" out")
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	})
+
+	chain := middleware.NewChain(record(&trace, "a"), record(&trace, "b"))
+	chain.Append(record(&trace, "c")).Then(handler).ServeHTTP(
+		httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil),
+	)
+
+	assert.Equal(t, []string{
+		"a in", "b in", "c in", "handler", "c out", "b out", "a out",
+	}, trace)
+}
+
+func TestChainAppendDoesNotModify(t *testing.T) {
+	var trace []string
+
+	base := middleware.NewChain(record(&trace, "a"))
+	first := base.Append(record(&trace, "b"))
+	second := base.Append(record(&trace, "c"))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	first.Then(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, []string{"a in", "b in", "b out", "a out"}, trace)
+
+	trace = nil
+	second.Then(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, []string{"a in", "c in", "c out", "a out"}, trace)
+
+	assert.Len(t, base, 1)
+}
+
+func TestEmptyChain(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	middleware.NewChain().ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
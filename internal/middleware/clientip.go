@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// Proxies are the networks of the reverse proxies in front of the server,
+// which are trusted to report the client they forward a request for in
+// X-Forwarded-For. Any other hop could have been made up by the client.
+type Proxies []netip.Prefix
+
+// Trusts reports whether the address is one of the proxies.
+func (p Proxies) Trusts(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve returns the request with its client's address resolved, for
+// ClientIP to return. Starting from the peer the request came from, each
+// hop that's a trusted proxy is replaced by the address it added to
+// X-Forwarded-For, so the first hop that isn't a proxy is the client.
+// Addresses the client put in the header itself are never reached.
+func (p Proxies) Resolve(r *http.Request) *http.Request {
+	ctx := context.WithValue(r.Context(), clientIPKey{}, p.clientIP(r))
+	return r.WithContext(ctx)
+}
+
+// Middleware resolves the client's address once for each request, so
+// everything handling it agrees on who the client is.
+func (p Proxies) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, p.Resolve(r))
+	})
+}
+
+func (p Proxies) clientIP(r *http.Request) string {
+	client := peerIP(r)
+
+	addr, err := netip.ParseAddr(client)
+	if err != nil || !p.Trusts(addr) {
+		return client
+	}
+
+	hops := forwardedFor(r)
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := parseHop(hops[i])
+		if err != nil {
+			// Nothing before a hop that can't be read can be trusted
+			return client
+		}
+
+		client = addr.String()
+		if !p.Trusts(addr) {
+			return client
+		}
+	}
+
+	return client
+}
+
+// ClientIP returns the address of the client that made the request, as
+// resolved by Proxies. A request that wasn't resolved is taken to come
+// directly from the client.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+
+	return peerIP(r)
+}
+
+// peerIP returns the address of the peer the request came from.
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+
+	return host
+}
+
+// forwardedFor returns every hop listed in X-Forwarded-For, in order,
+// across all of the header's lines.
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+
+	for _, line := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(line, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	return hops
+}
+
+// parseHop parses an address from X-Forwarded-For, which some proxies add
+// a port to.
+func parseHop(hop string) (netip.Addr, error) {
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap(), nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(hop)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	return addrPort.Addr().Unmap(), nil
+}
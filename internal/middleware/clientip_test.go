@@ -0,0 +1,105 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := middleware.Proxies{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	testCases := []struct {
+		Description string
+		RemoteAddr  string
+		Forwarded   []string
+		Expected    string
+	}{
+		{
+			Description: "direct",
+			RemoteAddr:  "203.0.113.7:1234",
+			Expected:    "203.0.113.7",
+		},
+		{
+			Description: "direct with a made up header",
+			RemoteAddr:  "203.0.113.7:1234",
+			Forwarded:   []string{"198.51.100.1"},
+			Expected:    "203.0.113.7",
+		},
+		{
+			Description: "through a proxy",
+			RemoteAddr:  "10.0.0.2:1234",
+			Forwarded:   []string{"203.0.113.7"},
+			Expected:    "203.0.113.7",
+		},
+		{
+			Description: "client adding its own hops",
+			RemoteAddr:  "10.0.0.2:1234",
+			Forwarded:   []string{"198.51.100.1, 10.0.0.9, 203.0.113.7"},
+			Expected:    "203.0.113.7",
+		},
+		{
+			Description: "through several proxies",
+			RemoteAddr:  "192.0.2.1:1234",
+			Forwarded:   []string{"198.51.100.1", "203.0.113.7, 10.0.0.3"},
+			Expected:    "203.0.113.7",
+		},
+		{
+			Description: "ipv6",
+			RemoteAddr:  "[fd00::1]:1234",
+			Forwarded:   []string{"2001:db8::7"},
+			Expected:    "2001:db8::7",
+		},
+		{
+			Description: "hop with a port",
+			RemoteAddr:  "10.0.0.2:1234",
+			Forwarded:   []string{"[2001:db8::7]:5678"},
+			Expected:    "2001:db8::7",
+		},
+		{
+			Description: "unreadable hop",
+			RemoteAddr:  "10.0.0.2:1234",
+			Forwarded:   []string{"203.0.113.7, unknown"},
+			Expected:    "10.0.0.2",
+		},
+		{
+			Description: "only proxies",
+			RemoteAddr:  "10.0.0.2:1234",
+			Expected:    "10.0.0.2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.RemoteAddr
+			for _, value := range tc.Forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			var got string
+			proxies.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = middleware.ClientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tc.Expected, got)
+		})
+	}
+}
+
+func TestClientIPUnresolved(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[2001:db8::7]:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	assert.Equal(t, "2001:db8::7", middleware.ClientIP(req))
+}
@@ -17,6 +17,12 @@ func (w *wrappedWriter) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 }
 
+// Unwrap allows http.ResponseController to reach the underlying writer,
+// so handlers can still flush streamed responses.
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 type errorPage struct {
 	StatusCode    int
 	StatusMessage string
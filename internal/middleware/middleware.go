@@ -1,7 +1,59 @@
 package middleware
 
-import "net/http"
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+)
 
 // Middleware represents the type signature of a middleware
 // function.
 type Middleware func(http.Handler) http.Handler
+
+// Chain is an ordered list of middleware. The first middleware in the
+// chain is the outermost, so it sees the request first and the response
+// last.
+type Chain []Middleware
+
+// NewChain creates a chain of the given middleware, in order.
+func NewChain(middleware ...Middleware) Chain {
+	return append(Chain(nil), middleware...)
+}
+
+// Append returns a new chain with the middleware added to the end, so
+// they run inside the existing middleware. The original chain is left
+// unchanged.
+func (c Chain) Append(middleware ...Middleware) Chain {
+	chain := make(Chain, 0, len(c)+len(middleware))
+	chain = append(chain, c...)
+
+	return append(chain, middleware...)
+}
+
+// Then wraps the handler in the chain's middleware.
+func (c Chain) Then(h http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+
+	return h
+}
+
+// ThenFunc wraps the handler function in the chain's middleware.
+func (c Chain) ThenFunc(f http.HandlerFunc) http.Handler {
+	return c.Then(f)
+}
+
+// WithLogging returns Logging as a Middleware.
+func WithLogging(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return Logging(logger, next)
+	}
+}
+
+// WithErrorPages returns HandleBadCode as a Middleware.
+func WithErrorPages(tmpl *template.Template) Middleware {
+	return func(next http.Handler) http.Handler {
+		return HandleBadCode(tmpl, next)
+	}
+}
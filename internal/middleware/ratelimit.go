@@ -1,11 +1,10 @@
 package middleware
 
 import (
+	"context"
 	"math"
 	"net/http"
-	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -14,67 +13,89 @@ import (
 type RateLimiter struct {
 	Period  time.Duration
 	MaxRate int64
-	Store   *redis.Client
+	Store   redis.Cmdable
+
+	// OnLimit, if set, is called with each request that is rejected for
+	// exceeding the rate.
+	OnLimit func(r *http.Request)
+}
+
+// Usage describes how much of a rate limit has been used. The zero Usage
+// means the usage isn't known.
+type Usage struct {
+	Used   int64
+	Limit  int64
+	Resets time.Duration
 }
 
-var re = regexp.MustCompile(`\s?,\s?`)
+// WriteHeaders writes the rate limit headers for the usage, if it's known.
+func (u Usage) WriteHeaders(w http.ResponseWriter) {
+	if u.Used == 0 {
+		return
+	}
 
-func (rl *RateLimiter) writeRateLimitHeaders(
-	w http.ResponseWriter,
-	used int64,
-	expireTime time.Duration,
-) {
-	limit := rl.MaxRate
-	remaining := int64(math.Max(float64(limit-used), 0))
-	reset := int64(math.Ceil(expireTime.Seconds()))
+	remaining := int64(math.Max(float64(u.Limit-u.Used), 0))
+	reset := int64(math.Ceil(u.Resets.Seconds()))
 
-	w.Header().Add("X-RateLimit-Limit", strconv.FormatInt(limit, 10))
+	w.Header().Add("X-RateLimit-Limit", strconv.FormatInt(u.Limit, 10))
 	w.Header().Add("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
 	w.Header().Add("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
 }
 
-func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Obtain the clientIP from the XFF header
-		clientIP := re.Split(r.Header.Get("X-Forwarded-For"), -1)[0]
+// Allow records an event for the key and reports whether the key is still
+// within the rate. If the store can't be reached the event is allowed, and
+// no usage is known.
+func (rl *RateLimiter) Allow(ctx context.Context, key string) (Usage, bool) {
+	// Get the current time to use for the event
+	now := time.Now()
+
+	// Add the current event to the store
+	rl.Store.ZAdd(ctx, key, redis.Z{
+		Member: now.UnixMicro(),
+		Score:  float64(now.UnixMicro()),
+	})
 
-		// If the xff header is empty, obtain the IP from the remoteAddr
-		if clientIP == "" {
-			parts := strings.Split(r.RemoteAddr, ":")
-			clientIP = strings.Join(parts[0:len(parts)-1], ":")
-		}
+	// Calculate the cutoff
+	cutoff := now.Add(rl.Period * -1).UnixMicro()
 
-		// Get the current time to use for the event
-		now := time.Now()
+	// Remove all events that are before the cutoff
+	rl.Store.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(cutoff, 10))
 
-		// Add the current event to the store
-		rl.Store.ZAdd(r.Context(), clientIP, redis.Z{
-			Member: now.UnixMicro(),
-			Score:  float64(now.UnixMicro()),
-		})
+	// Pull the remaining events from the sorted set
+	events, _ := rl.Store.ZRange(ctx, key, 0, -1).Result()
 
-		// Calculate the cutoff
-		cutoff := now.Add(rl.Period * -1).UnixMicro()
+	// If the store couldn't be reached, don't turn everyone away
+	if len(events) == 0 {
+		return Usage{}, true
+	}
 
-		// Remove all events that are before the cutoff
-		rl.Store.ZRemRangeByScore(r.Context(), clientIP, "-inf", strconv.FormatInt(cutoff, 10))
+	// Get the earliest event time
+	earliestMicro, _ := strconv.ParseInt(events[0], 10, 64)
+	earliest := time.UnixMicro(earliestMicro)
 
-		// Pull the remaining events from the sorted set
-		events, _ := rl.Store.ZRange(r.Context(), clientIP, 0, -1).Result()
+	// Calculate how long until it resets and how many events have occurred
+	usage := Usage{
+		Used:   int64(len(events)),
+		Limit:  rl.MaxRate,
+		Resets: rl.Period - time.Since(earliest),
+	}
 
-		// Get the earliest event time
-		earliestMicro, _ := strconv.ParseInt(events[0], 10, 64)
-		earliest := time.UnixMicro(earliestMicro)
+	return usage, usage.Used <= rl.MaxRate
+}
 
-		// Calculate how long until it resets and how many events have occurred
-		resets := rl.Period - time.Since(earliest)
-		eventCount := int64(len(events))
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usage, allowed := rl.Allow(r.Context(), ClientIP(r))
 
 		// write the rate limit headers
-		rl.writeRateLimitHeaders(w, eventCount, resets)
+		usage.WriteHeaders(w)
 
 		// Check if client has exceeded the max rate
-		if eventCount > rl.MaxRate {
+		if !allowed {
+			if rl.OnLimit != nil {
+				rl.OnLimit(r)
+			}
+
 			w.WriteHeader(http.StatusTooManyRequests)
 			return
 		}
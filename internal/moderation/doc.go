@@ -0,0 +1,20 @@
+// Package moderation implements the search syntax moderators use to find
+// messages across every guestbook, and the bulk actions they can apply to
+// the messages a search matches.
+//
+// A search is a list of space separated terms, all of which must match:
+//
+//	ip:203.0.113.0/24   messages from an address or network
+//	before:2024-08-10   messages left before the start of a day (UTC)
+//	after:2024-08-01    messages left on or after the start of a day (UTC)
+//	status:approved     messages with a status of pending, approved or rejected
+//	guestbook:my-slug   messages left in a guestbook
+//	fingerprint:0a1b…   messages left from a browser fingerprint
+//	tag:raid            messages using a hashtag
+//	private:true        private or public messages
+//	"buy now"           messages containing a phrase, ignoring case
+//
+// Any term can be negated by prefixing it with a minus, such as
+// -status:rejected. Values containing spaces can be quoted, as in
+// guestbook:"my-slug".
+package moderation
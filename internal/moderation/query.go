@@ -0,0 +1,248 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dreamsofcode-io/guestbook/internal/fingerprint"
+	"github.com/dreamsofcode-io/guestbook/internal/tag"
+)
+
+// ErrEmpty is returned when parsing a search without any terms.
+var ErrEmpty = errors.New("empty search")
+
+// dateLayout is the layout of the before and after terms.
+const dateLayout = "2006-01-02"
+
+// Statuses are the statuses a message can have.
+var Statuses = []string{"pending", "approved", "rejected"}
+
+// Term is a single condition of a search. Text terms have no key.
+type Term struct {
+	Key     string
+	Value   string
+	Negated bool
+
+	// arg is the value in the form it's passed to the database
+	arg any
+}
+
+// Query is a parsed search.
+type Query struct {
+	Terms []Term
+}
+
+// Parse parses a search, validating each of its terms.
+func Parse(s string) (Query, error) {
+	var q Query
+
+	r := []rune(s)
+	for i := 0; i < len(r); {
+		if unicode.IsSpace(r[i]) {
+			i++
+			continue
+		}
+
+		var t Term
+		if r[i] == '-' {
+			t.Negated = true
+			i++
+		}
+
+		// A key is a run of letters followed by a colon
+		j := i
+		for j < len(r) && r[j] >= 'a' && r[j] <= 'z' {
+			j++
+		}
+
+		if j > i && j < len(r) && r[j] == ':' {
+			t.Key = string(r[i:j])
+			i = j + 1
+		}
+
+		value, next, err := readValue(r, i)
+		if err != nil {
+			return Query{}, err
+		}
+		i = next
+
+		if value == "" {
+			return Query{}, fmt.Errorf("missing value at position %d", i)
+		}
+
+		t.Value = value
+		if t.arg, err = argument(t.Key, value); err != nil {
+			return Query{}, err
+		}
+
+		q.Terms = append(q.Terms, t)
+	}
+
+	if len(q.Terms) == 0 {
+		return Query{}, ErrEmpty
+	}
+
+	return q, nil
+}
+
+// readValue reads a quoted or unquoted value starting at i, returning it
+// along with the position after it.
+func readValue(r []rune, i int) (string, int, error) {
+	if i < len(r) && r[i] == '"' {
+		end := i + 1
+		for end < len(r) && r[end] != '"' {
+			end++
+		}
+
+		if end == len(r) {
+			return "", 0, fmt.Errorf("unterminated quote at position %d", i)
+		}
+
+		return string(r[i+1 : end]), end + 1, nil
+	}
+
+	end := i
+	for end < len(r) && !unicode.IsSpace(r[end]) {
+		end++
+	}
+
+	return string(r[i:end]), end, nil
+}
+
+// argument validates the value of a term, converting it to the form it's
+// passed to the database in.
+func argument(key string, value string) (any, error) {
+	switch key {
+	case "":
+		return "%" + escapeLike(value) + "%", nil
+	case "ip":
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			return prefix.Masked().String(), nil
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip %q", value)
+		}
+
+		return addr.String(), nil
+	case "before", "after":
+		day, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+		}
+
+		return day, nil
+	case "status":
+		for _, status := range Statuses {
+			if value == status {
+				return value, nil
+			}
+		}
+
+		return nil, fmt.Errorf("invalid status %q", value)
+	case "guestbook":
+		return value, nil
+	case "fingerprint":
+		if !fingerprint.Valid(value) {
+			return nil, fmt.Errorf("invalid fingerprint %q", value)
+		}
+
+		return value, nil
+	case "tag":
+		t, ok := tag.Normalize(strings.TrimPrefix(value, "#"))
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q", value)
+		}
+
+		return t, nil
+	case "private":
+		private, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for private", value)
+		}
+
+		return private, nil
+	}
+
+	return nil, fmt.Errorf("unknown search key %q", key)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// String formats the query in its canonical form.
+func (q Query) String() string {
+	terms := make([]string, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		var b strings.Builder
+		if t.Negated {
+			b.WriteByte('-')
+		}
+
+		if t.Key != "" {
+			b.WriteString(t.Key + ":")
+		}
+
+		if t.Key == "" || strings.ContainsFunc(t.Value, unicode.IsSpace) {
+			b.WriteString(`"` + t.Value + `"`)
+		} else {
+			b.WriteString(t.Value)
+		}
+
+		terms = append(terms, b.String())
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// Where compiles the query to a condition on the guest and guestbook
+// tables. Its placeholders are numbered from len(args)+1, and the
+// returned arguments are args with the query's arguments appended.
+func (q Query) Where(args []any) (string, []any) {
+	conds := make([]string, 0, len(q.Terms))
+
+	for _, t := range q.Terms {
+		args = append(args, t.arg)
+		p := "$" + strconv.Itoa(len(args))
+
+		var cond string
+		switch t.Key {
+		case "":
+			// Private messages are stored sealed, and moderators never
+			// search what they say
+			cond = "NOT guest.private AND guest.message ILIKE " + p + ` ESCAPE '\'`
+		case "ip":
+			cond = "guest.ip <<= " + p + "::inet"
+		case "before":
+			cond = "guest.created_at < " + p
+		case "after":
+			cond = "guest.created_at >= " + p
+		case "status":
+			cond = "guest.status = " + p
+		case "guestbook":
+			cond = "guestbook.slug = " + p
+		case "fingerprint":
+			cond = "guest.fingerprint = " + p
+		case "tag":
+			cond = "EXISTS (SELECT 1 FROM guest_tag WHERE guest_tag.guest_id = guest.id AND guest_tag.tag = " + p + ")"
+		case "private":
+			cond = "guest.private = " + p
+		}
+
+		if t.Negated {
+			cond = "NOT (" + cond + ")"
+		}
+
+		conds = append(conds, cond)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,127 @@
+package moderation_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/moderation"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		Description   string
+		Input         string
+		ExpectedWhere string
+		ExpectedArgs  []any
+		ExpectedErr   string
+	}{
+		{
+			Description:   "every key",
+			Input:         `ip:203.0.113.7/24 before:2024-08-10 status:approved "buy now"`,
+			ExpectedWhere: `guest.ip <<= $1::inet AND guest.created_at < $2 AND guest.status = $3 AND NOT guest.private AND guest.message ILIKE $4 ESCAPE '\'`,
+			ExpectedArgs: []any{
+				"203.0.113.0/24",
+				time.Date(2024, time.August, 10, 0, 0, 0, 0, time.UTC),
+				"approved",
+				"%buy now%",
+			},
+		},
+		{
+			Description:   "negated terms",
+			Input:         `-status:rejected -#raid tag:#Raid private:false`,
+			ExpectedWhere: `NOT (guest.status = $1) AND NOT (NOT guest.private AND guest.message ILIKE $2 ESCAPE '\') AND EXISTS (SELECT 1 FROM guest_tag WHERE guest_tag.guest_id = guest.id AND guest_tag.tag = $3) AND guest.private = $4`,
+			ExpectedArgs:  []any{"rejected", "%#raid%", "raid", false},
+		},
+		{
+			Description:   "wildcards are escaped",
+			Input:         `100%_off guestbook:"my-book"`,
+			ExpectedWhere: `NOT guest.private AND guest.message ILIKE $1 ESCAPE '\' AND guestbook.slug = $2`,
+			ExpectedArgs:  []any{`%100\%\_off%`, "my-book"},
+		},
+		{
+			Description:   "single address",
+			Input:         `ip:2001:db8::1`,
+			ExpectedWhere: `guest.ip <<= $1::inet`,
+			ExpectedArgs:  []any{"2001:db8::1"},
+		},
+		{
+			Description:   "fingerprint",
+			Input:         `fingerprint:0123456789abcdef0123456789abcdef`,
+			ExpectedWhere: `guest.fingerprint = $1`,
+			ExpectedArgs:  []any{"0123456789abcdef0123456789abcdef"},
+		},
+		{
+			Description: "invalid fingerprint",
+			Input:       "fingerprint:abc",
+			ExpectedErr: `invalid fingerprint "abc"`,
+		},
+		{
+			Description: "empty",
+			Input:       "   ",
+			ExpectedErr: "empty search",
+		},
+		{
+			Description: "unknown key",
+			Input:       "color:red",
+			ExpectedErr: `unknown search key "color"`,
+		},
+		{
+			Description: "invalid ip",
+			Input:       "ip:not-an-ip",
+			ExpectedErr: `invalid ip "not-an-ip"`,
+		},
+		{
+			Description: "invalid date",
+			Input:       "before:10/08/2024",
+			ExpectedErr: `invalid date "10/08/2024", expected YYYY-MM-DD`,
+		},
+		{
+			Description: "invalid status",
+			Input:       "status:deleted",
+			ExpectedErr: `invalid status "deleted"`,
+		},
+		{
+			Description: "unterminated quote",
+			Input:       `"buy now`,
+			ExpectedErr: "unterminated quote at position 0",
+		},
+		{
+			Description: "missing value",
+			Input:       "status:",
+			ExpectedErr: "missing value at position 7",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			q, err := moderation.Parse(tc.Input)
+			if tc.ExpectedErr != "" {
+				assert.EqualError(t, err, tc.ExpectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			where, args := q.Where(nil)
+			assert.Equal(t, tc.ExpectedWhere, where)
+			assert.Equal(t, tc.ExpectedArgs, args)
+		})
+	}
+}
+
+func TestWherePlaceholders(t *testing.T) {
+	q, err := moderation.Parse("status:pending")
+	assert.NoError(t, err)
+
+	where, args := q.Where([]any{"rejected"})
+	assert.Equal(t, "guest.status = $2", where)
+	assert.Equal(t, []any{"rejected", "pending"}, args)
+}
+
+func TestString(t *testing.T) {
+	q, err := moderation.Parse(`  -status:rejected   buy guestbook:"a b" `)
+	assert.NoError(t, err)
+	assert.Equal(t, `-status:rejected "buy" guestbook:"a b"`, q.String())
+}
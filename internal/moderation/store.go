@@ -0,0 +1,185 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// Action is a bulk action applied to every message a search matches.
+type Action string
+
+const (
+	Approve Action = "approve"
+	Reject  Action = "reject"
+	Delete  Action = "delete"
+	// Ban bans the address of every matching message, and rejects them.
+	Ban Action = "ban"
+)
+
+// ParseAction converts a submitted action into an Action.
+func ParseAction(value string) (Action, error) {
+	switch a := Action(value); a {
+	case Approve, Reject, Delete, Ban:
+		return a, nil
+	}
+
+	return "", fmt.Errorf("unknown action %q", value)
+}
+
+// Match is a message matched by a search.
+type Match struct {
+	repository.Guest
+	Slug string
+}
+
+const from = `
+FROM guest
+JOIN guestbook ON guestbook.id = guest.guestbook_id
+WHERE `
+
+// Find returns the most recent messages matching the query.
+func Find(
+	ctx context.Context, db repository.DBTX, q Query, limit int32,
+) ([]Match, error) {
+	where, args := q.Where([]any{limit})
+
+	rows, err := db.Query(ctx, `
+SELECT guest.id, guest.message, guest.ip, guest.created_at, guest.updated_at,
+  guest.guestbook_id, guest.private, guest.sealed_message, guest.fields,
+  guest.status, guest.fingerprint, guest.sealed_fields, guestbook.slug`+from+where+`
+ORDER BY guest.created_at DESC
+LIMIT $1`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []Match
+	for rows.Next() {
+		var m Match
+		if err := rows.Scan(
+			&m.ID,
+			&m.Message,
+			&m.Ip,
+			&m.CreatedAt,
+			&m.UpdatedAt,
+			&m.GuestbookID,
+			&m.Private,
+			&m.SealedMessage,
+			&m.Fields,
+			&m.Status,
+			&m.Fingerprint,
+			&m.SealedFields,
+			&m.Slug,
+		); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		matches = append(matches, m)
+	}
+
+	return matches, rows.Err()
+}
+
+// Count returns the number of messages matching the query.
+func Count(ctx context.Context, db repository.DBTX, q Query) (int64, error) {
+	where, args := q.Where(nil)
+
+	var count int64
+	err := db.QueryRow(ctx, "SELECT COUNT(*)"+from+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+
+	return count, nil
+}
+
+// Pending returns the IDs of the held messages matching the query, by the
+// slug of their guestbook, so that their authors can be told once they're
+// approved. It should be run in the same transaction as Apply.
+func Pending(
+	ctx context.Context, db repository.DBTX, q Query,
+) (map[string][]uuid.UUID, error) {
+	where, args := q.Where(nil)
+
+	rows, err := db.Query(ctx, `
+SELECT guest.id, guestbook.slug`+from+where+` AND guest.status = 'pending'`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("find pending: %w", err)
+	}
+	defer rows.Close()
+
+	pending := map[string][]uuid.UUID{}
+	for rows.Next() {
+		var (
+			id   uuid.UUID
+			slug string
+		)
+		if err := rows.Scan(&id, &slug); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		pending[slug] = append(pending[slug], id)
+	}
+
+	return pending, rows.Err()
+}
+
+// Apply applies the action to every message matching the query, returning
+// how many messages were affected. It should be run in a transaction
+// along with the audit record of the action.
+func Apply(
+	ctx context.Context, db repository.DBTX, q Query, action Action,
+) (int64, error) {
+	switch action {
+	case Approve:
+		return setStatus(ctx, db, q, "approved")
+	case Reject:
+		return setStatus(ctx, db, q, "rejected")
+	case Delete:
+		where, args := q.Where(nil)
+
+		tag, err := db.Exec(ctx, `
+DELETE FROM guest
+USING guestbook
+WHERE guestbook.id = guest.guestbook_id AND `+where, args...)
+		if err != nil {
+			return 0, fmt.Errorf("delete: %w", err)
+		}
+
+		return tag.RowsAffected(), nil
+	case Ban:
+		where, args := q.Where([]any{"banned by search: " + q.String()})
+
+		_, err := db.Exec(ctx, `
+INSERT INTO ban (network, reason, created_at)
+SELECT DISTINCT guest.ip, $1, now()`+from+where+`
+ON CONFLICT DO NOTHING`, args...)
+		if err != nil {
+			return 0, fmt.Errorf("ban: %w", err)
+		}
+
+		return setStatus(ctx, db, q, "rejected")
+	}
+
+	return 0, fmt.Errorf("unknown action %q", action)
+}
+
+func setStatus(
+	ctx context.Context, db repository.DBTX, q Query, status string,
+) (int64, error) {
+	where, args := q.Where([]any{status})
+
+	tag, err := db.Exec(ctx, `
+UPDATE guest
+SET status = $1, updated_at = now()
+FROM guestbook
+WHERE guestbook.id = guest.guestbook_id AND `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("update status: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,27 @@
+// Package plugin runs content filters compiled to WebAssembly, so that
+// moderation logic can be added without changing the guestbook.
+//
+// Each *.wasm file in the plugins directory is a filter. A filter exports
+// its memory along with two functions:
+//
+//	alloc(size i32) -> i32          reserves size bytes for the input
+//	filter(ptr i32, len i32) -> i64 checks the input, returning the verdict
+//
+// The input is written to the memory returned by alloc as JSON describing
+// the message, who left it and the guestbook's settings (see Input). The
+// result of filter packs the pointer to the verdict into its high 32 bits
+// and its length into the low 32, where the verdict is JSON such as:
+//
+//	{"action": "reject", "reason": "Links aren't allowed"}
+//
+// The action is one of allow, reject or review, with review holding the
+// message for a moderator. Filters may import env.log(ptr i32, len i32)
+// to write a line to the guestbook's log, and nothing else.
+//
+// Filters run on wazero, a WebAssembly runtime written in pure Go, which
+// validates each module as it's loaded. Every check runs in a fresh
+// instance, limited in the memory it uses and the time it takes, and it's
+// stopped as soon as its time is up. A filter that fails, by trapping or
+// going over a limit, holds the message for review rather than letting it
+// through. Files are reloaded as they change.
+package plugin
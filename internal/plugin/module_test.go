@@ -0,0 +1,183 @@
+package plugin_test
+
+// The filters the tests run are assembled here, so that they don't need a
+// compiler or checked in binaries.
+
+// valueType is a WebAssembly value type, as encoded in the binary format.
+type valueType byte
+
+const (
+	i32 valueType = 0x7F
+	i64 valueType = 0x7E
+)
+
+// wasmImport is a function imported from the host.
+type wasmImport struct {
+	Module  string
+	Name    string
+	Params  []valueType
+	Results []valueType
+}
+
+// wasmFunc is a function defined by the module. Its index follows the
+// imports.
+type wasmFunc struct {
+	Params  []valueType
+	Results []valueType
+
+	// Body is the function's code, without the final end.
+	Body []byte
+
+	// Export, if set, exports the function under the name.
+	Export string
+}
+
+// wasmMemory is the module's memory, exported as "memory".
+type wasmMemory struct {
+	Min uint32
+}
+
+// wasmData is a segment copied into memory when the module is
+// instantiated.
+type wasmData struct {
+	Offset uint32
+	Bytes  []byte
+}
+
+// wasmModule describes a module to assemble.
+type wasmModule struct {
+	Imports []wasmImport
+	Funcs   []wasmFunc
+	Memory  *wasmMemory
+	Data    []wasmData
+}
+
+// Bytes returns the module in the binary format.
+func (m wasmModule) Bytes() []byte {
+	var types, imports, funcs, exports, code [][]byte
+
+	for i, imp := range m.Imports {
+		types = append(types, funcType(imp.Params, imp.Results))
+		imports = append(imports, concat(
+			name(imp.Module), name(imp.Name), []byte{0x00}, u32(uint32(i)),
+		))
+	}
+
+	for i, fn := range m.Funcs {
+		idx := uint32(len(m.Imports) + i)
+
+		types = append(types, funcType(fn.Params, fn.Results))
+		funcs = append(funcs, u32(idx))
+
+		if fn.Export != "" {
+			exports = append(exports, concat(name(fn.Export), []byte{0x00}, u32(idx)))
+		}
+
+		// No locals
+		body := concat([]byte{0x00}, fn.Body, []byte{0x0B})
+		code = append(code, concat(u32(uint32(len(body))), body))
+	}
+
+	b := []byte{0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00}
+	b = append(b, section(1, vec(types))...)
+
+	if len(imports) > 0 {
+		b = append(b, section(2, vec(imports))...)
+	}
+
+	b = append(b, section(3, vec(funcs))...)
+
+	if m.Memory != nil {
+		limits := concat([]byte{0x00}, u32(m.Memory.Min))
+		b = append(b, section(5, vec([][]byte{limits}))...)
+		exports = append(exports, concat(name("memory"), []byte{0x02, 0x00}))
+	}
+
+	b = append(b, section(7, vec(exports))...)
+	b = append(b, section(10, vec(code))...)
+
+	if len(m.Data) > 0 {
+		var data [][]byte
+		for _, d := range m.Data {
+			data = append(data, concat(
+				[]byte{0x00}, i32Const(int32(d.Offset)), []byte{0x0B},
+				u32(uint32(len(d.Bytes))), d.Bytes,
+			))
+		}
+
+		b = append(b, section(11, vec(data))...)
+	}
+
+	return b
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+
+	return b
+}
+
+// u32 encodes an unsigned immediate, such as an index.
+func u32(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+
+		b = append(b, c|0x80)
+	}
+}
+
+// i32Const encodes an i32.const instruction.
+func i32Const(v int32) []byte {
+	return append([]byte{0x41}, signed(int64(v))...)
+}
+
+// i64Const encodes an i64.const instruction.
+func i64Const(v int64) []byte {
+	return append([]byte{0x42}, signed(v)...)
+}
+
+func signed(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+
+		b = append(b, c|0x80)
+	}
+}
+
+func section(id byte, body []byte) []byte {
+	return concat([]byte{id}, u32(uint32(len(body))), body)
+}
+
+func vec(items [][]byte) []byte {
+	return concat(u32(uint32(len(items))), concat(items...))
+}
+
+func name(s string) []byte {
+	return concat(u32(uint32(len(s))), []byte(s))
+}
+
+func funcType(params, results []valueType) []byte {
+	return concat([]byte{0x60}, valueTypes(params), valueTypes(results))
+}
+
+func valueTypes(types []valueType) []byte {
+	b := u32(uint32(len(types)))
+	for _, t := range types {
+		b = append(b, byte(t))
+	}
+
+	return b
+}
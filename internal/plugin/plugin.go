@@ -0,0 +1,457 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/dreamsofcode-io/guestbook/internal/field"
+)
+
+// Action is what a filter decides to do with a message.
+type Action string
+
+const (
+	Allow  Action = "allow"
+	Reject Action = "reject"
+	Review Action = "review"
+)
+
+// Verdict is a filter's decision on a message.
+type Verdict struct {
+	Action Action `json:"action"`
+	Reason string `json:"reason,omitempty"`
+
+	// Plugin is the name of the filter the verdict came from.
+	Plugin string `json:"-"`
+}
+
+// Guestbook is the settings of the guestbook a message is left in.
+type Guestbook struct {
+	Slug       string             `json:"slug"`
+	Title      string             `json:"title"`
+	Visibility string             `json:"visibility"`
+	Theme      string             `json:"theme"`
+	Fields     []field.Definition `json:"fields"`
+}
+
+// Input is what filters are given to check.
+type Input struct {
+	Message     string        `json:"message"`
+	Private     bool          `json:"private"`
+	IP          string        `json:"ip"`
+	Fingerprint string        `json:"fingerprint"`
+	Tags        []string      `json:"tags"`
+	Fields      []field.Value `json:"fields"`
+	Guestbook   Guestbook     `json:"guestbook"`
+}
+
+// Limits bounds each check made by a filter.
+type Limits struct {
+	// MemoryPages is the most 64KiB pages of memory a check may use.
+	MemoryPages uint32
+
+	// Timeout is how long a check may take, after which it's stopped.
+	Timeout time.Duration
+}
+
+// DefaultLimits allows for a quarter of a second of work in 16MiB of
+// memory.
+var DefaultLimits = Limits{
+	MemoryPages: 256,
+	Timeout:     time.Millisecond * 250,
+}
+
+// maxLogLine is the most of a line logged through env.log that's kept.
+const maxLogLine = 1024
+
+// ErrInvalidPlugin is returned, wrapped, for a module that doesn't
+// implement the filter interface.
+var ErrInvalidPlugin = errors.New("plugin: invalid plugin")
+
+// signature is the parameter and result types of a function.
+type signature struct {
+	params  []api.ValueType
+	results []api.ValueType
+}
+
+// exports are the functions a filter must export, with their types.
+var exports = map[string]signature{
+	"alloc":  {params: []api.ValueType{api.ValueTypeI32}, results: []api.ValueType{api.ValueTypeI32}},
+	"filter": {params: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, results: []api.ValueType{api.ValueTypeI64}},
+}
+
+// pluginKey holds the name of the filter running a check on its context.
+type pluginKey struct{}
+
+type plugin struct {
+	name    string
+	module  wazero.CompiledModule
+	modTime time.Time
+	size    int64
+}
+
+// Host loads the filters in a directory and runs messages past them.
+type Host struct {
+	logger  *slog.Logger
+	dir     string
+	limits  Limits
+	runtime wazero.Runtime
+
+	// mu is held for reading for the whole of each check, so that a
+	// filter's compiled module is only closed once nothing is running it.
+	mu      sync.RWMutex
+	plugins []*plugin
+	closed  bool
+}
+
+// NewHost creates a Host running the filters in dir under the limits,
+// loading them straight away. It should be closed when it's no longer
+// used.
+func NewHost(logger *slog.Logger, dir string, limits Limits) *Host {
+	ctx := context.Background()
+
+	// Closing the module when its context is done is what stops a check
+	// that runs for too long
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(limits.MemoryPages).
+		WithCloseOnContextDone(true)
+
+	h := &Host{
+		logger:  logger,
+		dir:     dir,
+		limits:  limits,
+		runtime: wazero.NewRuntimeWithConfig(ctx, config),
+	}
+
+	_, err := h.runtime.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(h.log).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		// The module is fixed, so this can only be a bug
+		panic(fmt.Sprintf("plugin: failed to instantiate env: %v", err))
+	}
+
+	h.Reload()
+
+	return h
+}
+
+// Close unloads the filters, after waiting for any checks in progress.
+// Messages checked after it's closed are held for review.
+func (h *Host) Close(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.plugins = nil
+	h.closed = true
+
+	return h.runtime.Close(ctx)
+}
+
+// Names returns the names of the loaded filters, in the order they run.
+func (h *Host) Names() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, len(h.plugins))
+	for i, p := range h.plugins {
+		names[i] = p.name
+	}
+
+	return names
+}
+
+// Reload loads filters that have been added or changed since the last
+// load, and drops those that have been removed. A filter that no longer
+// compiles is logged, and the last version that did is kept.
+func (h *Host) Reload() {
+	files, err := filepath.Glob(filepath.Join(h.dir, "*.wasm"))
+	if err != nil {
+		h.logger.Error("failed to list plugins", slog.Any("error", err))
+		return
+	}
+
+	h.mu.RLock()
+	current := map[string]*plugin{}
+	for _, p := range h.plugins {
+		current[p.name] = p
+	}
+	h.mu.RUnlock()
+
+	plugins := []*plugin{}
+	for _, file := range files {
+		name := filepath.Base(file)
+		prev := current[name]
+
+		info, err := os.Stat(file)
+		if err != nil {
+			h.logger.Error("failed to stat plugin", slog.String("plugin", name), slog.Any("error", err))
+			continue
+		}
+
+		if prev != nil && prev.modTime.Equal(info.ModTime()) && prev.size == info.Size() {
+			plugins = append(plugins, prev)
+			continue
+		}
+
+		p, err := h.load(file)
+		if err != nil {
+			h.logger.Error("failed to load plugin", slog.String("plugin", name), slog.Any("error", err))
+
+			if prev != nil {
+				plugins = append(plugins, prev)
+			}
+			continue
+		}
+
+		p.modTime, p.size = info.ModTime(), info.Size()
+		plugins = append(plugins, p)
+		h.logger.Info("loaded plugin", slog.String("plugin", name))
+	}
+
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].name < plugins[j].name
+	})
+
+	kept := map[*plugin]bool{}
+	for _, p := range plugins {
+		kept[p] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.plugins = plugins
+
+	// No check is running while the lock is held, so the modules of
+	// filters that were changed or removed can be freed
+	for _, p := range current {
+		if !kept[p] {
+			p.module.Close(context.Background())
+		}
+	}
+}
+
+// Watch reloads the filters every interval until the context is done.
+func (h *Host) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Reload()
+		}
+	}
+}
+
+// Check runs the input past each filter in turn. The first to reject the
+// message decides the verdict, and otherwise any asking for review, or
+// failing, holds it for a moderator.
+func (h *Host) Check(ctx context.Context, input Input) Verdict {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		return Verdict{Action: Review}
+	}
+
+	plugins := h.plugins
+
+	verdict := Verdict{Action: Allow}
+	if len(plugins) == 0 {
+		return verdict
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		h.logger.Error("failed to encode plugin input", slog.Any("error", err))
+		return Verdict{Action: Review}
+	}
+
+	for _, p := range plugins {
+		v, err := h.run(ctx, p, payload)
+		if err != nil {
+			h.logger.Error("plugin failed", slog.String("plugin", p.name), slog.Any("error", err))
+			v = Verdict{Action: Review, Plugin: p.name}
+		}
+
+		switch v.Action {
+		case Reject:
+			return v
+		case Review:
+			if verdict.Action != Review {
+				verdict = v
+			}
+		}
+	}
+
+	return verdict
+}
+
+// run checks the payload with a fresh instance of the filter.
+func (h *Host) run(ctx context.Context, p *plugin, payload []byte) (Verdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.limits.Timeout)
+	defer cancel()
+
+	mod, err := h.instantiate(ctx, p)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("instantiate: %w", err)
+	}
+	defer mod.Close(context.WithoutCancel(ctx))
+
+	res, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(payload)))
+	if err != nil {
+		return Verdict{}, fmt.Errorf("alloc: %w", err)
+	}
+
+	ptr := api.DecodeU32(res[0])
+	if !mod.Memory().Write(ptr, payload) {
+		return Verdict{}, fmt.Errorf("alloc returned memory out of bounds")
+	}
+
+	res, err = mod.ExportedFunction("filter").Call(ctx, uint64(ptr), uint64(len(payload)))
+	if err != nil {
+		return Verdict{}, fmt.Errorf("filter: %w", err)
+	}
+
+	out, ok := mod.Memory().Read(uint32(res[0]>>32), uint32(res[0]))
+	if !ok {
+		return Verdict{}, fmt.Errorf("verdict out of bounds")
+	}
+
+	var v Verdict
+	if err := json.Unmarshal(out, &v); err != nil {
+		return Verdict{}, fmt.Errorf("decode verdict: %w", err)
+	}
+
+	switch v.Action {
+	case Allow, Reject, Review:
+	default:
+		return Verdict{}, fmt.Errorf("unknown action %q", v.Action)
+	}
+
+	v.Plugin = p.name
+	return v, nil
+}
+
+// instantiate creates an instance of the filter, with its name on the
+// context for the functions it imports. Instances are anonymous, so the
+// same filter can run any number of checks at once.
+func (h *Host) instantiate(ctx context.Context, p *plugin) (api.Module, error) {
+	ctx = context.WithValue(ctx, pluginKey{}, p.name)
+
+	return h.runtime.InstantiateModule(
+		ctx, p.module, wazero.NewModuleConfig().WithName("").WithStartFunctions(),
+	)
+}
+
+// log is env.log, which writes a line from the filter's memory to the
+// guestbook's log.
+func (h *Host) log(ctx context.Context, mod api.Module, ptr, size uint32) {
+	line, ok := mod.Memory().Read(ptr, min(size, maxLogLine))
+	if !ok {
+		// Panicking traps the filter
+		panic(errors.New("log out of bounds"))
+	}
+
+	name, _ := ctx.Value(pluginKey{}).(string)
+	h.logger.Info(string(line), slog.String("plugin", name))
+}
+
+// load compiles a filter, checking it implements the interface and can be
+// instantiated.
+func (h *Host) load(file string) (*plugin, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.limits.Timeout)
+	defer cancel()
+
+	// Compiling validates the module, including that its memory fits in
+	// the limit
+	module, err := h.runtime.CompileModule(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPlugin, err)
+	}
+
+	p := &plugin{name: filepath.Base(file), module: module}
+
+	if err := validate(module); err != nil {
+		module.Close(ctx)
+		return nil, err
+	}
+
+	// Instantiating resolves the imports and runs any start function, so
+	// that a filter that can't start is caught before it's used.
+	mod, err := h.instantiate(ctx, p)
+	if err != nil {
+		module.Close(ctx)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPlugin, err)
+	}
+	mod.Close(ctx)
+
+	return p, nil
+}
+
+// validate checks the module exports its memory, and alloc and filter
+// with the right types.
+func validate(module wazero.CompiledModule) error {
+	if _, ok := module.ExportedMemories()["memory"]; !ok {
+		return fmt.Errorf("%w: memory isn't exported", ErrInvalidPlugin)
+	}
+
+	funcs := module.ExportedFunctions()
+
+	for name, want := range exports {
+		fn, ok := funcs[name]
+		if !ok {
+			return fmt.Errorf("%w: %s isn't exported", ErrInvalidPlugin, name)
+		}
+
+		got := signature{params: fn.ParamTypes(), results: fn.ResultTypes()}
+		if !got.equal(want) {
+			return fmt.Errorf("%w: %s has type %s, want %s", ErrInvalidPlugin, name, got, want)
+		}
+	}
+
+	return nil
+}
+
+func (s signature) equal(o signature) bool {
+	return slices.Equal(s.params, o.params) && slices.Equal(s.results, o.results)
+}
+
+func (s signature) String() string {
+	names := func(types []api.ValueType) string {
+		parts := make([]string, len(types))
+		for i, t := range types {
+			parts[i] = api.ValueTypeName(t)
+		}
+
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+
+	return names(s.params) + " -> " + names(s.results)
+}
@@ -0,0 +1,234 @@
+package plugin_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dreamsofcode-io/guestbook/internal/plugin"
+)
+
+// verdictAt is where filters keep their verdicts.
+const verdictAt = 1024
+
+// filter assembles a filter module that runs body, which is given the
+// input's pointer and length as locals 0 and 1. Log is imported as
+// function 0 when withLog is set.
+func filter(verdict string, withLog bool, body ...byte) []byte {
+	packed := int64(verdictAt)<<32 | int64(len(verdict))
+
+	m := wasmModule{
+		Memory: &wasmMemory{Min: 1},
+		Data:   []wasmData{{Offset: verdictAt, Bytes: []byte(verdict)}},
+		Funcs: []wasmFunc{
+			{
+				Params:  []valueType{i32},
+				Results: []valueType{i32},
+				Export:  "alloc",
+				Body:    i32Const(4096),
+			},
+			{
+				Params:  []valueType{i32, i32},
+				Results: []valueType{i64},
+				Export:  "filter",
+				Body:    concat(body, i64Const(packed)),
+			},
+		},
+	}
+
+	if withLog {
+		m.Imports = []wasmImport{{
+			Module: "env", Name: "log", Params: []valueType{i32, i32},
+		}}
+	}
+
+	return m.Bytes()
+}
+
+var (
+	allow  = filter(`{"action":"allow"}`, false)
+	reject = filter(`{"action":"reject","reason":"No shouting"}`, false)
+	review = filter(`{"action":"review"}`, false)
+	spin   = filter(`{"action":"allow"}`, false, 0x03, 0x40, 0x0C, 0x00, 0x0B)
+	traps  = filter(`{"action":"allow"}`, false, 0x00)
+	bogus  = filter(`{"action":"maybe"}`, false)
+	logs   = filter(`{"action":"allow"}`, true, 0x20, 0x00, 0x20, 0x01, 0x10, 0x00)
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func writePlugins(t *testing.T, dir string, plugins map[string][]byte) {
+	t.Helper()
+
+	for name, data := range plugins {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o644))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Plugins     map[string][]byte
+		Action      plugin.Action
+		Reason      string
+		Plugin      string
+	}{
+		{
+			Description: "no plugins",
+			Action:      plugin.Allow,
+		},
+		{
+			Description: "allowed",
+			Plugins:     map[string][]byte{"allow.wasm": allow},
+			Action:      plugin.Allow,
+		},
+		{
+			Description: "rejection wins over review",
+			Plugins:     map[string][]byte{"a.wasm": review, "b.wasm": reject, "c.wasm": allow},
+			Action:      plugin.Reject,
+			Reason:      "No shouting",
+			Plugin:      "b.wasm",
+		},
+		{
+			Description: "held for review",
+			Plugins:     map[string][]byte{"allow.wasm": allow, "review.wasm": review},
+			Action:      plugin.Review,
+			Plugin:      "review.wasm",
+		},
+		{
+			Description: "running out of time is held for review",
+			Plugins:     map[string][]byte{"spin.wasm": spin},
+			Action:      plugin.Review,
+			Plugin:      "spin.wasm",
+		},
+		{
+			Description: "trapping is held for review",
+			Plugins:     map[string][]byte{"traps.wasm": traps},
+			Action:      plugin.Review,
+			Plugin:      "traps.wasm",
+		},
+		{
+			Description: "unknown action is held for review",
+			Plugins:     map[string][]byte{"bogus.wasm": bogus},
+			Action:      plugin.Review,
+			Plugin:      "bogus.wasm",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			dir := t.TempDir()
+			writePlugins(t, dir, tc.Plugins)
+
+			limits := plugin.DefaultLimits
+			limits.Timeout = time.Millisecond * 50
+
+			host := plugin.NewHost(discard, dir, limits)
+			defer host.Close(context.Background())
+
+			verdict := host.Check(context.Background(), plugin.Input{Message: "hello"})
+			assert.Equal(t, tc.Action, verdict.Action)
+			assert.Equal(t, tc.Reason, verdict.Reason)
+			assert.Equal(t, tc.Plugin, verdict.Plugin)
+		})
+	}
+}
+
+func TestCheckInput(t *testing.T) {
+	dir := t.TempDir()
+	writePlugins(t, dir, map[string][]byte{"logs.wasm": logs})
+
+	var buf bytes.Buffer
+	host := plugin.NewHost(slog.New(slog.NewTextHandler(&buf, nil)), dir, plugin.DefaultLimits)
+	defer host.Close(context.Background())
+
+	verdict := host.Check(context.Background(), plugin.Input{
+		Message:   "hello",
+		IP:        "203.0.113.7",
+		Tags:      []string{"go"},
+		Guestbook: plugin.Guestbook{Slug: "party", Visibility: "public"},
+	})
+
+	assert.Equal(t, plugin.Allow, verdict.Action)
+	assert.Contains(t, buf.String(), `\"message\":\"hello\"`)
+	assert.Contains(t, buf.String(), `\"slug\":\"party\"`)
+	assert.Contains(t, buf.String(), "plugin=logs.wasm")
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	writePlugins(t, dir, map[string][]byte{"a.wasm": allow, "notes.txt": []byte("ignored")})
+
+	host := plugin.NewHost(discard, dir, plugin.DefaultLimits)
+	defer host.Close(context.Background())
+	assert.Equal(t, []string{"a.wasm"}, host.Names())
+
+	writePlugins(t, dir, map[string][]byte{"b.wasm": reject})
+	host.Reload()
+	assert.Equal(t, []string{"a.wasm", "b.wasm"}, host.Names())
+	assert.Equal(t, plugin.Reject, host.Check(context.Background(), plugin.Input{}).Action)
+
+	// A change that breaks the plugin keeps the working version
+	writePlugins(t, dir, map[string][]byte{"b.wasm": []byte("broken")})
+	host.Reload()
+	assert.Equal(t, []string{"a.wasm", "b.wasm"}, host.Names())
+	assert.Equal(t, plugin.Reject, host.Check(context.Background(), plugin.Input{}).Action)
+
+	writePlugins(t, dir, map[string][]byte{"b.wasm": review})
+	host.Reload()
+	assert.Equal(t, plugin.Review, host.Check(context.Background(), plugin.Input{}).Action)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.wasm")))
+	host.Reload()
+	assert.Equal(t, []string{"a.wasm"}, host.Names())
+	assert.Equal(t, plugin.Allow, host.Check(context.Background(), plugin.Input{}).Action)
+}
+
+func TestInvalidPlugins(t *testing.T) {
+	noFilter := wasmModule{
+		Memory: &wasmMemory{Min: 1},
+		Funcs: []wasmFunc{{
+			Params:  []valueType{i32},
+			Results: []valueType{i32},
+			Export:  "alloc",
+			Body:    i32Const(0),
+		}},
+	}
+
+	tooBig := wasmModule{
+		Memory: &wasmMemory{Min: 1024},
+		Funcs:  []wasmFunc{{Export: "unused"}},
+	}
+
+	dir := t.TempDir()
+	writePlugins(t, dir, map[string][]byte{
+		"garbage.wasm":   []byte("not wasm"),
+		"no-filter.wasm": noFilter.Bytes(),
+		"too-big.wasm":   tooBig.Bytes(),
+		"ok.wasm":        allow,
+	})
+
+	host := plugin.NewHost(discard, dir, plugin.DefaultLimits)
+	defer host.Close(context.Background())
+
+	assert.Equal(t, []string{"ok.wasm"}, host.Names())
+}
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	writePlugins(t, dir, map[string][]byte{"allow.wasm": allow})
+
+	host := plugin.NewHost(discard, dir, plugin.DefaultLimits)
+	assert.Equal(t, plugin.Allow, host.Check(context.Background(), plugin.Input{}).Action)
+
+	require.NoError(t, host.Close(context.Background()))
+	assert.Equal(t, plugin.Review, host.Check(context.Background(), plugin.Input{}).Action)
+}
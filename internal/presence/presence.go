@@ -0,0 +1,90 @@
+// Package presence counts how many people are viewing a guestbook right
+// now. Each open stream sends heartbeats into a redis sorted set scored by
+// when the heartbeat expires, so the count is shared across replicas and
+// viewers whose connection silently dropped age out on their own.
+package presence
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Hub records heartbeats and counts viewers.
+type Hub struct {
+	store redis.Cmdable
+	ttl   time.Duration
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+// NewHub creates a Hub where a viewer is counted for ttl after their last
+// heartbeat.
+func NewHub(store redis.Cmdable, ttl time.Duration) *Hub {
+	return &Hub{
+		store:  store,
+		ttl:    ttl,
+		closed: make(chan struct{}),
+	}
+}
+
+func key(guestbookID uuid.UUID) string {
+	return "presence:" + guestbookID.String()
+}
+
+// Interval is how often streams should send a heartbeat, leaving room for
+// a missed beat before the viewer expires.
+func (h *Hub) Interval() time.Duration {
+	return h.ttl / 3
+}
+
+// Heartbeat marks the viewer as present and returns the number of viewers
+// currently on the guestbook.
+func (h *Hub) Heartbeat(
+	ctx context.Context, guestbookID uuid.UUID, viewerID string,
+) (int64, error) {
+	now := time.Now()
+	k := key(guestbookID)
+
+	var count *redis.IntCmd
+
+	_, err := h.store.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, k, redis.Z{
+			Score:  float64(now.Add(h.ttl).UnixMilli()),
+			Member: viewerID,
+		})
+		pipe.ZRemRangeByScore(ctx, k, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		count = pipe.ZCard(ctx, k)
+		pipe.Expire(ctx, k, h.ttl*2)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+
+	return count.Val(), nil
+}
+
+// Leave removes the viewer straight away rather than waiting for them to
+// expire.
+func (h *Hub) Leave(ctx context.Context, guestbookID uuid.UUID, viewerID string) error {
+	return h.store.ZRem(ctx, key(guestbookID), viewerID).Err()
+}
+
+// Closed is closed when the hub is shutting down, telling streams to end.
+func (h *Hub) Closed() <-chan struct{} {
+	return h.closed
+}
+
+// Close ends every open stream, so they don't hold up a graceful shutdown.
+func (h *Hub) Close() {
+	h.once.Do(func() {
+		close(h.closed)
+	})
+}
@@ -0,0 +1,5 @@
+// Package push sends Web Push notifications: the VAPID keys identifying
+// the guestbook to push services (RFC 8292), the encryption of payloads
+// for each subscription (RFC 8291), and the delivery of notifications to
+// the subscriptions of owners and visitors through the job queue.
+package push
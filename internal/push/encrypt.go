@@ -0,0 +1,113 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// recordSize is the record size declared in the header, which is more
+	// than any payload push services accept.
+	recordSize = 4096
+
+	// headerSize is the salt, record size, key length and key.
+	headerSize = 16 + 4 + 1 + 65
+
+	// MaxPayload is the largest payload that can be sent, as push services
+	// accept at most 4096 bytes once encrypted.
+	MaxPayload = 4096 - headerSize - 16 - 1
+)
+
+// ErrTooLarge is returned for payloads over MaxPayload.
+var ErrTooLarge = errors.New("push: payload too large")
+
+// Subscription is where a browser asked for notifications to be sent, and
+// the keys to encrypt them for it.
+type Subscription struct {
+	Endpoint string
+	// P256dh is the browser's public key, an uncompressed P-256 point.
+	P256dh []byte
+	// Auth is the secret shared with the browser.
+	Auth []byte
+}
+
+// Encrypt encrypts the payload for the subscription, as the body of a push
+// message using the aes128gcm content encoding of RFC 8291.
+func Encrypt(sub Subscription, payload []byte) ([]byte, error) {
+	if len(payload) > MaxPayload {
+		return nil, ErrTooLarge
+	}
+
+	ua, err := ecdh.P256().NewPublicKey(sub.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription key: %w", err)
+	}
+
+	if len(sub.Auth) != 16 {
+		return nil, fmt.Errorf("invalid subscription auth secret")
+	}
+
+	// Each message is encrypted with a new key pair and salt
+	as, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	secret, err := as.ECDH(ua)
+	if err != nil {
+		return nil, fmt.Errorf("failed to agree key: %w", err)
+	}
+
+	asPublic := as.PublicKey().Bytes()
+
+	info := append([]byte("WebPush: info\x00"), sub.P256dh...)
+	info = append(info, asPublic...)
+
+	ikm := derive(secret, sub.Auth, info, 32)
+	key := derive(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := derive(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	header := make([]byte, 0, headerSize+len(payload)+1+gcm.Overhead())
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	// The whole payload is a single record, so it ends with the delimiter
+	// for the last record, 0x02, and no padding.
+	record := append(append([]byte(nil), payload...), 0x02)
+
+	return gcm.Seal(header, nonce, record, nil), nil
+}
+
+// derive is HKDF with SHA-256.
+func derive(secret, salt, info []byte, length int) []byte {
+	out := make([]byte, length)
+	io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out)
+
+	return out
+}
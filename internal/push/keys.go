@@ -0,0 +1,114 @@
+package push
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+)
+
+// Keys are the application server's VAPID keys, which identify it to push
+// services, so that only it can send to the subscriptions made with its
+// public key.
+type Keys struct {
+	private *ecdsa.PrivateKey
+	public  []byte
+}
+
+// GenerateKeys creates a new pair of keys.
+func GenerateKeys() (*Keys, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate vapid key: %w", err)
+	}
+
+	return ParseKeys(key.Bytes())
+}
+
+// ParseKeys creates the keys from the private key's 32 byte scalar.
+func ParseKeys(private []byte) (*Keys, error) {
+	key, err := ecdh.P256().NewPrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid key: %w", err)
+	}
+
+	// The uncompressed point is 0x04 followed by X and Y
+	public := key.PublicKey().Bytes()
+
+	return &Keys{
+		private: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(private),
+		},
+		public: public,
+	}, nil
+}
+
+// Bytes returns the private key's scalar, for storing.
+func (k *Keys) Bytes() []byte {
+	return k.private.D.FillBytes(make([]byte, 32))
+}
+
+// PublicKey returns the public key as browsers take it when subscribing,
+// the uncompressed point encoded as unpadded base64url.
+func (k *Keys) PublicKey() string {
+	return encode(k.public)
+}
+
+// authorization returns the Authorization header for sending to the
+// endpoint, a JWT signed with the private key, as in RFC 8292.
+func (k *Keys) authorization(endpoint string, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint: %w", err)
+	}
+
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(time.Hour * 12).Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	unsigned := encode([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	// ES256 signatures are the two 32 byte integers, one after the other
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return "vapid t=" + unsigned + "." + encode(signature) + ", k=" + k.PublicKey(), nil
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode decodes the base64url keys browsers give for subscriptions,
+// with or without padding.
+func Decode(value string) ([]byte, error) {
+	if data, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return data, nil
+	}
+
+	return base64.URLEncoding.DecodeString(value)
+}
@@ -0,0 +1,426 @@
+package push_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/dreamsofcode-io/guestbook/internal/push"
+)
+
+func b64(t *testing.T, value string) []byte {
+	t.Helper()
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	require.NoError(t, err)
+
+	return data
+}
+
+// decrypt decrypts a message as the browser does, with its private key and
+// auth secret.
+func decrypt(t *testing.T, ua *ecdh.PrivateKey, auth []byte, body []byte) []byte {
+	t.Helper()
+
+	require.Greater(t, len(body), 21)
+
+	salt := body[:16]
+	rs := binary.BigEndian.Uint32(body[16:20])
+	idlen := int(body[20])
+	require.Greater(t, len(body), 21+idlen)
+
+	asPublic := body[21 : 21+idlen]
+	ciphertext := body[21+idlen:]
+	require.LessOrEqual(t, len(ciphertext), int(rs))
+
+	as, err := ecdh.P256().NewPublicKey(asPublic)
+	require.NoError(t, err)
+
+	secret, err := ua.ECDH(as)
+	require.NoError(t, err)
+
+	derive := func(secret, salt, info []byte, length int) []byte {
+		out := make([]byte, length)
+		_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out)
+		require.NoError(t, err)
+		return out
+	}
+
+	info := append([]byte("WebPush: info\x00"), ua.PublicKey().Bytes()...)
+	info = append(info, asPublic...)
+
+	ikm := derive(secret, auth, info, 32)
+	key := derive(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := derive(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	record, err := gcm.Open(nil, nonce, ciphertext, nil)
+	require.NoError(t, err)
+
+	// The last record ends with 0x02, followed by any padding
+	end := strings.LastIndexByte(string(record), 0x02)
+	require.GreaterOrEqual(t, end, 0)
+
+	return record[:end]
+}
+
+// TestDecryptVector checks the decryption the other tests rely on against
+// the example in RFC 8291, appendix A.
+func TestDecryptVector(t *testing.T) {
+	ua, err := ecdh.P256().NewPrivateKey(b64(t, "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		b64(t, "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"),
+		ua.PublicKey().Bytes(),
+	)
+
+	body := b64(t, "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+
+	plaintext := decrypt(t, ua, b64(t, "BTBZMqHH6r4Tts7J_aSIgg"), body)
+	assert.Equal(t, "When I grow up, I want to be a watermelon", string(plaintext))
+}
+
+// browser is a subscriber's keys.
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) browser {
+	t.Helper()
+
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	auth := make([]byte, 16)
+	rand.Read(auth)
+
+	return browser{key: key, auth: auth}
+}
+
+func (b browser) subscription(endpoint string) push.Subscription {
+	return push.Subscription{
+		Endpoint: endpoint,
+		P256dh:   b.key.PublicKey().Bytes(),
+		Auth:     b.auth,
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	b := newBrowser(t)
+	sub := b.subscription("https://push.example.com/send/1")
+
+	testCases := []struct {
+		Description string
+		Payload     []byte
+		ExpectedErr error
+	}{
+		{
+			Description: "message",
+			Payload:     []byte(`{"title":"New message","body":"hello"}`),
+		},
+		{
+			Description: "empty",
+			Payload:     []byte{},
+		},
+		{
+			Description: "largest payload",
+			Payload:     []byte(strings.Repeat("a", push.MaxPayload)),
+		},
+		{
+			Description: "too large",
+			Payload:     []byte(strings.Repeat("a", push.MaxPayload+1)),
+			ExpectedErr: push.ErrTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			body, err := push.Encrypt(sub, tc.Payload)
+			if tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(body), 4096)
+			assert.Equal(t, string(tc.Payload), string(decrypt(t, b.key, b.auth, body)))
+		})
+	}
+
+	// Each message has its own salt and key
+	first, err := push.Encrypt(sub, []byte("hello"))
+	require.NoError(t, err)
+
+	second, err := push.Encrypt(sub, []byte("hello"))
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+}
+
+func TestEncryptInvalidSubscription(t *testing.T) {
+	b := newBrowser(t)
+
+	sub := b.subscription("https://push.example.com/send/1")
+	sub.P256dh = []byte("not a key")
+
+	_, err := push.Encrypt(sub, []byte("hello"))
+	assert.Error(t, err)
+
+	sub = b.subscription("https://push.example.com/send/1")
+	sub.Auth = sub.Auth[:8]
+
+	_, err = push.Encrypt(sub, []byte("hello"))
+	assert.Error(t, err)
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := push.GenerateKeys()
+	require.NoError(t, err)
+
+	parsed, err := push.ParseKeys(keys.Bytes())
+	require.NoError(t, err)
+
+	assert.Equal(t, keys.PublicKey(), parsed.PublicKey())
+	assert.Len(t, b64(t, keys.PublicKey()), 65)
+
+	_, err = push.ParseKeys([]byte("short"))
+	assert.Error(t, err)
+}
+
+// pushService stands in for a browser vendor's push service, checking the
+// VAPID authorization and decrypting what it's sent.
+type pushService struct {
+	t       *testing.T
+	server  *httptest.Server
+	browser browser
+	status  int
+	// key is the application server key the browser subscribed with,
+	// when only requests signed with it should be accepted.
+	key string
+
+	received []string
+	headers  []http.Header
+}
+
+func newPushService(t *testing.T) *pushService {
+	s := &pushService{t: t, browser: newBrowser(t), status: http.StatusCreated}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+func (s *pushService) serve(w http.ResponseWriter, r *http.Request) {
+	s.headers = append(s.headers, r.Header.Clone())
+
+	if !s.verify(r.Header.Get("Authorization")) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(s.t, err)
+
+	s.received = append(s.received, string(decrypt(s.t, s.browser.key, s.browser.auth, body)))
+	w.WriteHeader(s.status)
+}
+
+// verify checks the VAPID JWT was signed by the key it's sent with, and
+// is for this push service.
+func (s *pushService) verify(authorization string) bool {
+	scheme, params, ok := strings.Cut(authorization, " ")
+	if !ok || scheme != "vapid" {
+		return false
+	}
+
+	var token, key string
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+
+	if s.key != "" && key != s.key {
+		return false
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	point := b64(s.t, key)
+	x, y := elliptic.Unmarshal(elliptic.P256(), point)
+	if x == nil {
+		return false
+	}
+
+	sig := b64(s.t, parts[2])
+	if len(sig) != 64 {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	if !ecdsa.Verify(public, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return false
+	}
+
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(b64(s.t, parts[1]), &claims); err != nil {
+		return false
+	}
+
+	return claims.Aud == s.server.URL &&
+		claims.Exp > time.Now().Unix() &&
+		claims.Exp <= time.Now().Add(time.Hour*24).Unix() &&
+		claims.Sub == "mailto:admin@example.com"
+}
+
+func TestSend(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Status      int
+		ExpectedErr error
+	}{
+		{
+			Description: "delivered",
+			Status:      http.StatusCreated,
+		},
+		{
+			Description: "subscription gone",
+			Status:      http.StatusGone,
+			ExpectedErr: push.ErrGone,
+		},
+		{
+			Description: "subscription not found",
+			Status:      http.StatusNotFound,
+			ExpectedErr: push.ErrGone,
+		},
+		{
+			Description: "payload too large",
+			Status:      http.StatusRequestEntityTooLarge,
+			ExpectedErr: push.ErrTooLarge,
+		},
+	}
+
+	keys, err := push.GenerateKeys()
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			service := newPushService(t)
+			service.status = tc.Status
+
+			sender := push.NewSender(keys, "mailto:admin@example.com", service.server.Client())
+			sub := service.browser.subscription(service.server.URL + "/send/abc")
+
+			err := sender.Send(context.Background(), sub, []byte(`{"title":"New message"}`))
+			if tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			require.Len(t, service.received, 1)
+			assert.Equal(t, `{"title":"New message"}`, service.received[0])
+
+			header := service.headers[0]
+			assert.Equal(t, "aes128gcm", header.Get("Content-Encoding"))
+			assert.Equal(t, "86400", header.Get("TTL"))
+			assert.Contains(t, header.Get("Authorization"), "k="+keys.PublicKey())
+		})
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	keys, err := push.GenerateKeys()
+	require.NoError(t, err)
+
+	service := newPushService(t)
+	service.status = http.StatusServiceUnavailable
+	service.key = keys.PublicKey()
+
+	sender := push.NewSender(keys, "mailto:admin@example.com", service.server.Client())
+
+	err = sender.Send(context.Background(), service.browser.subscription(service.server.URL), []byte("hi"))
+	assert.EqualError(t, err, "push service responded with 503 Service Unavailable")
+
+	// A key other than the one subscribed with is refused
+	other, err := push.GenerateKeys()
+	require.NoError(t, err)
+
+	sender = push.NewSender(other, "mailto:admin@example.com", service.server.Client())
+
+	err = sender.Send(context.Background(), service.browser.subscription(service.server.URL), []byte("hi"))
+	assert.EqualError(t, err, "push service responded with 401 Unauthorized")
+}
+
+func TestSendNotPublic(t *testing.T) {
+	keys, err := push.GenerateKeys()
+	require.NoError(t, err)
+
+	service := newPushService(t)
+	service.key = keys.PublicKey()
+
+	// The default client refuses to connect to anything but the internet
+	sender := push.NewSender(keys, "mailto:admin@example.com", nil)
+
+	testCases := []struct {
+		Description string
+		Endpoint    string
+	}{
+		{Description: "loopback", Endpoint: service.server.URL},
+		{Description: "localhost", Endpoint: strings.Replace(service.server.URL, "127.0.0.1", "localhost", 1)},
+		{Description: "ipv6 loopback", Endpoint: "http://[::1]:9/send"},
+		{Description: "private", Endpoint: "http://10.0.0.1:9/send"},
+		{Description: "link-local", Endpoint: "http://169.254.169.254/latest/meta-data"},
+		{Description: "shared address space", Endpoint: "http://100.64.0.1:9/send"},
+		{Description: "unspecified", Endpoint: "http://0.0.0.0:9/send"},
+		{Description: "ipv4-mapped", Endpoint: "http://[::ffff:127.0.0.1]:9/send"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			err := sender.Send(context.Background(), service.browser.subscription(tc.Endpoint), []byte("hi"))
+			assert.ErrorIs(t, err, push.ErrNotPublic)
+		})
+	}
+
+	assert.Empty(t, service.headers)
+}
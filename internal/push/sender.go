@@ -0,0 +1,147 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrGone is returned when the push service no longer knows the
+// subscription, which should then be forgotten.
+var ErrGone = errors.New("push: subscription has expired or been removed")
+
+// ErrNotPublic is returned, wrapped, when a subscription's endpoint is an
+// address that isn't on the public internet.
+var ErrNotPublic = errors.New("push: endpoint isn't a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which isn't public
+// but isn't covered by netip.Addr.IsPrivate either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// DefaultTTL is how long a push service keeps a notification for a
+// browser that's offline.
+const DefaultTTL = time.Hour * 24
+
+// Sender sends encrypted messages to push services.
+type Sender struct {
+	keys    *Keys
+	subject string
+	client  *http.Client
+
+	// TTL is how long push services should keep messages for browsers
+	// that are offline.
+	TTL time.Duration
+}
+
+// NewSender creates a Sender signing requests with the keys. The subject
+// is a mailto: or https: URL push services can contact the sender at.
+//
+// Endpoints are given by browsers, so anyone can subscribe with one that
+// points inside the server's network. Without a client, the one used only
+// connects to public addresses, checked after they're resolved so that
+// DNS can't be used to get around it.
+func NewSender(keys *Keys, subject string, client *http.Client) *Sender {
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 30, Transport: publicTransport()}
+	}
+
+	return &Sender{
+		keys:    keys,
+		subject: subject,
+		client:  client,
+		TTL:     DefaultTTL,
+	}
+}
+
+// Send encrypts the payload for the subscription and sends it to its push
+// service.
+func (s *Sender) Send(ctx context.Context, sub Subscription, payload []byte) error {
+	body, err := Encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+
+	auth, err := s.keys.authorization(sub.Endpoint, s.subject, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(s.TTL.Seconds())))
+	req.Header.Set("Urgency", "normal")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send push message: %w", err)
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return ErrGone
+	case res.StatusCode == http.StatusRequestEntityTooLarge:
+		return ErrTooLarge
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return fmt.Errorf("push service responded with %s", res.Status)
+	}
+
+	return nil
+}
+
+// publicTransport returns a transport that refuses to connect to any
+// address that isn't public, including after a redirect. It doesn't use
+// a proxy, as the proxy would make the connection instead.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   time.Second * 30,
+		KeepAlive: time.Second * 30,
+		Control:   publicOnly,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return transport
+}
+
+// publicOnly is a net.Dialer.Control function, checking the address about
+// to be connected to is public.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrNotPublic, address)
+	}
+
+	if addr := addrPort.Addr().Unmap(); !isPublic(addr) {
+		return fmt.Errorf("%w: %s", ErrNotPublic, addr)
+	}
+
+	return nil
+}
+
+// isPublic reports whether the address is reachable on the internet,
+// rather than being loopback, private, link-local, multicast or otherwise
+// reserved.
+func isPublic(addr netip.Addr) bool {
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
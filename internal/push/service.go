@@ -0,0 +1,290 @@
+package push
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/dreamsofcode-io/guestbook/internal/access"
+	"github.com/dreamsofcode-io/guestbook/internal/jobs"
+	"github.com/dreamsofcode-io/guestbook/internal/repository"
+)
+
+// The kinds of jobs notifications are delivered through. Notifying an
+// account or guests fans out into a job per subscription, so that one
+// failing push service doesn't hold up or repeat the others.
+const (
+	kindAccount = "push.account"
+	kindGuests  = "push.guests"
+	kindSend    = "push.send"
+)
+
+// ServiceWorker is the script that shows notifications in the browser. It
+// must be served from the site's own origin.
+//
+//go:embed sw.js
+var ServiceWorker []byte
+
+// Notification is the payload shown by the service worker.
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// URL is the page opened when the notification is clicked.
+	URL string `json:"url"`
+}
+
+type accountJob struct {
+	AccountID    uuid.UUID    `json:"account_id"`
+	Notification Notification `json:"notification"`
+}
+
+type guestsJob struct {
+	GuestIDs     []uuid.UUID  `json:"guest_ids"`
+	Notification Notification `json:"notification"`
+}
+
+type sendJob struct {
+	SubscriptionID uuid.UUID    `json:"subscription_id"`
+	Notification   Notification `json:"notification"`
+}
+
+// Service stores subscriptions and notifies them.
+type Service struct {
+	logger *slog.Logger
+	db     *pgxpool.Pool
+	repo   *repository.Queries
+	sender *Sender
+	signer *access.Signer
+}
+
+func NewService(
+	logger *slog.Logger, db *pgxpool.Pool, sender *Sender,
+	signer *access.Signer,
+) *Service {
+	return &Service{
+		logger: logger,
+		db:     db,
+		repo:   repository.New(db),
+		sender: sender,
+		signer: signer,
+	}
+}
+
+// PublicKey returns the VAPID public key browsers subscribe with.
+func (s *Service) PublicKey() string {
+	return s.sender.keys.PublicKey()
+}
+
+// Register handles the notification jobs on the queue.
+func (s *Service) Register(q *jobs.Queue) {
+	q.Handle(kindAccount, s.fanOutAccount)
+	q.Handle(kindGuests, s.fanOutGuests)
+	q.Handle(kindSend, s.send)
+}
+
+// SubscribeAccount subscribes the browser to notifications for the
+// account's guestbooks.
+func (s *Service) SubscribeAccount(ctx context.Context, accountID uuid.UUID, sub Subscription) error {
+	return s.subscribe(ctx, sub, uuid.NullUUID{UUID: accountID, Valid: true}, uuid.NullUUID{})
+}
+
+// SubscribeGuest subscribes the browser to notifications about the
+// visitor's message.
+func (s *Service) SubscribeGuest(ctx context.Context, guestID uuid.UUID, sub Subscription) error {
+	return s.subscribe(ctx, sub, uuid.NullUUID{}, uuid.NullUUID{UUID: guestID, Valid: true})
+}
+
+func (s *Service) subscribe(
+	ctx context.Context, sub Subscription, accountID, guestID uuid.NullUUID,
+) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return fmt.Errorf("failed to create subscription id: %w", err)
+	}
+
+	err = s.repo.InsertPushSubscription(ctx, repository.InsertPushSubscriptionParams{
+		ID:        id,
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.P256dh,
+		Auth:      sub.Auth,
+		AccountID: accountID,
+		GuestID:   guestID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert subscription: %w", err)
+	}
+
+	return nil
+}
+
+// Unsubscribe removes every subscription made from the browser.
+func (s *Service) Unsubscribe(ctx context.Context, endpoint string) error {
+	if err := s.repo.DeletePushSubscriptionsByEndpoint(ctx, endpoint); err != nil {
+		return fmt.Errorf("failed to delete subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+// GuestToken returns a token the visitor who left the message can
+// subscribe to notifications about it with.
+func (s *Service) GuestToken(guestID uuid.UUID) string {
+	return s.signer.Sign("push:" + guestID.String())
+}
+
+// VerifyGuestToken returns the message ID a token from GuestToken was made
+// for.
+func (s *Service) VerifyGuestToken(token string) (uuid.UUID, bool) {
+	value, ok := s.signer.Verify(token)
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	raw, ok := strings.CutPrefix(value, "push:")
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+// NotifyAccount queues the notification for the account's subscriptions.
+// The db may be a transaction, so it's only sent if the change it's about
+// is committed.
+func (s *Service) NotifyAccount(
+	ctx context.Context, db jobs.Inserter, accountID uuid.UUID, n Notification,
+) error {
+	return jobs.Enqueue(ctx, db, kindAccount, accountJob{
+		AccountID:    accountID,
+		Notification: n,
+	})
+}
+
+// NotifyGuests queues the notification for the subscriptions made by the
+// visitors who left the messages.
+func (s *Service) NotifyGuests(
+	ctx context.Context, db jobs.Inserter, guestIDs []uuid.UUID, n Notification,
+) error {
+	if len(guestIDs) == 0 {
+		return nil
+	}
+
+	return jobs.Enqueue(ctx, db, kindGuests, guestsJob{
+		GuestIDs:     guestIDs,
+		Notification: n,
+	})
+}
+
+func (s *Service) fanOutAccount(ctx context.Context, payload []byte) error {
+	var job accountJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+
+	subs, err := s.repo.FindPushSubscriptionsByAccount(
+		ctx, uuid.NullUUID{UUID: job.AccountID, Valid: true},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to find subscriptions: %w", err)
+	}
+
+	return s.fanOut(ctx, subs, job.Notification)
+}
+
+func (s *Service) fanOutGuests(ctx context.Context, payload []byte) error {
+	var job guestsJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+
+	subs, err := s.repo.FindPushSubscriptionsByGuests(ctx, job.GuestIDs)
+	if err != nil {
+		return fmt.Errorf("failed to find subscriptions: %w", err)
+	}
+
+	return s.fanOut(ctx, subs, job.Notification)
+}
+
+// fanOut queues a send for each subscription, in a single transaction so
+// that retrying doesn't send any twice.
+func (s *Service) fanOut(
+	ctx context.Context, subs []repository.PushSubscription, n Notification,
+) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	repo := s.repo.WithTx(tx)
+
+	for _, sub := range subs {
+		err := jobs.Enqueue(ctx, repo, kindSend, sendJob{
+			SubscriptionID: sub.ID,
+			Notification:   n,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *Service) send(ctx context.Context, payload []byte) error {
+	var job sendJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+
+	sub, err := s.repo.FindPushSubscription(ctx, job.SubscriptionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Unsubscribed since the notification was queued
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to find subscription: %w", err)
+	}
+
+	data, err := json.Marshal(job.Notification)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("failed to encode notification: %w", err))
+	}
+
+	err = s.sender.Send(ctx, Subscription{
+		Endpoint: sub.Endpoint,
+		P256dh:   sub.P256dh,
+		Auth:     sub.Auth,
+	}, data)
+
+	switch {
+	case errors.Is(err, ErrGone):
+		s.logger.Info("removing expired push subscription", slog.String("id", sub.ID.String()))
+		return s.Unsubscribe(ctx, sub.Endpoint)
+	case errors.Is(err, ErrNotPublic):
+		// It can never be delivered, and was likely made to probe the
+		// server's network
+		s.logger.Warn("removing push subscription to a non-public address", slog.String("id", sub.ID.String()))
+		return s.Unsubscribe(ctx, sub.Endpoint)
+	case errors.Is(err, ErrTooLarge):
+		return jobs.Permanent(err)
+	}
+
+	return err
+}
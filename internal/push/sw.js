@@ -0,0 +1,15 @@
+// Shows the notifications sent by the guestbook, opening the page they're
+// about when clicked.
+self.addEventListener("push", (event) => {
+  const data = event.data ? event.data.json() : {}
+
+  event.waitUntil(self.registration.showNotification(data.title || "Guest Book", {
+    body: data.body,
+    data: { url: data.url || "/" },
+  }))
+})
+
+self.addEventListener("notificationclick", (event) => {
+  event.notification.close()
+  event.waitUntil(self.clients.openWindow(event.notification.data.url))
+})
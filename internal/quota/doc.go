@@ -0,0 +1,3 @@
+// Package quota defines the plans an account can be on, the limits each
+// plan allows, and how usage is measured against them per billing period.
+package quota
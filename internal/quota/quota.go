@@ -0,0 +1,223 @@
+package quota
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Unlimited is used as a limit when there is no limit.
+const Unlimited int64 = -1
+
+// WarnRatio is how much of a limit can be used before the owner is warned.
+const WarnRatio = 0.8
+
+// Metric is a kind of usage counted per billing period.
+type Metric string
+
+const (
+	// Messages counts the messages left in an account's guestbooks.
+	Messages Metric = "messages"
+	// Storage counts the bytes stored for an account's messages.
+	Storage Metric = "storage_bytes"
+	// APICalls counts requests made to the API on an account's behalf.
+	APICalls Metric = "api_calls"
+)
+
+// Limits are the most an account may use. Guestbooks is a total, while
+// the others are per billing period.
+type Limits struct {
+	Guestbooks   int64
+	Messages     int64
+	StorageBytes int64
+	APICalls     int64
+}
+
+// Metric returns the limit for the given metric.
+func (l Limits) Metric(m Metric) int64 {
+	switch m {
+	case Messages:
+		return l.Messages
+	case Storage:
+		return l.StorageBytes
+	case APICalls:
+		return l.APICalls
+	}
+
+	return Unlimited
+}
+
+// Overrides replace individual limits of a plan for one account. Nil
+// fields keep the plan's limit.
+type Overrides struct {
+	Guestbooks   *int64
+	Messages     *int64
+	StorageBytes *int64
+	APICalls     *int64
+}
+
+// DefaultPlan is the plan new accounts start on, and the plan used for
+// any unrecognised plan name.
+const DefaultPlan = "free"
+
+// Plans are the plans available to accounts, by name.
+var Plans = map[string]Limits{
+	"free": {
+		Guestbooks:   3,
+		Messages:     1_000,
+		StorageBytes: 10 << 20,
+		APICalls:     10_000,
+	},
+	"pro": {
+		Guestbooks:   25,
+		Messages:     50_000,
+		StorageBytes: 1 << 30,
+		APICalls:     1_000_000,
+	},
+	"unlimited": {
+		Guestbooks:   Unlimited,
+		Messages:     Unlimited,
+		StorageBytes: Unlimited,
+		APICalls:     Unlimited,
+	},
+}
+
+// PlanNames returns the names of the available plans, sorted.
+func PlanNames() []string {
+	names := make([]string, 0, len(Plans))
+	for name := range Plans {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Resolve returns the limits for an account on the given plan with the
+// given overrides.
+func Resolve(plan string, o Overrides) Limits {
+	limits, ok := Plans[plan]
+	if !ok {
+		limits = Plans[DefaultPlan]
+	}
+
+	apply := func(limit *int64, override *int64) {
+		if override != nil {
+			*limit = *override
+		}
+	}
+
+	apply(&limits.Guestbooks, o.Guestbooks)
+	apply(&limits.Messages, o.Messages)
+	apply(&limits.StorageBytes, o.StorageBytes)
+	apply(&limits.APICalls, o.APICalls)
+
+	return limits
+}
+
+// Period returns the start of the billing period containing t. Periods
+// are calendar months in UTC.
+func Period(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Allows reports whether using n more would stay within the limit.
+func Allows(limit int64, used int64, n int64) bool {
+	return limit == Unlimited || used+n <= limit
+}
+
+// Status describes how close usage is to its limit.
+type Status int
+
+const (
+	OK Status = iota
+	Warning
+	Exceeded
+)
+
+// Check returns the status of the usage against the limit.
+func Check(limit int64, used int64) Status {
+	switch {
+	case limit == Unlimited:
+		return OK
+	case used >= limit:
+		return Exceeded
+	case float64(used) >= float64(limit)*WarnRatio:
+		return Warning
+	}
+
+	return OK
+}
+
+// Line is one row of a usage report.
+type Line struct {
+	Name   string
+	Used   int64
+	Limit  int64
+	Bytes  bool
+	Status Status
+}
+
+// Warning reports whether the line is close to its limit.
+func (l Line) Warning() bool {
+	return l.Status == Warning
+}
+
+// Exceeded reports whether the line has reached its limit.
+func (l Line) Exceeded() bool {
+	return l.Status == Exceeded
+}
+
+// String formats the line's usage, such as "12 / 1000".
+func (l Line) String() string {
+	format := func(n int64) string {
+		if l.Bytes {
+			return FormatBytes(n)
+		}
+		return fmt.Sprint(n)
+	}
+
+	if l.Limit == Unlimited {
+		return format(l.Used) + " / unlimited"
+	}
+
+	return format(l.Used) + " / " + format(l.Limit)
+}
+
+// Report summarises an account's usage against its limits.
+func Report(limits Limits, guestbooks int64, usage map[Metric]int64) []Line {
+	line := func(name string, used int64, limit int64, bytes bool) Line {
+		return Line{
+			Name:   name,
+			Used:   used,
+			Limit:  limit,
+			Bytes:  bytes,
+			Status: Check(limit, used),
+		}
+	}
+
+	return []Line{
+		line("Guestbooks", guestbooks, limits.Guestbooks, false),
+		line("Messages this month", usage[Messages], limits.Messages, false),
+		line("Storage this month", usage[Storage], limits.StorageBytes, true),
+		line("API calls this month", usage[APICalls], limits.APICalls, false),
+	}
+}
+
+// FormatBytes formats a number of bytes for display, such as "1.5 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
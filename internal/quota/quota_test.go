@@ -0,0 +1,104 @@
+package quota_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/quota"
+)
+
+func TestResolve(t *testing.T) {
+	messages := int64(5)
+	unlimited := quota.Unlimited
+
+	testCases := []struct {
+		Description string
+		Plan        string
+		Overrides   quota.Overrides
+		Expected    quota.Limits
+	}{
+		{
+			Description: "plan limits",
+			Plan:        "pro",
+			Expected:    quota.Plans["pro"],
+		},
+		{
+			Description: "unknown plan uses the default",
+			Plan:        "enterprise",
+			Expected:    quota.Plans[quota.DefaultPlan],
+		},
+		{
+			Description: "overrides replace individual limits",
+			Plan:        "free",
+			Overrides: quota.Overrides{
+				Messages:   &messages,
+				Guestbooks: &unlimited,
+			},
+			Expected: quota.Limits{
+				Guestbooks:   quota.Unlimited,
+				Messages:     5,
+				StorageBytes: quota.Plans["free"].StorageBytes,
+				APICalls:     quota.Plans["free"].APICalls,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, quota.Resolve(tc.Plan, tc.Overrides))
+		})
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	loc := time.FixedZone("UTC+10", 10*60*60)
+
+	assert.Equal(
+		t,
+		time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC),
+		quota.Period(time.Date(2026, time.October, 1, 8, 0, 0, 0, loc)),
+	)
+	assert.Equal(
+		t,
+		time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC),
+		quota.Period(time.Date(2026, time.October, 31, 23, 59, 0, 0, time.UTC)),
+	)
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Limit       int64
+		Used        int64
+		Expected    quota.Status
+	}{
+		{Description: "well within", Limit: 100, Used: 10, Expected: quota.OK},
+		{Description: "close to the limit", Limit: 100, Used: 80, Expected: quota.Warning},
+		{Description: "at the limit", Limit: 100, Used: 100, Expected: quota.Exceeded},
+		{Description: "unlimited", Limit: quota.Unlimited, Used: 1 << 40, Expected: quota.OK},
+		{Description: "zero limit", Limit: 0, Used: 0, Expected: quota.Exceeded},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, quota.Check(tc.Limit, tc.Used))
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	assert.True(t, quota.Allows(10, 9, 1))
+	assert.False(t, quota.Allows(10, 10, 1))
+	assert.True(t, quota.Allows(quota.Unlimited, 10, 1))
+}
+
+func TestLineString(t *testing.T) {
+	assert.Equal(t, "12 / 1000", quota.Line{Used: 12, Limit: 1000}.String())
+	assert.Equal(t, "12 / unlimited", quota.Line{Used: 12, Limit: quota.Unlimited}.String())
+	assert.Equal(
+		t, "1.5 KB / 10.0 MB",
+		quota.Line{Used: 1536, Limit: 10 << 20, Bytes: true}.String(),
+	)
+}
@@ -9,12 +9,149 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-type Guest struct {
+type Account struct {
+	ID                uuid.UUID
+	Email             string
+	PasswordHash      string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Admin             bool
+	Plan              string
+	QuotaGuestbooks   pgtype.Int8
+	QuotaMessages     pgtype.Int8
+	QuotaStorageBytes pgtype.Int8
+	QuotaApiCalls     pgtype.Int8
+}
+
+type AuditLog struct {
 	ID        uuid.UUID
-	Message   string
-	Ip        net.IP
+	AccountID uuid.NullUUID
+	Action    string
+	Query     string
+	Affected  int64
+	CreatedAt time.Time
+}
+
+type Ban struct {
+	Network   net.IP
+	Reason    string
 	CreatedAt time.Time
+}
+
+type BlockedTag struct {
+	GuestbookID uuid.UUID
+	Tag         string
+	CreatedAt   time.Time
+}
+
+type CertificateCache struct {
+	Key       string
+	Data      []byte
 	UpdatedAt time.Time
 }
+
+type Guest struct {
+	ID            uuid.UUID
+	Message       string
+	Ip            net.IP
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	GuestbookID   uuid.UUID
+	Private       bool
+	SealedMessage []byte
+	Fields        []byte
+	Status        string
+	Fingerprint   string
+	SealedFields  []byte
+}
+
+type GuestTag struct {
+	GuestID     uuid.UUID
+	GuestbookID uuid.UUID
+	Tag         string
+}
+
+type Guestbook struct {
+	ID               uuid.UUID
+	Slug             string
+	Title            string
+	Visibility       string
+	AccessCodeHash   string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	OwnerID          uuid.NullUUID
+	FieldDefinitions []byte
+	Theme            string
+	CustomCss        string
+}
+
+type GuestbookEmail struct {
+	GuestbookID    uuid.UUID
+	AllowedSenders []string
+	UpdatedAt      time.Time
+}
+
+type GuestbookTraffic struct {
+	GuestbookID uuid.UUID
+	Day         time.Time
+	Views       int64
+	Visitors    int64
+}
+
+type GuestbookViewer struct {
+	GuestbookID uuid.UUID
+	AccountID   uuid.UUID
+	CreatedAt   time.Time
+}
+
+type Job struct {
+	ID        int64
+	Kind      string
+	Payload   []byte
+	RunAt     time.Time
+	Attempts  int32
+	LastError string
+	CreatedAt time.Time
+}
+
+type Usage struct {
+	AccountID uuid.UUID
+	Period    time.Time
+	Metric    string
+	Amount    int64
+}
+
+type PushSubscription struct {
+	ID        uuid.UUID
+	Endpoint  string
+	P256dh    []byte
+	Auth      []byte
+	AccountID uuid.NullUUID
+	GuestID   uuid.NullUUID
+	CreatedAt time.Time
+}
+
+type RateLimitHit struct {
+	ID          int64
+	Ip          net.IP
+	Fingerprint string
+	Path        string
+	CreatedAt   time.Time
+}
+
+type Report struct {
+	ID         uuid.UUID
+	GuestID    uuid.UUID
+	ReporterIp net.IP
+	Reason     string
+	CreatedAt  time.Time
+}
+
+type VapidKey struct {
+	ID         bool
+	PrivateKey []byte
+	CreatedAt  time.Time
+}
@@ -8,44 +8,1087 @@ package repository
 import (
 	"context"
 	"net"
+	"net/netip"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const blockTag = `-- name: BlockTag :exec
+INSERT INTO blocked_tag (guestbook_id, tag, created_at)
+VALUES ($1, $2, now())
+ON CONFLICT DO NOTHING
+`
+
+type BlockTagParams struct {
+	GuestbookID uuid.UUID
+	Tag         string
+}
+
+func (q *Queries) BlockTag(ctx context.Context, arg BlockTagParams) error {
+	_, err := q.db.Exec(ctx, blockTag, arg.GuestbookID, arg.Tag)
+	return err
+}
+
+const claimGuestbook = `-- name: ClaimGuestbook :exec
+UPDATE guestbook
+SET owner_id = $2, updated_at = now()
+WHERE slug = $1 AND owner_id IS NULL
+`
+
+type ClaimGuestbookParams struct {
+	Slug    string
+	OwnerID uuid.NullUUID
+}
+
+func (q *Queries) ClaimGuestbook(ctx context.Context, arg ClaimGuestbookParams) error {
+	_, err := q.db.Exec(ctx, claimGuestbook, arg.Slug, arg.OwnerID)
+	return err
+}
+
+const claimJob = `-- name: ClaimJob :one
+UPDATE job
+SET run_at = $1, attempts = attempts + 1
+WHERE id = (
+  SELECT id FROM job
+  WHERE run_at <= NOW()
+  ORDER BY run_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, payload, run_at, attempts, last_error, created_at
+`
+
+func (q *Queries) ClaimJob(ctx context.Context, leaseUntil time.Time) (Job, error) {
+	row := q.db.QueryRow(ctx, claimJob, leaseUntil)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Payload,
+		&i.RunAt,
+		&i.Attempts,
+		&i.LastError,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const count = `-- name: Count :one
 SELECT COUNT(*) FROM guest
+WHERE guestbook_id = $1 AND NOT private AND status = 'approved'
+`
+
+func (q *Queries) Count(ctx context.Context, guestbookID uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, count, guestbookID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countGuestbooksByOwner = `-- name: CountGuestbooksByOwner :one
+SELECT COUNT(*)
+FROM guestbook
+WHERE owner_id = $1
+`
+
+func (q *Queries) CountGuestbooksByOwner(ctx context.Context, ownerID uuid.NullUUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countGuestbooksByOwner, ownerID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countGuestbooksGroupedByOwner = `-- name: CountGuestbooksGroupedByOwner :many
+SELECT owner_id, COUNT(*) AS count
+FROM guestbook
+WHERE owner_id IS NOT NULL
+GROUP BY owner_id
+`
+
+type CountGuestbooksGroupedByOwnerRow struct {
+	OwnerID uuid.NullUUID
+	Count   int64
+}
+
+func (q *Queries) CountGuestbooksGroupedByOwner(ctx context.Context) ([]CountGuestbooksGroupedByOwnerRow, error) {
+	rows, err := q.db.Query(ctx, countGuestbooksGroupedByOwner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountGuestbooksGroupedByOwnerRow
+	for rows.Next() {
+		var i CountGuestbooksGroupedByOwnerRow
+		if err := rows.Scan(&i.OwnerID, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countTags = `-- name: CountTags :many
+SELECT tag, COUNT(*) AS count
+FROM guest_tag
+JOIN guest ON guest.id = guest_tag.guest_id
+WHERE guest_tag.guestbook_id = $1 AND guest.status = 'approved'
+  AND tag NOT IN (
+    SELECT blocked_tag.tag FROM blocked_tag
+    WHERE blocked_tag.guestbook_id = $1
+  )
+GROUP BY tag
+ORDER BY count DESC, tag
+LIMIT $2
+`
+
+type CountTagsParams struct {
+	GuestbookID uuid.UUID
+	Limit       int32
+}
+
+type CountTagsRow struct {
+	Tag   string
+	Count int64
+}
+
+func (q *Queries) CountTags(ctx context.Context, arg CountTagsParams) ([]CountTagsRow, error) {
+	rows, err := q.db.Query(ctx, countTags, arg.GuestbookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountTagsRow
+	for rows.Next() {
+		var i CountTagsRow
+		if err := rows.Scan(&i.Tag, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteCachedCertificate = `-- name: DeleteCachedCertificate :exec
+DELETE FROM certificate_cache WHERE key = $1
+`
+
+func (q *Queries) DeleteCachedCertificate(ctx context.Context, key string) error {
+	_, err := q.db.Exec(ctx, deleteCachedCertificate, key)
+	return err
+}
+
+const deleteGuestbookEmail = `-- name: DeleteGuestbookEmail :exec
+DELETE FROM guestbook_email WHERE guestbook_id = $1
+`
+
+func (q *Queries) DeleteGuestbookEmail(ctx context.Context, guestbookID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteGuestbookEmail, guestbookID)
+	return err
+}
+
+const deleteGuestbookViewer = `-- name: DeleteGuestbookViewer :exec
+DELETE FROM guestbook_viewer WHERE guestbook_id = $1 AND account_id = $2
+`
+
+type DeleteGuestbookViewerParams struct {
+	GuestbookID uuid.UUID
+	AccountID   uuid.UUID
+}
+
+func (q *Queries) DeleteGuestbookViewer(ctx context.Context, arg DeleteGuestbookViewerParams) error {
+	_, err := q.db.Exec(ctx, deleteGuestbookViewer, arg.GuestbookID, arg.AccountID)
+	return err
+}
+
+const deleteJob = `-- name: DeleteJob :exec
+DELETE FROM job WHERE id = $1
+`
+
+func (q *Queries) DeleteJob(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, deleteJob, id)
+	return err
+}
+
+const deletePushSubscriptionsByEndpoint = `-- name: DeletePushSubscriptionsByEndpoint :exec
+DELETE FROM push_subscription WHERE endpoint = $1
+`
+
+func (q *Queries) DeletePushSubscriptionsByEndpoint(ctx context.Context, endpoint string) error {
+	_, err := q.db.Exec(ctx, deletePushSubscriptionsByEndpoint, endpoint)
+	return err
+}
+
+const findAccount = `-- name: FindAccount :one
+SELECT id, email, password_hash, created_at, updated_at, admin, plan, quota_guestbooks, quota_messages, quota_storage_bytes, quota_api_calls
+FROM account
+WHERE id = $1
+`
+
+func (q *Queries) FindAccount(ctx context.Context, id uuid.UUID) (Account, error) {
+	row := q.db.QueryRow(ctx, findAccount, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Admin,
+		&i.Plan,
+		&i.QuotaGuestbooks,
+		&i.QuotaMessages,
+		&i.QuotaStorageBytes,
+		&i.QuotaApiCalls,
+	)
+	return i, err
+}
+
+const findAccountByEmail = `-- name: FindAccountByEmail :one
+SELECT id, email, password_hash, created_at, updated_at, admin, plan, quota_guestbooks, quota_messages, quota_storage_bytes, quota_api_calls
+FROM account
+WHERE email = $1
+`
+
+func (q *Queries) FindAccountByEmail(ctx context.Context, email string) (Account, error) {
+	row := q.db.QueryRow(ctx, findAccountByEmail, email)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Admin,
+		&i.Plan,
+		&i.QuotaGuestbooks,
+		&i.QuotaMessages,
+		&i.QuotaStorageBytes,
+		&i.QuotaApiCalls,
+	)
+	return i, err
+}
+
+const findAccountForUpdate = `-- name: FindAccountForUpdate :one
+SELECT id, email, password_hash, created_at, updated_at, admin, plan, quota_guestbooks, quota_messages, quota_storage_bytes, quota_api_calls
+FROM account
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) FindAccountForUpdate(ctx context.Context, id uuid.UUID) (Account, error) {
+	row := q.db.QueryRow(ctx, findAccountForUpdate, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Admin,
+		&i.Plan,
+		&i.QuotaGuestbooks,
+		&i.QuotaMessages,
+		&i.QuotaStorageBytes,
+		&i.QuotaApiCalls,
+	)
+	return i, err
+}
+
+const findAccounts = `-- name: FindAccounts :many
+SELECT id, email, password_hash, created_at, updated_at, admin, plan, quota_guestbooks, quota_messages, quota_storage_bytes, quota_api_calls
+FROM account
+ORDER BY email
+`
+
+func (q *Queries) FindAccounts(ctx context.Context) ([]Account, error) {
+	rows, err := q.db.Query(ctx, findAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Account
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.PasswordHash,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Admin,
+			&i.Plan,
+			&i.QuotaGuestbooks,
+			&i.QuotaMessages,
+			&i.QuotaStorageBytes,
+			&i.QuotaApiCalls,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findAll = `-- name: FindAll :many
+SELECT id, message, ip, created_at, updated_at, guestbook_id, private, sealed_message, fields, status, fingerprint, sealed_fields
+FROM guest
+WHERE guestbook_id = $1 AND NOT private AND status = 'approved'
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type FindAllParams struct {
+	GuestbookID uuid.UUID
+	Limit       int32
+}
+
+func (q *Queries) FindAll(ctx context.Context, arg FindAllParams) ([]Guest, error) {
+	rows, err := q.db.Query(ctx, findAll, arg.GuestbookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Guest
+	for rows.Next() {
+		var i Guest
+		if err := rows.Scan(
+			&i.ID,
+			&i.Message,
+			&i.Ip,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.GuestbookID,
+			&i.Private,
+			&i.SealedMessage,
+			&i.Fields,
+			&i.Status,
+			&i.Fingerprint,
+			&i.SealedFields,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findAllByTag = `-- name: FindAllByTag :many
+SELECT guest.id, guest.message, guest.ip, guest.created_at, guest.updated_at, guest.guestbook_id, guest.private, guest.sealed_message, guest.fields, guest.status, guest.fingerprint, guest.sealed_fields
+FROM guest
+JOIN guest_tag ON guest_tag.guest_id = guest.id
+WHERE guest_tag.guestbook_id = $1 AND guest_tag.tag = $2 AND NOT guest.private
+  AND guest.status = 'approved'
+ORDER BY guest.created_at DESC
+LIMIT $3
+`
+
+type FindAllByTagParams struct {
+	GuestbookID uuid.UUID
+	Tag         string
+	Limit       int32
+}
+
+func (q *Queries) FindAllByTag(ctx context.Context, arg FindAllByTagParams) ([]Guest, error) {
+	rows, err := q.db.Query(ctx, findAllByTag, arg.GuestbookID, arg.Tag, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Guest
+	for rows.Next() {
+		var i Guest
+		if err := rows.Scan(
+			&i.ID,
+			&i.Message,
+			&i.Ip,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.GuestbookID,
+			&i.Private,
+			&i.SealedMessage,
+			&i.Fields,
+			&i.Status,
+			&i.Fingerprint,
+			&i.SealedFields,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findAllWithPrivate = `-- name: FindAllWithPrivate :many
+SELECT id, message, ip, created_at, updated_at, guestbook_id, private, sealed_message, fields, status, fingerprint, sealed_fields
+FROM guest
+WHERE guestbook_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type FindAllWithPrivateParams struct {
+	GuestbookID uuid.UUID
+	Limit       int32
+}
+
+func (q *Queries) FindAllWithPrivate(ctx context.Context, arg FindAllWithPrivateParams) ([]Guest, error) {
+	rows, err := q.db.Query(ctx, findAllWithPrivate, arg.GuestbookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Guest
+	for rows.Next() {
+		var i Guest
+		if err := rows.Scan(
+			&i.ID,
+			&i.Message,
+			&i.Ip,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.GuestbookID,
+			&i.Private,
+			&i.SealedMessage,
+			&i.Fields,
+			&i.Status,
+			&i.Fingerprint,
+			&i.SealedFields,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findAuditLog = `-- name: FindAuditLog :many
+SELECT audit_log.id, audit_log.action, audit_log.query, audit_log.affected,
+  audit_log.created_at, account.email
+FROM audit_log
+LEFT JOIN account ON account.id = audit_log.account_id
+ORDER BY audit_log.created_at DESC
+LIMIT $1
+`
+
+type FindAuditLogRow struct {
+	ID        uuid.UUID
+	Action    string
+	Query     string
+	Affected  int64
+	CreatedAt time.Time
+	Email     pgtype.Text
+}
+
+func (q *Queries) FindAuditLog(ctx context.Context, limit int32) ([]FindAuditLogRow, error) {
+	rows, err := q.db.Query(ctx, findAuditLog, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindAuditLogRow
+	for rows.Next() {
+		var i FindAuditLogRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Action,
+			&i.Query,
+			&i.Affected,
+			&i.CreatedAt,
+			&i.Email,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findBansByFingerprint = `-- name: FindBansByFingerprint :many
+SELECT network, reason, created_at
+FROM ban
+WHERE EXISTS (
+  SELECT 1 FROM guest
+  WHERE guest.fingerprint = $1 AND guest.ip <<= ban.network
+)
+ORDER BY created_at DESC
+`
+
+func (q *Queries) FindBansByFingerprint(ctx context.Context, fingerprint string) ([]Ban, error) {
+	rows, err := q.db.Query(ctx, findBansByFingerprint, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Ban
+	for rows.Next() {
+		var i Ban
+		if err := rows.Scan(&i.Network, &i.Reason, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findBansByNetwork = `-- name: FindBansByNetwork :many
+SELECT network, reason, created_at
+FROM ban
+WHERE network && $1::cidr
+ORDER BY created_at DESC
+`
+
+func (q *Queries) FindBansByNetwork(ctx context.Context, network netip.Prefix) ([]Ban, error) {
+	rows, err := q.db.Query(ctx, findBansByNetwork, network)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Ban
+	for rows.Next() {
+		var i Ban
+		if err := rows.Scan(&i.Network, &i.Reason, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findBlockedTags = `-- name: FindBlockedTags :many
+SELECT tag
+FROM blocked_tag
+WHERE guestbook_id = $1
+ORDER BY tag
+`
+
+func (q *Queries) FindBlockedTags(ctx context.Context, guestbookID uuid.UUID) ([]string, error) {
+	rows, err := q.db.Query(ctx, findBlockedTags, guestbookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		items = append(items, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findCachedCertificate = `-- name: FindCachedCertificate :one
+SELECT data FROM certificate_cache WHERE key = $1
+`
+
+func (q *Queries) FindCachedCertificate(ctx context.Context, key string) ([]byte, error) {
+	row := q.db.QueryRow(ctx, findCachedCertificate, key)
+	var data []byte
+	err := row.Scan(&data)
+	return data, err
+}
+
+const findGuestbookBySlug = `-- name: FindGuestbookBySlug :one
+SELECT id, slug, title, visibility, access_code_hash, created_at, updated_at, owner_id, field_definitions, theme, custom_css
+FROM guestbook
+WHERE slug = $1
+`
+
+func (q *Queries) FindGuestbookBySlug(ctx context.Context, slug string) (Guestbook, error) {
+	row := q.db.QueryRow(ctx, findGuestbookBySlug, slug)
+	var i Guestbook
+	err := row.Scan(
+		&i.ID,
+		&i.Slug,
+		&i.Title,
+		&i.Visibility,
+		&i.AccessCodeHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.FieldDefinitions,
+		&i.Theme,
+		&i.CustomCss,
+	)
+	return i, err
+}
+
+const findGuestbookEmail = `-- name: FindGuestbookEmail :one
+SELECT guestbook_id, allowed_senders, updated_at FROM guestbook_email WHERE guestbook_id = $1
+`
+
+func (q *Queries) FindGuestbookEmail(ctx context.Context, guestbookID uuid.UUID) (GuestbookEmail, error) {
+	row := q.db.QueryRow(ctx, findGuestbookEmail, guestbookID)
+	var i GuestbookEmail
+	err := row.Scan(&i.GuestbookID, &i.AllowedSenders, &i.UpdatedAt)
+	return i, err
+}
+
+const findGuestbookViewers = `-- name: FindGuestbookViewers :many
+SELECT account.id, account.email
+FROM guestbook_viewer
+JOIN account ON account.id = guestbook_viewer.account_id
+WHERE guestbook_viewer.guestbook_id = $1
+ORDER BY account.email
+`
+
+type FindGuestbookViewersRow struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) FindGuestbookViewers(ctx context.Context, guestbookID uuid.UUID) ([]FindGuestbookViewersRow, error) {
+	rows, err := q.db.Query(ctx, findGuestbookViewers, guestbookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindGuestbookViewersRow
+	for rows.Next() {
+		var i FindGuestbookViewersRow
+		if err := rows.Scan(&i.ID, &i.Email); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findGuestbooksByOwner = `-- name: FindGuestbooksByOwner :many
+SELECT id, slug, title, visibility, access_code_hash, created_at, updated_at, owner_id, field_definitions, theme, custom_css
+FROM guestbook
+WHERE owner_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) FindGuestbooksByOwner(ctx context.Context, ownerID uuid.NullUUID) ([]Guestbook, error) {
+	rows, err := q.db.Query(ctx, findGuestbooksByOwner, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Guestbook
+	for rows.Next() {
+		var i Guestbook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Title,
+			&i.Visibility,
+			&i.AccessCodeHash,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.FieldDefinitions,
+			&i.Theme,
+			&i.CustomCss,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findPushSubscription = `-- name: FindPushSubscription :one
+SELECT id, endpoint, p256dh, auth, account_id, guest_id, created_at FROM push_subscription WHERE id = $1
+`
+
+func (q *Queries) FindPushSubscription(ctx context.Context, id uuid.UUID) (PushSubscription, error) {
+	row := q.db.QueryRow(ctx, findPushSubscription, id)
+	var i PushSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.Endpoint,
+		&i.P256dh,
+		&i.Auth,
+		&i.AccountID,
+		&i.GuestID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const findPushSubscriptionsByAccount = `-- name: FindPushSubscriptionsByAccount :many
+SELECT id, endpoint, p256dh, auth, account_id, guest_id, created_at FROM push_subscription WHERE account_id = $1
+`
+
+func (q *Queries) FindPushSubscriptionsByAccount(ctx context.Context, accountID uuid.NullUUID) ([]PushSubscription, error) {
+	rows, err := q.db.Query(ctx, findPushSubscriptionsByAccount, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PushSubscription
+	for rows.Next() {
+		var i PushSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.Endpoint,
+			&i.P256dh,
+			&i.Auth,
+			&i.AccountID,
+			&i.GuestID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findPushSubscriptionsByGuests = `-- name: FindPushSubscriptionsByGuests :many
+SELECT id, endpoint, p256dh, auth, account_id, guest_id, created_at FROM push_subscription WHERE guest_id = ANY($1::uuid[])
+`
+
+func (q *Queries) FindPushSubscriptionsByGuests(ctx context.Context, guestIds []uuid.UUID) ([]PushSubscription, error) {
+	rows, err := q.db.Query(ctx, findPushSubscriptionsByGuests, guestIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PushSubscription
+	for rows.Next() {
+		var i PushSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.Endpoint,
+			&i.P256dh,
+			&i.Auth,
+			&i.AccountID,
+			&i.GuestID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findRateLimitHitsByFingerprint = `-- name: FindRateLimitHitsByFingerprint :many
+SELECT id, ip, fingerprint, path, created_at
+FROM rate_limit_hit
+WHERE fingerprint = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type FindRateLimitHitsByFingerprintParams struct {
+	Fingerprint string
+	Limit       int32
+}
+
+func (q *Queries) FindRateLimitHitsByFingerprint(ctx context.Context, arg FindRateLimitHitsByFingerprintParams) ([]RateLimitHit, error) {
+	rows, err := q.db.Query(ctx, findRateLimitHitsByFingerprint, arg.Fingerprint, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RateLimitHit
+	for rows.Next() {
+		var i RateLimitHit
+		if err := rows.Scan(
+			&i.ID,
+			&i.Ip,
+			&i.Fingerprint,
+			&i.Path,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findRateLimitHitsByNetwork = `-- name: FindRateLimitHitsByNetwork :many
+SELECT id, ip, fingerprint, path, created_at
+FROM rate_limit_hit
+WHERE ip <<= $1::cidr
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type FindRateLimitHitsByNetworkParams struct {
+	Network netip.Prefix
+	Limit   int32
+}
+
+func (q *Queries) FindRateLimitHitsByNetwork(ctx context.Context, arg FindRateLimitHitsByNetworkParams) ([]RateLimitHit, error) {
+	rows, err := q.db.Query(ctx, findRateLimitHitsByNetwork, arg.Network, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RateLimitHit
+	for rows.Next() {
+		var i RateLimitHit
+		if err := rows.Scan(
+			&i.ID,
+			&i.Ip,
+			&i.Fingerprint,
+			&i.Path,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findReportsByFingerprint = `-- name: FindReportsByFingerprint :many
+SELECT report.id, report.guest_id, report.reporter_ip, report.reason,
+  report.created_at, guest.message, guest.private
+FROM report
+JOIN guest ON guest.id = report.guest_id
+WHERE guest.fingerprint = $1
+ORDER BY report.created_at DESC
+LIMIT $2
+`
+
+type FindReportsByFingerprintParams struct {
+	Fingerprint string
+	Limit       int32
+}
+
+type FindReportsByFingerprintRow struct {
+	ID         uuid.UUID
+	GuestID    uuid.UUID
+	ReporterIp net.IP
+	Reason     string
+	CreatedAt  time.Time
+	Message    string
+	Private    bool
+}
+
+func (q *Queries) FindReportsByFingerprint(ctx context.Context, arg FindReportsByFingerprintParams) ([]FindReportsByFingerprintRow, error) {
+	rows, err := q.db.Query(ctx, findReportsByFingerprint, arg.Fingerprint, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindReportsByFingerprintRow
+	for rows.Next() {
+		var i FindReportsByFingerprintRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.GuestID,
+			&i.ReporterIp,
+			&i.Reason,
+			&i.CreatedAt,
+			&i.Message,
+			&i.Private,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findReportsByNetwork = `-- name: FindReportsByNetwork :many
+SELECT report.id, report.guest_id, report.reporter_ip, report.reason,
+  report.created_at, guest.message, guest.private
+FROM report
+JOIN guest ON guest.id = report.guest_id
+WHERE guest.ip <<= $1::cidr
+ORDER BY report.created_at DESC
+LIMIT $2
+`
+
+type FindReportsByNetworkParams struct {
+	Network netip.Prefix
+	Limit   int32
+}
+
+type FindReportsByNetworkRow struct {
+	ID         uuid.UUID
+	GuestID    uuid.UUID
+	ReporterIp net.IP
+	Reason     string
+	CreatedAt  time.Time
+	Message    string
+	Private    bool
+}
+
+func (q *Queries) FindReportsByNetwork(ctx context.Context, arg FindReportsByNetworkParams) ([]FindReportsByNetworkRow, error) {
+	rows, err := q.db.Query(ctx, findReportsByNetwork, arg.Network, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindReportsByNetworkRow
+	for rows.Next() {
+		var i FindReportsByNetworkRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.GuestID,
+			&i.ReporterIp,
+			&i.Reason,
+			&i.CreatedAt,
+			&i.Message,
+			&i.Private,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findTraffic = `-- name: FindTraffic :many
+SELECT guestbook_id, day, views, visitors
+FROM guestbook_traffic
+WHERE guestbook_id = $1 AND day >= $2
+ORDER BY day DESC
 `
 
-func (q *Queries) Count(ctx context.Context) (int64, error) {
-	row := q.db.QueryRow(ctx, count)
-	var count int64
-	err := row.Scan(&count)
-	return count, err
+type FindTrafficParams struct {
+	GuestbookID uuid.UUID
+	Day         time.Time
 }
 
-const findAll = `-- name: FindAll :many
-SELECT id, message, ip, created_at, updated_at
-FROM guest
-ORDER BY created_at DESC
-LIMIT $1
+func (q *Queries) FindTraffic(ctx context.Context, arg FindTrafficParams) ([]GuestbookTraffic, error) {
+	rows, err := q.db.Query(ctx, findTraffic, arg.GuestbookID, arg.Day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GuestbookTraffic
+	for rows.Next() {
+		var i GuestbookTraffic
+		if err := rows.Scan(
+			&i.GuestbookID,
+			&i.Day,
+			&i.Views,
+			&i.Visitors,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findUsage = `-- name: FindUsage :many
+SELECT account_id, period, metric, amount
+FROM usage
+WHERE account_id = $1 AND period = $2
 `
 
-func (q *Queries) FindAll(ctx context.Context, limit int32) ([]Guest, error) {
-	rows, err := q.db.Query(ctx, findAll, limit)
+type FindUsageParams struct {
+	AccountID uuid.UUID
+	Period    time.Time
+}
+
+func (q *Queries) FindUsage(ctx context.Context, arg FindUsageParams) ([]Usage, error) {
+	rows, err := q.db.Query(ctx, findUsage, arg.AccountID, arg.Period)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Guest
+	var items []Usage
 	for rows.Next() {
-		var i Guest
+		var i Usage
 		if err := rows.Scan(
-			&i.ID,
-			&i.Message,
-			&i.Ip,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.AccountID,
+			&i.Period,
+			&i.Metric,
+			&i.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findUsageByPeriod = `-- name: FindUsageByPeriod :many
+SELECT account_id, period, metric, amount
+FROM usage
+WHERE period = $1
+`
+
+func (q *Queries) FindUsageByPeriod(ctx context.Context, period time.Time) ([]Usage, error) {
+	rows, err := q.db.Query(ctx, findUsageByPeriod, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Usage
+	for rows.Next() {
+		var i Usage
+		if err := rows.Scan(
+			&i.AccountID,
+			&i.Period,
+			&i.Metric,
+			&i.Amount,
 		); err != nil {
 			return nil, err
 		}
@@ -57,17 +1100,65 @@ func (q *Queries) FindAll(ctx context.Context, limit int32) ([]Guest, error) {
 	return items, nil
 }
 
+const findVapidKey = `-- name: FindVapidKey :one
+SELECT private_key FROM vapid_key
+`
+
+func (q *Queries) FindVapidKey(ctx context.Context) ([]byte, error) {
+	row := q.db.QueryRow(ctx, findVapidKey)
+	var private_key []byte
+	err := row.Scan(&private_key)
+	return private_key, err
+}
+
+const incrementUsage = `-- name: IncrementUsage :one
+INSERT INTO usage (account_id, period, metric, amount)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (account_id, period, metric) DO UPDATE
+SET amount = usage.amount + EXCLUDED.amount
+RETURNING amount
+`
+
+type IncrementUsageParams struct {
+	AccountID uuid.UUID
+	Period    time.Time
+	Metric    string
+	Amount    int64
+}
+
+func (q *Queries) IncrementUsage(ctx context.Context, arg IncrementUsageParams) (int64, error) {
+	row := q.db.QueryRow(ctx, incrementUsage,
+		arg.AccountID,
+		arg.Period,
+		arg.Metric,
+		arg.Amount,
+	)
+	var amount int64
+	err := row.Scan(&amount)
+	return amount, err
+}
+
 const insert = `-- name: Insert :one
-INSERT INTO guest (id, message, created_at, updated_at, ip)
-VALUES ($1, $2, $3, $3, $4)
-RETURNING id, message, ip, created_at, updated_at
+INSERT INTO guest (
+  id, message, created_at, updated_at, ip, guestbook_id, private,
+  sealed_message, fields, fingerprint, status, sealed_fields
+)
+VALUES ($1, $2, $3, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING id, message, ip, created_at, updated_at, guestbook_id, private, sealed_message, fields, status, fingerprint, sealed_fields
 `
 
 type InsertParams struct {
-	ID        uuid.UUID
-	Message   string
-	CreatedAt time.Time
-	Ip        net.IP
+	ID            uuid.UUID
+	Message       string
+	CreatedAt     time.Time
+	Ip            net.IP
+	GuestbookID   uuid.UUID
+	Private       bool
+	SealedMessage []byte
+	Fields        []byte
+	Fingerprint   string
+	Status        string
+	SealedFields  []byte
 }
 
 func (q *Queries) Insert(ctx context.Context, arg InsertParams) (Guest, error) {
@@ -76,6 +1167,13 @@ func (q *Queries) Insert(ctx context.Context, arg InsertParams) (Guest, error) {
 		arg.Message,
 		arg.CreatedAt,
 		arg.Ip,
+		arg.GuestbookID,
+		arg.Private,
+		arg.SealedMessage,
+		arg.Fields,
+		arg.Fingerprint,
+		arg.Status,
+		arg.SealedFields,
 	)
 	var i Guest
 	err := row.Scan(
@@ -84,6 +1182,530 @@ func (q *Queries) Insert(ctx context.Context, arg InsertParams) (Guest, error) {
 		&i.Ip,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.GuestbookID,
+		&i.Private,
+		&i.SealedMessage,
+		&i.Fields,
+		&i.Status,
+		&i.Fingerprint,
+		&i.SealedFields,
+	)
+	return i, err
+}
+
+const insertAccount = `-- name: InsertAccount :one
+INSERT INTO account (id, email, password_hash, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $4)
+RETURNING id, email, password_hash, created_at, updated_at, admin, plan, quota_guestbooks, quota_messages, quota_storage_bytes, quota_api_calls
+`
+
+type InsertAccountParams struct {
+	ID           uuid.UUID
+	Email        string
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+func (q *Queries) InsertAccount(ctx context.Context, arg InsertAccountParams) (Account, error) {
+	row := q.db.QueryRow(ctx, insertAccount,
+		arg.ID,
+		arg.Email,
+		arg.PasswordHash,
+		arg.CreatedAt,
+	)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Admin,
+		&i.Plan,
+		&i.QuotaGuestbooks,
+		&i.QuotaMessages,
+		&i.QuotaStorageBytes,
+		&i.QuotaApiCalls,
+	)
+	return i, err
+}
+
+const insertAuditLog = `-- name: InsertAuditLog :exec
+INSERT INTO audit_log (id, account_id, action, query, affected, created_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type InsertAuditLogParams struct {
+	ID        uuid.UUID
+	AccountID uuid.NullUUID
+	Action    string
+	Query     string
+	Affected  int64
+	CreatedAt time.Time
+}
+
+func (q *Queries) InsertAuditLog(ctx context.Context, arg InsertAuditLogParams) error {
+	_, err := q.db.Exec(ctx, insertAuditLog,
+		arg.ID,
+		arg.AccountID,
+		arg.Action,
+		arg.Query,
+		arg.Affected,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const insertBan = `-- name: InsertBan :exec
+INSERT INTO ban (network, reason, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type InsertBanParams struct {
+	Network   net.IP
+	Reason    string
+	CreatedAt time.Time
+}
+
+func (q *Queries) InsertBan(ctx context.Context, arg InsertBanParams) error {
+	_, err := q.db.Exec(ctx, insertBan, arg.Network, arg.Reason, arg.CreatedAt)
+	return err
+}
+
+const insertGuestTag = `-- name: InsertGuestTag :exec
+INSERT INTO guest_tag (guest_id, guestbook_id, tag)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type InsertGuestTagParams struct {
+	GuestID     uuid.UUID
+	GuestbookID uuid.UUID
+	Tag         string
+}
+
+func (q *Queries) InsertGuestTag(ctx context.Context, arg InsertGuestTagParams) error {
+	_, err := q.db.Exec(ctx, insertGuestTag, arg.GuestID, arg.GuestbookID, arg.Tag)
+	return err
+}
+
+const insertGuestbook = `-- name: InsertGuestbook :one
+INSERT INTO guestbook (
+  id, slug, title, visibility, access_code_hash, owner_id, created_at,
+  updated_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+RETURNING id, slug, title, visibility, access_code_hash, created_at, updated_at, owner_id, field_definitions, theme, custom_css
+`
+
+type InsertGuestbookParams struct {
+	ID             uuid.UUID
+	Slug           string
+	Title          string
+	Visibility     string
+	AccessCodeHash string
+	OwnerID        uuid.NullUUID
+	CreatedAt      time.Time
+}
+
+func (q *Queries) InsertGuestbook(ctx context.Context, arg InsertGuestbookParams) (Guestbook, error) {
+	row := q.db.QueryRow(ctx, insertGuestbook,
+		arg.ID,
+		arg.Slug,
+		arg.Title,
+		arg.Visibility,
+		arg.AccessCodeHash,
+		arg.OwnerID,
+		arg.CreatedAt,
+	)
+	var i Guestbook
+	err := row.Scan(
+		&i.ID,
+		&i.Slug,
+		&i.Title,
+		&i.Visibility,
+		&i.AccessCodeHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.FieldDefinitions,
+		&i.Theme,
+		&i.CustomCss,
+	)
+	return i, err
+}
+
+const insertGuestbookViewer = `-- name: InsertGuestbookViewer :exec
+INSERT INTO guestbook_viewer (guestbook_id, account_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING
+`
+
+type InsertGuestbookViewerParams struct {
+	GuestbookID uuid.UUID
+	AccountID   uuid.UUID
+}
+
+func (q *Queries) InsertGuestbookViewer(ctx context.Context, arg InsertGuestbookViewerParams) error {
+	_, err := q.db.Exec(ctx, insertGuestbookViewer, arg.GuestbookID, arg.AccountID)
+	return err
+}
+
+const insertJob = `-- name: InsertJob :exec
+INSERT INTO job (kind, payload, run_at, created_at)
+VALUES ($1, $2, $3, NOW())
+`
+
+type InsertJobParams struct {
+	Kind    string
+	Payload []byte
+	RunAt   time.Time
+}
+
+func (q *Queries) InsertJob(ctx context.Context, arg InsertJobParams) error {
+	_, err := q.db.Exec(ctx, insertJob, arg.Kind, arg.Payload, arg.RunAt)
+	return err
+}
+
+const insertPushSubscription = `-- name: InsertPushSubscription :exec
+INSERT INTO push_subscription (
+  id, endpoint, p256dh, auth, account_id, guest_id, created_at
+) VALUES ($1, $2, $3, $4, $5, $6, NOW())
+ON CONFLICT DO NOTHING
+`
+
+type InsertPushSubscriptionParams struct {
+	ID        uuid.UUID
+	Endpoint  string
+	P256dh    []byte
+	Auth      []byte
+	AccountID uuid.NullUUID
+	GuestID   uuid.NullUUID
+}
+
+func (q *Queries) InsertPushSubscription(ctx context.Context, arg InsertPushSubscriptionParams) error {
+	_, err := q.db.Exec(ctx, insertPushSubscription,
+		arg.ID,
+		arg.Endpoint,
+		arg.P256dh,
+		arg.Auth,
+		arg.AccountID,
+		arg.GuestID,
+	)
+	return err
+}
+
+const insertRateLimitHit = `-- name: InsertRateLimitHit :exec
+INSERT INTO rate_limit_hit (ip, fingerprint, path, created_at)
+VALUES ($1, $2, $3, $4)
+`
+
+type InsertRateLimitHitParams struct {
+	Ip          net.IP
+	Fingerprint string
+	Path        string
+	CreatedAt   time.Time
+}
+
+func (q *Queries) InsertRateLimitHit(ctx context.Context, arg InsertRateLimitHitParams) error {
+	_, err := q.db.Exec(ctx, insertRateLimitHit,
+		arg.Ip,
+		arg.Fingerprint,
+		arg.Path,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const insertReport = `-- name: InsertReport :execrows
+INSERT INTO report (id, guest_id, reporter_ip, reason, created_at)
+SELECT $1, guest.id, $2, $3, $4
+FROM guest
+WHERE guest.id = $5 AND guest.guestbook_id = $6
+`
+
+type InsertReportParams struct {
+	ID          uuid.UUID
+	ReporterIp  net.IP
+	Reason      string
+	CreatedAt   time.Time
+	GuestID     uuid.UUID
+	GuestbookID uuid.UUID
+}
+
+func (q *Queries) InsertReport(ctx context.Context, arg InsertReportParams) (int64, error) {
+	result, err := q.db.Exec(ctx, insertReport,
+		arg.ID,
+		arg.ReporterIp,
+		arg.Reason,
+		arg.CreatedAt,
+		arg.GuestID,
+		arg.GuestbookID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const insertVapidKey = `-- name: InsertVapidKey :exec
+INSERT INTO vapid_key (private_key, created_at)
+VALUES ($1, NOW())
+ON CONFLICT DO NOTHING
+`
+
+func (q *Queries) InsertVapidKey(ctx context.Context, privateKey []byte) error {
+	_, err := q.db.Exec(ctx, insertVapidKey, privateKey)
+	return err
+}
+
+const isBanned = `-- name: IsBanned :one
+SELECT EXISTS (
+  SELECT 1 FROM ban WHERE $1::inet <<= network
+)
+`
+
+func (q *Queries) IsBanned(ctx context.Context, ip net.IP) (bool, error) {
+	row := q.db.QueryRow(ctx, isBanned, ip)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const isGuestbookViewer = `-- name: IsGuestbookViewer :one
+SELECT EXISTS (
+  SELECT 1 FROM guestbook_viewer WHERE guestbook_id = $1 AND account_id = $2
+)
+`
+
+type IsGuestbookViewerParams struct {
+	GuestbookID uuid.UUID
+	AccountID   uuid.UUID
+}
+
+func (q *Queries) IsGuestbookViewer(ctx context.Context, arg IsGuestbookViewerParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isGuestbookViewer, arg.GuestbookID, arg.AccountID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const retryJob = `-- name: RetryJob :exec
+UPDATE job SET run_at = $2, last_error = $3 WHERE id = $1
+`
+
+type RetryJobParams struct {
+	ID        int64
+	RunAt     time.Time
+	LastError string
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) error {
+	_, err := q.db.Exec(ctx, retryJob, arg.ID, arg.RunAt, arg.LastError)
+	return err
+}
+
+const unblockTag = `-- name: UnblockTag :exec
+DELETE FROM blocked_tag
+WHERE guestbook_id = $1 AND tag = $2
+`
+
+type UnblockTagParams struct {
+	GuestbookID uuid.UUID
+	Tag         string
+}
+
+func (q *Queries) UnblockTag(ctx context.Context, arg UnblockTagParams) error {
+	_, err := q.db.Exec(ctx, unblockTag, arg.GuestbookID, arg.Tag)
+	return err
+}
+
+const updateAccountPlan = `-- name: UpdateAccountPlan :exec
+UPDATE account
+SET plan = $2,
+    quota_guestbooks = $3,
+    quota_messages = $4,
+    quota_storage_bytes = $5,
+    quota_api_calls = $6,
+    updated_at = now()
+WHERE id = $1
+`
+
+type UpdateAccountPlanParams struct {
+	ID                uuid.UUID
+	Plan              string
+	QuotaGuestbooks   pgtype.Int8
+	QuotaMessages     pgtype.Int8
+	QuotaStorageBytes pgtype.Int8
+	QuotaApiCalls     pgtype.Int8
+}
+
+func (q *Queries) UpdateAccountPlan(ctx context.Context, arg UpdateAccountPlanParams) error {
+	_, err := q.db.Exec(ctx, updateAccountPlan,
+		arg.ID,
+		arg.Plan,
+		arg.QuotaGuestbooks,
+		arg.QuotaMessages,
+		arg.QuotaStorageBytes,
+		arg.QuotaApiCalls,
+	)
+	return err
+}
+
+const updateGuestbookFields = `-- name: UpdateGuestbookFields :exec
+UPDATE guestbook
+SET field_definitions = $2, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateGuestbookFieldsParams struct {
+	ID               uuid.UUID
+	FieldDefinitions []byte
+}
+
+func (q *Queries) UpdateGuestbookFields(ctx context.Context, arg UpdateGuestbookFieldsParams) error {
+	_, err := q.db.Exec(ctx, updateGuestbookFields, arg.ID, arg.FieldDefinitions)
+	return err
+}
+
+const updateGuestbookSettings = `-- name: UpdateGuestbookSettings :exec
+UPDATE guestbook
+SET title = $2, visibility = $3, access_code_hash = $4, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateGuestbookSettingsParams struct {
+	ID             uuid.UUID
+	Title          string
+	Visibility     string
+	AccessCodeHash string
+}
+
+func (q *Queries) UpdateGuestbookSettings(ctx context.Context, arg UpdateGuestbookSettingsParams) error {
+	_, err := q.db.Exec(ctx, updateGuestbookSettings,
+		arg.ID,
+		arg.Title,
+		arg.Visibility,
+		arg.AccessCodeHash,
+	)
+	return err
+}
+
+const updateGuestbookTheme = `-- name: UpdateGuestbookTheme :exec
+UPDATE guestbook
+SET theme = $2, custom_css = $3, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateGuestbookThemeParams struct {
+	ID        uuid.UUID
+	Theme     string
+	CustomCss string
+}
+
+func (q *Queries) UpdateGuestbookTheme(ctx context.Context, arg UpdateGuestbookThemeParams) error {
+	_, err := q.db.Exec(ctx, updateGuestbookTheme, arg.ID, arg.Theme, arg.CustomCss)
+	return err
+}
+
+const upsertAccount = `-- name: UpsertAccount :one
+INSERT INTO account (id, email, password_hash, created_at, updated_at, admin)
+VALUES ($1, $2, $3, $4, $4, $5)
+ON CONFLICT (email) DO UPDATE
+SET password_hash = EXCLUDED.password_hash, updated_at = EXCLUDED.updated_at,
+    admin = EXCLUDED.admin
+RETURNING id, email, password_hash, created_at, updated_at, admin, plan, quota_guestbooks, quota_messages, quota_storage_bytes, quota_api_calls
+`
+
+type UpsertAccountParams struct {
+	ID           uuid.UUID
+	Email        string
+	PasswordHash string
+	CreatedAt    time.Time
+	Admin        bool
+}
+
+func (q *Queries) UpsertAccount(ctx context.Context, arg UpsertAccountParams) (Account, error) {
+	row := q.db.QueryRow(ctx, upsertAccount,
+		arg.ID,
+		arg.Email,
+		arg.PasswordHash,
+		arg.CreatedAt,
+		arg.Admin,
+	)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Admin,
+		&i.Plan,
+		&i.QuotaGuestbooks,
+		&i.QuotaMessages,
+		&i.QuotaStorageBytes,
+		&i.QuotaApiCalls,
 	)
 	return i, err
 }
+
+const upsertCachedCertificate = `-- name: UpsertCachedCertificate :exec
+INSERT INTO certificate_cache (key, data, updated_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (key) DO UPDATE
+SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at
+`
+
+type UpsertCachedCertificateParams struct {
+	Key  string
+	Data []byte
+}
+
+func (q *Queries) UpsertCachedCertificate(ctx context.Context, arg UpsertCachedCertificateParams) error {
+	_, err := q.db.Exec(ctx, upsertCachedCertificate, arg.Key, arg.Data)
+	return err
+}
+
+const upsertGuestbookEmail = `-- name: UpsertGuestbookEmail :exec
+INSERT INTO guestbook_email (guestbook_id, allowed_senders, updated_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (guestbook_id) DO UPDATE
+SET allowed_senders = EXCLUDED.allowed_senders, updated_at = EXCLUDED.updated_at
+`
+
+type UpsertGuestbookEmailParams struct {
+	GuestbookID    uuid.UUID
+	AllowedSenders []string
+}
+
+func (q *Queries) UpsertGuestbookEmail(ctx context.Context, arg UpsertGuestbookEmailParams) error {
+	_, err := q.db.Exec(ctx, upsertGuestbookEmail, arg.GuestbookID, arg.AllowedSenders)
+	return err
+}
+
+const upsertTraffic = `-- name: UpsertTraffic :exec
+INSERT INTO guestbook_traffic (guestbook_id, day, views, visitors)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (guestbook_id, day) DO UPDATE
+SET views = GREATEST(guestbook_traffic.views, EXCLUDED.views),
+    visitors = GREATEST(guestbook_traffic.visitors, EXCLUDED.visitors)
+`
+
+type UpsertTrafficParams struct {
+	GuestbookID uuid.UUID
+	Day         time.Time
+	Views       int64
+	Visitors    int64
+}
+
+func (q *Queries) UpsertTraffic(ctx context.Context, arg UpsertTrafficParams) error {
+	_, err := q.db.Exec(ctx, upsertTraffic,
+		arg.GuestbookID,
+		arg.Day,
+		arg.Views,
+		arg.Visitors,
+	)
+	return err
+}
@@ -0,0 +1,69 @@
+// Package router registers routes on a ServeMux in groups, each sharing a
+// path prefix and a chain of middleware.
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+)
+
+// Router registers routes on a ServeMux, prefixing their paths and
+// wrapping them in its middleware.
+type Router struct {
+	mux    *http.ServeMux
+	prefix string
+	chain  middleware.Chain
+}
+
+// New creates a Router for the mux, without a prefix or middleware.
+func New(mux *http.ServeMux) *Router {
+	return &Router{mux: mux}
+}
+
+// With returns a router for the same group with the middleware added
+// inside the group's existing middleware.
+func (r *Router) With(mw ...middleware.Middleware) *Router {
+	return &Router{
+		mux:    r.mux,
+		prefix: r.prefix,
+		chain:  r.chain.Append(mw...),
+	}
+}
+
+// Group returns a router for routes under the prefix, with the middleware
+// added inside the existing middleware.
+func (r *Router) Group(prefix string, mw ...middleware.Middleware) *Router {
+	group := r.With(mw...)
+	group.prefix = r.prefix + strings.TrimSuffix(prefix, "/")
+
+	return group
+}
+
+// Handle registers the handler for the pattern, which is in the form
+// accepted by http.ServeMux, with the path relative to the group. A
+// pattern with only a method, such as "GET", matches the group's prefix
+// exactly.
+func (r *Router) Handle(pattern string, h http.Handler) {
+	r.mux.Handle(r.pattern(pattern), r.chain.Then(h))
+}
+
+// HandleFunc registers the handler function for the pattern.
+func (r *Router) HandleFunc(pattern string, f http.HandlerFunc) {
+	r.Handle(pattern, f)
+}
+
+// pattern adds the group's prefix to the path of the pattern.
+func (r *Router) pattern(pattern string) string {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		if strings.HasPrefix(pattern, "/") {
+			return r.prefix + pattern
+		}
+
+		method, path = pattern, ""
+	}
+
+	return method + " " + r.prefix + strings.TrimLeft(path, " ")
+}
@@ -0,0 +1,111 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/middleware"
+	"github.com/dreamsofcode-io/guestbook/internal/router"
+)
+
+func header(name string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.PathValue("slug")))
+}
+
+func TestRouter(t *testing.T) {
+	mux := http.NewServeMux()
+
+	r := router.New(mux).With(header("root"))
+	r.HandleFunc("GET /{$}", ok)
+	r.With(header("limited")).HandleFunc("POST /{$}", ok)
+
+	books := r.Group("/g/{slug}/", header("book"))
+	books.HandleFunc("GET /{$}", ok)
+	books.Group("/settings").HandleFunc("GET", ok)
+	books.Group("/admin", header("admin")).HandleFunc("GET /users", ok)
+
+	testCases := []struct {
+		Description   string
+		Method        string
+		Path          string
+		ExpectedCode  int
+		ExpectedTrace []string
+		ExpectedBody  string
+	}{
+		{
+			Description:   "root",
+			Method:        "GET",
+			Path:          "/",
+			ExpectedCode:  http.StatusOK,
+			ExpectedTrace: []string{"root"},
+		},
+		{
+			Description:   "per route middleware",
+			Method:        "POST",
+			Path:          "/",
+			ExpectedCode:  http.StatusOK,
+			ExpectedTrace: []string{"root", "limited"},
+		},
+		{
+			Description:   "group",
+			Method:        "GET",
+			Path:          "/g/party/",
+			ExpectedCode:  http.StatusOK,
+			ExpectedTrace: []string{"root", "book"},
+			ExpectedBody:  "party",
+		},
+		{
+			Description:   "group index",
+			Method:        "GET",
+			Path:          "/g/party/settings",
+			ExpectedCode:  http.StatusOK,
+			ExpectedTrace: []string{"root", "book"},
+			ExpectedBody:  "party",
+		},
+		{
+			Description:  "group index is exact",
+			Method:       "GET",
+			Path:         "/g/party/settings/",
+			ExpectedCode: http.StatusNotFound,
+		},
+		{
+			Description:   "nested group",
+			Method:        "GET",
+			Path:          "/g/party/admin/users",
+			ExpectedCode:  http.StatusOK,
+			ExpectedTrace: []string{"root", "book", "admin"},
+			ExpectedBody:  "party",
+		},
+		{
+			Description:  "group middleware doesn't leak",
+			Method:       "POST",
+			Path:         "/g/party/",
+			ExpectedCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tc.Method, tc.Path, nil))
+
+			assert.Equal(t, tc.ExpectedCode, w.Code)
+			assert.Equal(t, tc.ExpectedTrace, w.Header().Values("X-Trace"))
+			if tc.ExpectedBody != "" {
+				assert.Equal(t, tc.ExpectedBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package seal encrypts data that must be kept confidential at rest, such
+// as private messages left for a guestbook's owner.
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidKey = errors.New("key must be 32 bytes")
+
+// Sealer encrypts and decrypts values using AES-256-GCM.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+func New(key []byte) (*Sealer, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+
+	return &Sealer{
+		aead: aead,
+	}, nil
+}
+
+// NewFromBase64 creates a Sealer from a base64 encoded key.
+func NewFromBase64(key string) (*Sealer, error) {
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+
+	return New(data)
+}
+
+// Seal encrypts the plaintext, binding it to the additional data so that
+// the ciphertext can't be moved to another record.
+func (s *Sealer) Seal(plaintext []byte, additional []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return s.aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// Open decrypts a value produced by Seal.
+func (s *Sealer) Open(sealed []byte, additional []byte) ([]byte, error) {
+	size := s.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("sealed value too short")
+	}
+
+	plaintext, err := s.aead.Open(nil, sealed[:size], sealed[size:], additional)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open: %w", err)
+	}
+
+	return plaintext, nil
+}
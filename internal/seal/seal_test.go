@@ -0,0 +1,34 @@
+package seal_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/seal"
+)
+
+func TestSealer(t *testing.T) {
+	sealer, err := seal.New(make([]byte, 32))
+	assert.NoError(t, err)
+
+	sealed, err := sealer.Seal([]byte("hello"), []byte("guest-1"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(sealed), "hello")
+
+	t.Run("opens with the same additional data", func(t *testing.T) {
+		plaintext, err := sealer.Open(sealed, []byte("guest-1"))
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(plaintext))
+	})
+
+	t.Run("fails with different additional data", func(t *testing.T) {
+		_, err := sealer.Open(sealed, []byte("guest-2"))
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects short keys", func(t *testing.T) {
+		_, err := seal.New(make([]byte, 16))
+		assert.ErrorIs(t, err, seal.ErrInvalidKey)
+	})
+}
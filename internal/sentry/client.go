@@ -0,0 +1,424 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxEvents is how many events are sent a minute when Options
+	// doesn't set a limit.
+	DefaultMaxEvents = 30
+
+	// DefaultDedupeWindow is how long events like one just sent are
+	// dropped for when Options doesn't set a window.
+	DefaultDedupeWindow = time.Minute * 5
+
+	// maxBreadcrumbs is how many breadcrumbs are kept for the next event,
+	// for each request and outside of them.
+	maxBreadcrumbs = 50
+
+	// queueSize is how many events can wait to be sent before more are
+	// dropped.
+	queueSize = 64
+
+	// defaultRetryAfter is how long to pause for when the endpoint asks
+	// for a pause without saying how long.
+	defaultRetryAfter = time.Minute
+
+	clientName = "guestbook-sentry/1.0"
+)
+
+// Options configures a Client.
+type Options struct {
+	// DSN identifies the project to report to.
+	DSN string
+
+	// Release is the version of the code running, such as a commit hash.
+	Release string
+
+	// Environment is where the code is running, such as production.
+	Environment string
+
+	// MaxEvents is the most events sent a minute, defaulting to
+	// DefaultMaxEvents.
+	MaxEvents int
+
+	// DedupeWindow is how long events like one just sent are dropped for,
+	// defaulting to DefaultDedupeWindow.
+	DedupeWindow time.Duration
+
+	// HTTPClient sends the events, defaulting to a client with a timeout.
+	HTTPClient *http.Client
+
+	// Logger reports failures to send events. It shouldn't report to the
+	// client itself.
+	Logger *slog.Logger
+}
+
+// dedupe tracks an event that was sent, to drop those like it.
+type dedupe struct {
+	sent       time.Time
+	suppressed int
+}
+
+// Client sends events to the endpoint identified by a DSN. It's safe for
+// concurrent use.
+type Client struct {
+	dsn        *DSN
+	opts       Options
+	serverName string
+
+	// breadcrumbs are those recorded outside of any request.
+	breadcrumbs trail
+
+	mu          sync.Mutex
+	seen        map[string]*dedupe
+	window      time.Time
+	sent        int
+	pausedUntil time.Time
+	closed      bool
+
+	queue   chan *Event
+	pending sync.WaitGroup
+	done    chan struct{}
+}
+
+// New creates a Client and starts sending events in the background, until
+// it's closed.
+func New(opts Options) (*Client, error) {
+	dsn, err := ParseDSN(opts.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxEvents <= 0 {
+		opts.MaxEvents = DefaultMaxEvents
+	}
+
+	if opts.DedupeWindow <= 0 {
+		opts.DedupeWindow = DefaultDedupeWindow
+	}
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: time.Second * 10}
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	hostname, _ := os.Hostname()
+
+	c := &Client{
+		dsn:        dsn,
+		opts:       opts,
+		serverName: hostname,
+		seen:       map[string]*dedupe{},
+		queue:      make(chan *Event, queueSize),
+		done:       make(chan struct{}),
+	}
+
+	go c.run()
+
+	return c, nil
+}
+
+// AddBreadcrumb records something that happened, to be sent with the
+// events that follow. With the context of a request passed through
+// Middleware, it's only sent with that request's events, and otherwise
+// only with events captured outside of a request.
+func (c *Client) AddBreadcrumb(ctx context.Context, b Breadcrumb) {
+	if b.Timestamp.IsZero() {
+		b.Timestamp = time.Now()
+	}
+
+	c.trail(ctx).add(b)
+}
+
+// trail returns the breadcrumbs for the context's request, or those kept
+// outside of any request.
+func (c *Client) trail(ctx context.Context) *trail {
+	if s := scopeFrom(ctx); s != nil {
+		return &s.breadcrumbs
+	}
+
+	return &c.breadcrumbs
+}
+
+// CaptureError reports the error, returning the event's ID, or an empty
+// string if it was dropped.
+func (c *Client) CaptureError(ctx context.Context, err error) string {
+	return c.Capture(ctx, &Event{
+		Level:     LevelError,
+		Exception: []Exception{NewException(err, Callers(1))},
+	})
+}
+
+// Capture reports the event, filling in the client's details and the
+// request from the context, and returns its ID. It returns an empty string
+// if the event was dropped, for being like one sent recently, being over
+// the limit, or the endpoint asking for a pause.
+func (c *Client) Capture(ctx context.Context, e *Event) string {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || now.Before(c.pausedUntil) {
+		return ""
+	}
+
+	key := e.key()
+	if seen, ok := c.seen[key]; ok && now.Sub(seen.sent) < c.opts.DedupeWindow {
+		seen.suppressed++
+		return ""
+	}
+
+	if now.Sub(c.window) >= time.Minute {
+		c.window = now
+		c.sent = 0
+		c.prune(now)
+	}
+
+	if c.sent >= c.opts.MaxEvents {
+		return ""
+	}
+
+	if e.Extra == nil {
+		e.Extra = map[string]any{}
+	}
+
+	if seen, ok := c.seen[key]; ok && seen.suppressed > 0 {
+		e.Extra["suppressed_duplicates"] = seen.suppressed
+	}
+
+	c.fill(ctx, e, now)
+
+	c.pending.Add(1)
+
+	select {
+	case c.queue <- e:
+	default:
+		c.pending.Done()
+		return ""
+	}
+
+	c.seen[key] = &dedupe{sent: now}
+	c.sent++
+
+	return e.EventID
+}
+
+// fill sets the details of the event the caller leaves out.
+func (c *Client) fill(ctx context.Context, e *Event, now time.Time) {
+	e.EventID = newEventID()
+	e.Timestamp = now.UTC()
+	e.Platform = "go"
+	e.Release = c.opts.Release
+	e.Environment = c.opts.Environment
+	e.ServerName = c.serverName
+	e.Breadcrumbs = c.trail(ctx).list()
+
+	if e.Level == "" {
+		e.Level = LevelError
+	}
+
+	if s := scopeFrom(ctx); e.Request == nil && s != nil {
+		e.Request = s.request
+	}
+
+	if e.Culprit == "" {
+		for _, ex := range e.Exception {
+			if e.Culprit = culprit(ex.Stacktrace); e.Culprit != "" {
+				break
+			}
+		}
+	}
+}
+
+// prune forgets events sent longer ago than the dedupe window.
+func (c *Client) prune(now time.Time) {
+	for key, seen := range c.seen {
+		if now.Sub(seen.sent) >= c.opts.DedupeWindow {
+			delete(c.seen, key)
+		}
+	}
+}
+
+// key identifies events alike enough to be duplicates: the same message
+// and type of error from the same place, whatever the error's details.
+func (e *Event) key() string {
+	var b strings.Builder
+	b.WriteString(string(e.Level))
+	b.WriteString("\x00" + e.Message)
+
+	for _, ex := range e.Exception {
+		b.WriteString("\x00" + ex.Type + "\x00" + culprit(ex.Stacktrace))
+	}
+
+	return b.String()
+}
+
+// Flush waits until the events captured so far have been sent, or the
+// context is done.
+func (c *Client) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	go func() {
+		c.pending.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops capturing events, and waits until those already captured
+// have been sent, or the context is done.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+
+	for e := range c.queue {
+		if err := c.send(e); err != nil {
+			c.opts.Logger.Warn(
+				"failed to send error report",
+				slog.String("event_id", e.EventID),
+				slog.Any("error", err),
+			)
+		}
+
+		c.pending.Done()
+	}
+}
+
+// send posts the event in an envelope.
+func (c *Client) send(e *Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	header, err := json.Marshal(map[string]string{
+		"event_id": e.EventID,
+		"dsn":      c.dsn.String(),
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode envelope: %w", err)
+	}
+
+	var body bytes.Buffer
+	body.Write(header)
+	fmt.Fprintf(&body, "\n{\"type\":\"event\",\"length\":%d}\n", len(payload))
+	body.Write(payload)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequest(http.MethodPost, c.dsn.Endpoint(), &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", c.dsn.auth(clientName))
+
+	res, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event: %w", err)
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, res.Body)
+
+	if pause := retryAfter(res); pause > 0 {
+		c.mu.Lock()
+		c.pausedUntil = time.Now().Add(pause)
+		c.mu.Unlock()
+	}
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", res.Status)
+	}
+
+	return nil
+}
+
+// retryAfter returns how long the endpoint asks to pause sending errors
+// for, from X-Sentry-Rate-Limits, or Retry-After on a 429.
+func retryAfter(res *http.Response) time.Duration {
+	var pause time.Duration
+
+	// Each limit is seconds:categories:scope..., where no categories
+	// means every category.
+	if limits := res.Header.Get("X-Sentry-Rate-Limits"); limits != "" {
+		for _, limit := range strings.Split(limits, ",") {
+			parts := strings.Split(strings.TrimSpace(limit), ":")
+
+			seconds, err := strconv.Atoi(parts[0])
+			if err != nil {
+				continue
+			}
+
+			applies := len(parts) < 2 || parts[1] == ""
+			if !applies {
+				for _, category := range strings.Split(parts[1], ";") {
+					applies = applies || category == "error" || category == "default"
+				}
+			}
+
+			if d := time.Duration(seconds) * time.Second; applies && d > pause {
+				pause = d
+			}
+		}
+
+		return pause
+	}
+
+	if res.StatusCode != http.StatusTooManyRequests {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return defaultRetryAfter
+}
+
+func newEventID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,16 @@
+// Package sentry reports errors and panics to a Sentry compatible
+// endpoint, which can be sentry.io, a self-hosted Sentry, or anything else
+// accepting the envelope protocol, such as a local stand-in.
+//
+// Errors are captured from the logs: Handler wraps a slog.Handler so that
+// every record at the error level becomes an event, and every other record
+// a breadcrumb leading up to it. Records logged with the context of a
+// request passed through Middleware carry that request, and their
+// breadcrumbs are kept with it, so that an event only comes with what led
+// up to it in the same request. Middleware also captures panics.
+//
+// Events are sent in the background. Events like one sent recently are
+// dropped, as is everything beyond a limit each minute, or while the
+// endpoint asks for a pause, so that a failure on every request doesn't
+// flood it.
+package sentry
@@ -0,0 +1,80 @@
+package sentry
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DSN identifies the project events are sent to, in the form
+// https://public@host/path/project.
+type DSN struct {
+	raw       string
+	publicKey string
+	secretKey string
+	endpoint  string
+	projectID string
+}
+
+// ParseDSN parses a DSN.
+func ParseDSN(dsn string) (*DSN, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dsn: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid dsn: unsupported scheme %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid dsn: missing host")
+	}
+
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("invalid dsn: missing public key")
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+
+	i := strings.LastIndex(path, "/")
+	if i < 0 || path[i+1:] == "" {
+		return nil, fmt.Errorf("invalid dsn: missing project id")
+	}
+
+	prefix, project := path[:i], path[i+1:]
+	secret, _ := u.User.Password()
+
+	return &DSN{
+		raw:       dsn,
+		publicKey: u.User.Username(),
+		secretKey: secret,
+		projectID: project,
+		endpoint:  fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project),
+	}, nil
+}
+
+// String returns the DSN as it was parsed.
+func (d *DSN) String() string {
+	return d.raw
+}
+
+// Endpoint is the URL envelopes are sent to.
+func (d *DSN) Endpoint() string {
+	return d.endpoint
+}
+
+// ProjectID is the project events are reported to.
+func (d *DSN) ProjectID() string {
+	return d.projectID
+}
+
+// auth is the X-Sentry-Auth header value for the client.
+func (d *DSN) auth(client string) string {
+	auth := "Sentry sentry_version=7, sentry_client=" + client + ", sentry_key=" + d.publicKey
+	if d.secretKey != "" {
+		auth += ", sentry_secret=" + d.secretKey
+	}
+
+	return auth
+}
@@ -0,0 +1,254 @@
+package sentry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Level is the severity of an event or breadcrumb.
+type Level string
+
+const (
+	LevelDebug   Level = "debug"
+	LevelInfo    Level = "info"
+	LevelWarning Level = "warning"
+	LevelError   Level = "error"
+	LevelFatal   Level = "fatal"
+)
+
+// Event is a captured error or panic, in the shape of the Sentry event
+// payload. The client fills in its identity and context when capturing.
+type Event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Level       Level             `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Culprit     string            `json:"culprit,omitempty"`
+	Exception   []Exception       `json:"-"`
+	Breadcrumbs []Breadcrumb      `json:"-"`
+	Request     *Request          `json:"request,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]any    `json:"extra,omitempty"`
+}
+
+type values[T any] struct {
+	Values []T `json:"values"`
+}
+
+// MarshalJSON encodes the event, with the exceptions and breadcrumbs
+// nested under values as the protocol expects.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+
+	payload := struct {
+		event
+		Exception   *values[Exception]  `json:"exception,omitempty"`
+		Breadcrumbs *values[Breadcrumb] `json:"breadcrumbs,omitempty"`
+	}{event: event(e)}
+
+	if len(e.Exception) > 0 {
+		payload.Exception = &values[Exception]{e.Exception}
+	}
+
+	if len(e.Breadcrumbs) > 0 {
+		payload.Breadcrumbs = &values[Breadcrumb]{e.Breadcrumbs}
+	}
+
+	return json.Marshal(payload)
+}
+
+// Exception is an error or panic, with where it happened.
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Module     string      `json:"module,omitempty"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+// Stacktrace is a list of frames, with the outermost call first.
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+// Frame is a function call in a stack trace.
+type Frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	AbsPath  string `json:"abs_path,omitempty"`
+	Lineno   int    `json:"lineno,omitempty"`
+	InApp    bool   `json:"in_app"`
+}
+
+// Request is the HTTP request an event happened while handling.
+type Request struct {
+	URL         string            `json:"url"`
+	Method      string            `json:"method"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// Breadcrumb is something that happened before an event, to help explain
+// it.
+type Breadcrumb struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Category  string         `json:"category,omitempty"`
+	Level     Level          `json:"level,omitempty"`
+	Message   string         `json:"message,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// sensitiveHeaders are left out of requests sent with events.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+	"X-Forwarded-For":     true,
+	"X-Real-Ip":           true,
+}
+
+// NewRequest describes the request, leaving out the query string's values
+// and headers that identify the visitor or hold credentials.
+func NewRequest(r *http.Request) *Request {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	req := &Request{
+		URL:     scheme + "://" + r.Host + r.URL.Path,
+		Method:  r.Method,
+		Headers: map[string]string{},
+	}
+
+	if query := r.URL.Query(); len(query) > 0 {
+		keys := make([]string, 0, len(query))
+		for key := range query {
+			keys = append(keys, key+"=[Filtered]")
+		}
+
+		slices.Sort(keys)
+		req.QueryString = strings.Join(keys, "&")
+	}
+
+	for name, value := range r.Header {
+		if !sensitiveHeaders[name] {
+			req.Headers[name] = strings.Join(value, ", ")
+		}
+	}
+
+	return req
+}
+
+// NewException describes the error, using the type of the error it wraps
+// at the bottom of its chain, as the wrapping is rarely interesting.
+func NewException(err error, stack *Stacktrace) Exception {
+	cause := err
+	for {
+		next := errors.Unwrap(cause)
+		if next == nil {
+			break
+		}
+
+		cause = next
+	}
+
+	return Exception{
+		Type:       fmt.Sprintf("%T", cause),
+		Value:      err.Error(),
+		Stacktrace: stack,
+	}
+}
+
+// Callers returns the stack trace of the caller, skipping that many
+// frames above it.
+func Callers(skip int) *Stacktrace {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+
+	return stacktrace(pcs[:n])
+}
+
+func stacktrace(pcs []uintptr) *Stacktrace {
+	var frames []Frame
+
+	iter := runtime.CallersFrames(pcs)
+	for {
+		f, more := iter.Next()
+		if f.Function != "" {
+			frames = append(frames, newFrame(f))
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	// Sentry expects the outermost call first
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+
+	return &Stacktrace{Frames: frames}
+}
+
+// module is the import path of the code's own packages, whose frames are
+// marked as in the app.
+const module = "github.com/dreamsofcode-io/guestbook"
+
+func newFrame(f runtime.Frame) Frame {
+	pkg, function := splitFunction(f.Function)
+
+	filename := f.File
+	if i := strings.LastIndex(filename, "/"); i >= 0 {
+		filename = filename[i+1:]
+	}
+
+	return Frame{
+		Function: function,
+		Module:   pkg,
+		Filename: filename,
+		AbsPath:  f.File,
+		Lineno:   f.Line,
+		InApp:    strings.HasPrefix(pkg, module) && pkg != module+"/internal/sentry",
+	}
+}
+
+// splitFunction splits a function's full name, such as
+// example.com/pkg.(*Type).Method, into its package and function.
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		i := slash + 1 + dot
+		return name[:i], name[i+1:]
+	}
+
+	return "", name
+}
+
+// culprit names the innermost frame in the app, where the event happened.
+func culprit(stack *Stacktrace) string {
+	if stack == nil {
+		return ""
+	}
+
+	for i := len(stack.Frames) - 1; i >= 0; i-- {
+		if f := stack.Frames[i]; f.InApp {
+			return f.Module + "." + f.Function
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,183 @@
+package sentry
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+)
+
+// Handler is a slog.Handler that reports records at the error level and
+// above as events, and keeps the others as breadcrumbs, before passing
+// each on to the handler it wraps.
+type Handler struct {
+	next   slog.Handler
+	client *Client
+	group  string
+
+	// data and err hold the attributes added to the handler.
+	data map[string]any
+	err  error
+}
+
+// NewHandler wraps the handler to report to the client.
+func NewHandler(next slog.Handler, client *Client) *Handler {
+	return &Handler{next: next, client: client}
+}
+
+// Enabled reports whether the record is handled, which records at the
+// info level and above always are, as breadcrumbs if nothing else.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo || h.next.Enabled(ctx, level)
+}
+
+// Handle reports the record and passes it on.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	data := make(map[string]any, len(h.data)+r.NumAttrs())
+	for key, value := range h.data {
+		data[key] = value
+	}
+
+	err := h.err
+	r.Attrs(func(a slog.Attr) bool {
+		if e := flatten(data, h.group, a); err == nil {
+			err = e
+		}
+
+		return true
+	})
+
+	level := levelOf(r.Level)
+
+	if r.Level >= slog.LevelError {
+		e := &Event{
+			Level:   level,
+			Logger:  "slog",
+			Message: r.Message,
+			Extra:   data,
+		}
+
+		stack := callersFrom(r.PC)
+		if err != nil {
+			e.Exception = []Exception{NewException(err, stack)}
+		} else {
+			e.Culprit = culprit(stack)
+		}
+
+		h.client.Capture(ctx, e)
+	}
+
+	h.client.AddBreadcrumb(ctx, Breadcrumb{
+		Timestamp: r.Time,
+		Category:  "log",
+		Level:     level,
+		Message:   r.Message,
+		Data:      data,
+	})
+
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler adding the attributes to every record.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	clone.data = make(map[string]any, len(h.data)+len(attrs))
+
+	for key, value := range h.data {
+		clone.data[key] = value
+	}
+
+	for _, a := range attrs {
+		if err := flatten(clone.data, h.group, a); clone.err == nil {
+			clone.err = err
+		}
+	}
+
+	return &clone
+}
+
+// WithGroup returns a handler nesting later attributes under the group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	clone.group = h.group + name + "."
+
+	return &clone
+}
+
+// flatten adds the attribute to the data, with the keys of groups joined
+// by dots, and errors as their messages. It returns the first error among
+// the values, if any.
+func flatten(data map[string]any, prefix string, a slog.Attr) error {
+	v := a.Value.Resolve()
+
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+
+		var first error
+		for _, attr := range v.Group() {
+			if err := flatten(data, prefix, attr); first == nil {
+				first = err
+			}
+		}
+
+		return first
+	}
+
+	if a.Key == "" {
+		return nil
+	}
+
+	if err, ok := v.Any().(error); ok {
+		data[prefix+a.Key] = err.Error()
+		return err
+	}
+
+	data[prefix+a.Key] = v.Any()
+
+	return nil
+}
+
+func levelOf(level slog.Level) Level {
+	switch {
+	case level >= slog.LevelError+4:
+		return LevelFatal
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarning
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	}
+
+	return LevelDebug
+}
+
+// callersFrom returns the stack trace from the function that logged, at
+// pc, leaving out the frames of slog and this handler.
+func callersFrom(pc uintptr) *Stacktrace {
+	if pc == 0 {
+		return nil
+	}
+
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(1, pcs)
+
+	for i, p := range pcs[:n] {
+		if p == pc {
+			return stacktrace(pcs[i:n])
+		}
+	}
+
+	return stacktrace([]uintptr{pc})
+}
@@ -0,0 +1,95 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+type scopeKey struct{}
+
+// scope is what's known about the request being handled, for the events
+// captured while handling it.
+type scope struct {
+	request     *Request
+	breadcrumbs trail
+}
+
+// scopeFrom returns the scope stored in the context by Middleware.
+func scopeFrom(ctx context.Context) *scope {
+	if ctx == nil {
+		return nil
+	}
+
+	s, _ := ctx.Value(scopeKey{}).(*scope)
+	return s
+}
+
+// trail is the most recent breadcrumbs, up to maxBreadcrumbs.
+type trail struct {
+	mu          sync.Mutex
+	breadcrumbs []Breadcrumb
+}
+
+func (t *trail) add(b Breadcrumb) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.breadcrumbs) >= maxBreadcrumbs {
+		t.breadcrumbs = append(t.breadcrumbs[:0], t.breadcrumbs[1:]...)
+	}
+
+	t.breadcrumbs = append(t.breadcrumbs, b)
+}
+
+func (t *trail) list() []Breadcrumb {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Breadcrumb(nil), t.breadcrumbs...)
+}
+
+// Middleware attaches the request to events captured while handling it,
+// along with the breadcrumbs recorded while handling it and no others, and
+// captures panics, responding with a 500 in their place.
+func (c *Client) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), scopeKey{}, &scope{request: NewRequest(r)})
+		r = r.WithContext(ctx)
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			// Aborting is how handlers end a response early, rather than
+			// a failure
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			c.opts.Logger.Error(
+				"panic handling request",
+				slog.String("path", r.URL.Path),
+				slog.Any("panic", v),
+			)
+
+			// Skipping the deferred function and the runtime's panic
+			stack := Callers(2)
+
+			ex := Exception{Type: fmt.Sprintf("%T", v), Value: fmt.Sprint(v), Stacktrace: stack}
+			if err, ok := v.(error); ok {
+				ex = NewException(err, stack)
+			}
+
+			c.Capture(ctx, &Event{Level: LevelFatal, Exception: []Exception{ex}})
+
+			w.WriteHeader(http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
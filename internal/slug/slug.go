@@ -0,0 +1,47 @@
+// Package slug validates the short names that guestbooks are served under.
+package slug
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalid  = errors.New("slugs must be 3 to 40 lowercase letters, numbers or dashes, and can't start or end with a dash")
+	ErrReserved = errors.New("that slug is reserved")
+)
+
+var re = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,38}[a-z0-9]$`)
+
+// reserved slugs either clash with the site's own routes or could be
+// mistaken for something official. Guestbooks receive mail at
+// <slug>@<domain>, so this includes the mailboxes other servers and people
+// expect to reach the site's operators, or to never be answered.
+var reserved = []string{
+	"about", "abuse", "access", "account", "admin", "administrator", "api",
+	"app", "assets", "auth", "dashboard", "default", "embed", "g",
+	"guestbook", "help", "hostmaster", "login", "logout", "mailer-daemon",
+	"new", "no-reply", "noreply", "official", "postmaster", "presence",
+	"root", "security", "settings", "signup", "static", "stats", "status",
+	"support", "system", "tags", "theme", "webmaster", "www",
+}
+
+// Normalize lowercases and trims a submitted slug.
+func Normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// Validate checks that a normalized slug is well formed and not reserved.
+func Validate(s string) error {
+	if !re.MatchString(s) || strings.Contains(s, "--") {
+		return ErrInvalid
+	}
+
+	if slices.Contains(reserved, s) {
+		return ErrReserved
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package slug_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/slug"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		Slug        string
+		ExpectedErr error
+	}{
+		{"smith-wedding-2024", nil},
+		{"abc", nil},
+		{"ab", slug.ErrInvalid},
+		{"-party", slug.ErrInvalid},
+		{"party-", slug.ErrInvalid},
+		{"two--dashes", slug.ErrInvalid},
+		{"Upper", slug.ErrInvalid},
+		{"has space", slug.ErrInvalid},
+		{"a-very-long-slug-that-goes-on-and-on-and-on", slug.ErrInvalid},
+		{"admin", slug.ErrReserved},
+		{"dashboard", slug.ErrReserved},
+		{"postmaster", slug.ErrReserved},
+		{"abuse", slug.ErrReserved},
+		{"hostmaster", slug.ErrReserved},
+		{"webmaster", slug.ErrReserved},
+		{"mailer-daemon", slug.ErrReserved},
+		{"noreply", slug.ErrReserved},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Slug, func(t *testing.T) {
+			assert.Equal(t, test.ExpectedErr, slug.Validate(test.Slug))
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package smtpd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLine is the longest command line accepted, which is more than
+	// the 512 bytes RFC 5321 requires.
+	maxLine = 1024
+
+	// maxErrors is how many bad commands a client may send before it's
+	// disconnected.
+	maxErrors = 10
+)
+
+// errLineTooLong is returned by readLine for lines over maxLine.
+var errLineTooLong = errors.New("line too long")
+
+type conn struct {
+	server *Server
+	nc     net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+
+	helo string
+	env  *Envelope
+
+	mu      sync.Mutex
+	idle    bool
+	closing bool
+}
+
+func (s *Server) newConn(nc net.Conn) *conn {
+	return &conn{
+		server: s,
+		nc:     nc,
+		r:      bufio.NewReaderSize(nc, maxLine),
+		w:      bufio.NewWriter(nc),
+	}
+}
+
+// shutdown asks the connection to quit once it's finished with any message
+// it's receiving.
+func (c *conn) shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closing = true
+	if c.idle {
+		c.nc.SetReadDeadline(time.Now())
+	}
+}
+
+// waiting marks whether the connection is waiting for a command outside of
+// a transaction, reporting whether it should quit instead.
+func (c *conn) waiting(idle bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.idle = idle && c.env == nil
+	return c.closing && c.env == nil
+}
+
+func (c *conn) serve() {
+	defer c.nc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c.reply(220, "", c.server.Domain+" ESMTP ready")
+
+	errs := 0
+	for {
+		if c.waiting(true) {
+			c.reply(421, "4.3.2", "Shutting down")
+			return
+		}
+
+		c.nc.SetReadDeadline(time.Now().Add(c.server.timeout()))
+		line, err := c.readLine()
+
+		if c.waiting(false) {
+			c.reply(421, "4.3.2", "Shutting down")
+			return
+		}
+
+		if errors.Is(err, errLineTooLong) {
+			c.reply(500, "5.5.6", "Line too long")
+			return
+		} else if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				c.reply(421, "4.4.2", "Timeout, closing connection")
+			}
+
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		ok, quit := c.handle(ctx, strings.ToUpper(verb), strings.TrimSpace(arg))
+		if quit {
+			return
+		}
+
+		if !ok {
+			if errs++; errs >= maxErrors {
+				c.reply(421, "4.7.0", "Too many errors, closing connection")
+				return
+			}
+		}
+	}
+}
+
+// handle runs a command, reporting whether it succeeded and whether the
+// connection should be closed.
+func (c *conn) handle(ctx context.Context, verb string, arg string) (bool, bool) {
+	switch verb {
+	case "HELO", "EHLO":
+		if arg == "" {
+			c.reply(501, "5.5.4", "Domain name required")
+			return false, false
+		}
+
+		c.helo = arg
+		c.env = nil
+
+		if verb == "HELO" {
+			c.reply(250, "", c.server.Domain)
+			return true, false
+		}
+
+		c.replyLines(250,
+			c.server.Domain,
+			"SIZE "+strconv.FormatInt(c.server.maxSize(), 10),
+			"8BITMIME",
+			"ENHANCEDSTATUSCODES",
+		)
+		return true, false
+	case "MAIL":
+		return c.mail(ctx, arg), false
+	case "RCPT":
+		return c.rcpt(ctx, arg), false
+	case "DATA":
+		return c.data(ctx)
+	case "RSET":
+		c.env = nil
+		c.reply(250, "2.0.0", "OK")
+		return true, false
+	case "NOOP":
+		c.reply(250, "2.0.0", "OK")
+		return true, false
+	case "VRFY":
+		c.reply(252, "2.5.0", "Cannot verify user")
+		return true, false
+	case "QUIT":
+		c.reply(221, "2.0.0", "Bye")
+		return true, true
+	case "STARTTLS", "AUTH":
+		c.reply(502, "5.5.1", "Not implemented")
+		return false, false
+	}
+
+	c.reply(500, "5.5.2", "Unrecognized command")
+	return false, false
+}
+
+func (c *conn) mail(ctx context.Context, arg string) bool {
+	switch {
+	case c.helo == "":
+		c.reply(503, "5.5.1", "Say hello first")
+		return false
+	case c.env != nil:
+		c.reply(503, "5.5.1", "Sender already given")
+		return false
+	}
+
+	from, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		c.reply(501, "5.5.4", "Syntax: MAIL FROM:<address>")
+		return false
+	}
+
+	for _, param := range params {
+		name, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(name, "SIZE") {
+			continue
+		}
+
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.reply(501, "5.5.4", "Invalid size")
+			return false
+		}
+
+		if size > c.server.maxSize() {
+			c.reply(552, "5.3.4", "Message too big")
+			return false
+		}
+	}
+
+	c.env = &Envelope{
+		RemoteAddr: c.nc.RemoteAddr(),
+		Helo:       c.helo,
+		From:       from,
+	}
+
+	c.reply(250, "2.1.0", "OK")
+	return true
+}
+
+func (c *conn) rcpt(ctx context.Context, arg string) bool {
+	if c.env == nil {
+		c.reply(503, "5.5.1", "Need MAIL first")
+		return false
+	}
+
+	to, _, ok := parsePath(arg, "TO:")
+	if !ok || !strings.Contains(to, "@") {
+		c.reply(501, "5.5.4", "Syntax: RCPT TO:<address>")
+		return false
+	}
+
+	if len(c.env.To) >= c.server.maxRecipients() {
+		c.reply(452, "4.5.3", "Too many recipients")
+		return false
+	}
+
+	if err := c.server.Backend.Recipient(ctx, c.env, to); err != nil {
+		c.replyError(err)
+		return false
+	}
+
+	c.env.To = append(c.env.To, to)
+	c.reply(250, "2.1.5", "OK")
+
+	return true
+}
+
+func (c *conn) data(ctx context.Context) (bool, bool) {
+	if c.env == nil || len(c.env.To) == 0 {
+		c.reply(503, "5.5.1", "Need RCPT first")
+		return false, false
+	}
+
+	c.reply(354, "", "End data with <CR><LF>.<CR><LF>")
+
+	limit := c.server.maxSize()
+	dot := textproto.NewReader(c.r).DotReader()
+
+	data, err := io.ReadAll(io.LimitReader(dot, limit+1))
+	if err == nil && int64(len(data)) > limit {
+		// The rest is read, so the client hears why it was refused
+		_, err = io.Copy(io.Discard, dot)
+		if err == nil {
+			c.env = nil
+			c.reply(552, "5.3.4", "Message too big")
+			return false, false
+		}
+	}
+
+	if err != nil {
+		return false, true
+	}
+
+	env := c.env
+	env.Data = data
+	c.env = nil
+
+	if err := c.server.Backend.Deliver(ctx, env); err != nil {
+		c.replyError(err)
+		return false, false
+	}
+
+	c.reply(250, "2.0.0", "OK, queued")
+	return true, false
+}
+
+// replyError replies with an error from the backend, which is temporary
+// unless it's an *Error saying otherwise.
+func (c *conn) replyError(err error) {
+	var smtpErr *Error
+	if errors.As(err, &smtpErr) {
+		c.reply(smtpErr.Code, smtpErr.Enhanced, smtpErr.Message)
+		return
+	}
+
+	c.server.logger().Error("failed to handle mail", slog.Any("error", err))
+	c.reply(451, "4.3.0", "Temporary failure, try again later")
+}
+
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", errLineTooLong
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (c *conn) reply(code int, enhanced string, message string) {
+	if enhanced != "" {
+		message = enhanced + " " + message
+	}
+
+	c.nc.SetWriteDeadline(time.Now().Add(c.server.timeout()))
+	fmt.Fprintf(c.w, "%d %s\r\n", code, message)
+	c.w.Flush()
+}
+
+func (c *conn) replyLines(code int, lines ...string) {
+	c.nc.SetWriteDeadline(time.Now().Add(c.server.timeout()))
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+
+		fmt.Fprintf(c.w, "%d%s%s\r\n", code, sep, line)
+	}
+	c.w.Flush()
+}
+
+// parsePath parses the address and parameters of MAIL and RCPT commands,
+// such as FROM:<guest@example.com> SIZE=1024.
+func parsePath(arg string, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+
+	rest := strings.TrimLeft(arg[len(prefix):], " ")
+	if !strings.HasPrefix(rest, "<") {
+		return "", nil, false
+	}
+
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", nil, false
+	}
+
+	addr := rest[1:end]
+
+	// Source routes, like <@relay.example.com:guest@example.com>, are
+	// ignored
+	if strings.HasPrefix(addr, "@") {
+		_, addr, _ = strings.Cut(addr, ":")
+	}
+
+	return addr, strings.Fields(rest[end+1:]), true
+}
@@ -0,0 +1,4 @@
+// Package smtpd is a small SMTP server for receiving mail addressed to
+// the guestbook itself. It doesn't relay, authenticate or offer STARTTLS:
+// each recipient and message is accepted or refused by a Backend.
+package smtpd
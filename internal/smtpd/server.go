@@ -0,0 +1,268 @@
+package smtpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the largest message accepted when the server
+	// doesn't set one.
+	DefaultMaxSize = 1 << 20
+
+	// DefaultMaxRecipients is the most recipients a message may have when
+	// the server doesn't set a limit.
+	DefaultMaxRecipients = 10
+
+	// DefaultTimeout is how long a client has to send each command when
+	// the server doesn't set a timeout.
+	DefaultTimeout = time.Minute * 5
+)
+
+// ErrServerClosed is returned by Serve after the server is shut down.
+var ErrServerClosed = errors.New("smtpd: server closed")
+
+// Error is an error a Backend returns to reply with a specific code, such
+// as to refuse a recipient permanently rather than ask for a retry.
+type Error struct {
+	Code     int
+	Enhanced string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s %s", e.Code, e.Enhanced, e.Message)
+}
+
+var (
+	// ErrNoMailbox refuses a recipient that doesn't exist.
+	ErrNoMailbox = &Error{Code: 550, Enhanced: "5.1.1", Message: "No such mailbox"}
+
+	// ErrRelayDenied refuses a recipient at a domain the server isn't for.
+	ErrRelayDenied = &Error{Code: 550, Enhanced: "5.7.1", Message: "Relaying denied"}
+
+	// ErrSenderDenied refuses a sender that isn't allowed to send to a
+	// recipient.
+	ErrSenderDenied = &Error{Code: 550, Enhanced: "5.7.1", Message: "Sender not allowed"}
+
+	// ErrTooMany asks a sender to try again later.
+	ErrTooMany = &Error{Code: 450, Enhanced: "4.7.1", Message: "Too many messages, try again later"}
+)
+
+// Envelope is a message being received, and where it's from and to.
+type Envelope struct {
+	RemoteAddr net.Addr
+	// Helo is the name the client greeted the server with.
+	Helo string
+	// From is the sender's address, which is empty for bounces.
+	From string
+	To   []string
+	Data []byte
+}
+
+// RemoteIP returns the address of the client sending the message.
+func (e *Envelope) RemoteIP() net.IP {
+	if addr, ok := e.RemoteAddr.(*net.TCPAddr); ok {
+		return addr.IP
+	}
+
+	host, _, err := net.SplitHostPort(e.RemoteAddr.String())
+	if err != nil {
+		return nil
+	}
+
+	return net.ParseIP(host)
+}
+
+// Backend decides which messages are accepted, and receives them.
+type Backend interface {
+	// Recipient checks a recipient can be sent to by the envelope's
+	// sender, before the message is sent.
+	Recipient(ctx context.Context, env *Envelope, to string) error
+
+	// Deliver receives a message, which is only accepted if it returns
+	// nil.
+	Deliver(ctx context.Context, env *Envelope) error
+}
+
+// Server receives mail for a Backend.
+type Server struct {
+	// Domain is the name the server greets clients with.
+	Domain  string
+	Backend Backend
+	Logger  *slog.Logger
+
+	MaxSize       int64
+	MaxRecipients int
+	Timeout       time.Duration
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// Serve accepts connections on the listener until the server is shut
+// down, always returning a non-nil error.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l) {
+		return ErrServerClosed
+	}
+	defer s.untrack(l)
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(time.Millisecond * 100)
+				continue
+			}
+
+			return err
+		}
+
+		c := s.newConn(nc)
+		if !s.trackConn(c, true) {
+			nc.Close()
+			return ErrServerClosed
+		}
+
+		go func() {
+			defer s.trackConn(c, false)
+			c.serve()
+		}()
+	}
+}
+
+// Shutdown stops accepting connections, and waits for the open ones to
+// finish their current message and quit until the context is done, when
+// they're closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+
+	// Idle connections are waiting on a command, so they're told to leave
+	for c := range s.conns {
+		c.shutdown()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.nc.Close()
+		}
+		s.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+func (s *Server) track(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	if s.listeners == nil {
+		s.listeners = map[net.Listener]struct{}{}
+	}
+
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, l)
+}
+
+func (s *Server) trackConn(c *conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.conns, c)
+		s.wg.Done()
+		return true
+	}
+
+	if s.closed {
+		return false
+	}
+
+	if s.conns == nil {
+		s.conns = map[*conn]struct{}{}
+	}
+
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+
+	return true
+}
+
+func (s *Server) maxSize() int64 {
+	if s.MaxSize > 0 {
+		return s.MaxSize
+	}
+
+	return DefaultMaxSize
+}
+
+func (s *Server) maxRecipients() int {
+	if s.MaxRecipients > 0 {
+		return s.MaxRecipients
+	}
+
+	return DefaultMaxRecipients
+}
+
+func (s *Server) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+
+	return DefaultTimeout
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+
+	return slog.Default()
+}
@@ -0,0 +1,333 @@
+package smtpd_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dreamsofcode-io/guestbook/internal/smtpd"
+)
+
+// backend accepts mail to guestbook.example.com, other than to nobody@,
+// and records what it's delivered.
+type backend struct {
+	mu        sync.Mutex
+	delivered []*smtpd.Envelope
+	err       error
+}
+
+func (b *backend) Recipient(ctx context.Context, env *smtpd.Envelope, to string) error {
+	switch {
+	case !strings.HasSuffix(to, "@guestbook.example.com"):
+		return smtpd.ErrRelayDenied
+	case strings.HasPrefix(to, "nobody@"):
+		return smtpd.ErrNoMailbox
+	case env.From == "spammer@example.com":
+		return smtpd.ErrSenderDenied
+	}
+
+	return nil
+}
+
+func (b *backend) Deliver(ctx context.Context, env *smtpd.Envelope) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+
+	b.delivered = append(b.delivered, env)
+	return nil
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func serve(t *testing.T, b *backend) (*smtpd.Server, string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &smtpd.Server{
+		Domain:        "guestbook.example.com",
+		Backend:       b,
+		Logger:        discard,
+		MaxSize:       1024,
+		MaxRecipients: 2,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(l)
+	}()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		s.Shutdown(ctx)
+		assert.ErrorIs(t, <-done, smtpd.ErrServerClosed)
+	})
+
+	return s, l.Addr().String()
+}
+
+func send(addr string, from string, to []string, msg string) error {
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello("mail.example.com"); err != nil {
+		return err
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+const message = "From: guest@example.com\r\n" +
+	"Subject: Hello\r\n" +
+	"\r\n" +
+	"Lovely party!\r\n" +
+	".Dots at the start of a line are kept\r\n"
+
+func TestDeliver(t *testing.T) {
+	testCases := []struct {
+		Description string
+		From        string
+		To          []string
+		Message     string
+		Err         error
+		ExpectedErr string
+	}{
+		{
+			Description: "delivered",
+			From:        "guest@example.com",
+			To:          []string{"party@guestbook.example.com"},
+			Message:     message,
+		},
+		{
+			Description: "unknown mailbox",
+			From:        "guest@example.com",
+			To:          []string{"nobody@guestbook.example.com"},
+			Message:     message,
+			ExpectedErr: "550 5.1.1 No such mailbox",
+		},
+		{
+			Description: "relaying",
+			From:        "guest@example.com",
+			To:          []string{"someone@example.org"},
+			Message:     message,
+			ExpectedErr: "550 5.7.1 Relaying denied",
+		},
+		{
+			Description: "sender not allowed",
+			From:        "spammer@example.com",
+			To:          []string{"party@guestbook.example.com"},
+			Message:     message,
+			ExpectedErr: "550 5.7.1 Sender not allowed",
+		},
+		{
+			Description: "too many recipients",
+			From:        "guest@example.com",
+			To: []string{
+				"a@guestbook.example.com", "b@guestbook.example.com", "c@guestbook.example.com",
+			},
+			Message:     message,
+			ExpectedErr: "452 4.5.3 Too many recipients",
+		},
+		{
+			Description: "too big",
+			From:        "guest@example.com",
+			To:          []string{"party@guestbook.example.com"},
+			Message:     message + strings.Repeat("Lovely party!\r\n", 100),
+			ExpectedErr: "552 5.3.4 Message too big",
+		},
+		{
+			Description: "backend failure is temporary",
+			From:        "guest@example.com",
+			To:          []string{"party@guestbook.example.com"},
+			Message:     message,
+			Err:         errors.New("database is down"),
+			ExpectedErr: "451 4.3.0 Temporary failure, try again later",
+		},
+		{
+			Description: "backend refusal",
+			From:        "guest@example.com",
+			To:          []string{"party@guestbook.example.com"},
+			Message:     message,
+			Err:         &smtpd.Error{Code: 550, Enhanced: "5.7.1", Message: "Blank messages don't count"},
+			ExpectedErr: "550 5.7.1 Blank messages don't count",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			b := &backend{err: tc.Err}
+			_, addr := serve(t, b)
+
+			err := send(addr, tc.From, tc.To, tc.Message)
+			if tc.ExpectedErr != "" {
+				var reply *textproto.Error
+				require.ErrorAs(t, err, &reply)
+				assert.Equal(t, tc.ExpectedErr, fmt.Sprintf("%d %s", reply.Code, reply.Msg))
+				assert.Empty(t, b.delivered)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, b.delivered, 1)
+
+			env := b.delivered[0]
+			assert.Equal(t, tc.From, env.From)
+			assert.Equal(t, tc.To, env.To)
+			assert.Equal(t, "mail.example.com", env.Helo)
+			assert.Equal(t, "127.0.0.1", env.RemoteIP().String())
+			assert.Equal(t, strings.ReplaceAll(tc.Message, "\r\n", "\n"), string(env.Data))
+		})
+	}
+}
+
+func TestCommands(t *testing.T) {
+	_, addr := serve(t, &backend{})
+
+	nc, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer nc.Close()
+
+	c := textproto.NewConn(nc)
+
+	_, _, err = c.ReadResponse(220)
+	require.NoError(t, err)
+
+	steps := []struct {
+		Command string
+		Code    int
+		Message string
+	}{
+		{"MAIL FROM:<guest@example.com>", 503, "5.5.1 Say hello first"},
+		{"EHLO mail.example.com", 250, "guestbook.example.com\nSIZE 1024\n8BITMIME\nENHANCEDSTATUSCODES"},
+		{"RCPT TO:<party@guestbook.example.com>", 503, "5.5.1 Need MAIL first"},
+		{"MAIL FROM:guest@example.com", 501, "5.5.4 Syntax: MAIL FROM:<address>"},
+		{"MAIL FROM:<guest@example.com> SIZE=4096", 552, "5.3.4 Message too big"},
+		{"mail from:<> SIZE=100", 250, "2.1.0 OK"},
+		{"MAIL FROM:<guest@example.com>", 503, "5.5.1 Sender already given"},
+		{"DATA", 503, "5.5.1 Need RCPT first"},
+		{"RCPT TO:<@relay.example.com:party@guestbook.example.com>", 250, "2.1.5 OK"},
+		{"RSET", 250, "2.0.0 OK"},
+		{"DATA", 503, "5.5.1 Need RCPT first"},
+		{"STARTTLS", 502, "5.5.1 Not implemented"},
+		{"HELP", 500, "5.5.2 Unrecognized command"},
+		{"NOOP", 250, "2.0.0 OK"},
+		{"QUIT", 221, "2.0.0 Bye"},
+	}
+
+	for _, step := range steps {
+		id, err := c.Cmd("%s", step.Command)
+		require.NoError(t, err)
+
+		c.StartResponse(id)
+		code, msg, _ := c.ReadResponse(0)
+		c.EndResponse(id)
+
+		assert.Equal(t, step.Code, code, step.Command)
+		assert.Equal(t, step.Message, msg, step.Command)
+	}
+}
+
+func TestTooManyErrors(t *testing.T) {
+	_, addr := serve(t, &backend{})
+
+	nc, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer nc.Close()
+
+	c := textproto.NewConn(nc)
+
+	_, _, err = c.ReadResponse(220)
+	require.NoError(t, err)
+
+	for range 9 {
+		require.NoError(t, c.PrintfLine("BOGUS"))
+		code, _, _ := c.ReadResponse(0)
+		assert.Equal(t, 500, code)
+	}
+
+	require.NoError(t, c.PrintfLine("BOGUS"))
+	code, _, _ := c.ReadResponse(0)
+	assert.Equal(t, 500, code)
+
+	code, msg, _ := c.ReadResponse(0)
+	assert.Equal(t, 421, code)
+	assert.Equal(t, "4.7.0 Too many errors, closing connection", msg)
+}
+
+func TestShutdown(t *testing.T) {
+	s, addr := serve(t, &backend{})
+
+	nc, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer nc.Close()
+
+	c := textproto.NewConn(nc)
+
+	_, _, err = c.ReadResponse(220)
+	require.NoError(t, err)
+
+	id, err := c.Cmd("NOOP")
+	require.NoError(t, err)
+	c.StartResponse(id)
+	_, _, err = c.ReadResponse(250)
+	c.EndResponse(id)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The idle connection is told the server is going away
+	require.NoError(t, s.Shutdown(ctx))
+
+	code, msg, _ := c.ReadResponse(0)
+	assert.Equal(t, 421, code)
+	assert.Equal(t, "4.3.2 Shutting down", msg)
+
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err)
+}
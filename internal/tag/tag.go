@@ -0,0 +1,95 @@
+// Package tag extracts #hashtags from messages and renders them as links.
+package tag
+
+import (
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MaxLength is the longest tag that will be recognised.
+const MaxLength = 64
+
+// re matches a hashtag that isn't part of a longer word, such as a URL
+// fragment or an HTML entity.
+var re = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#/])#([\p{L}\p{N}_]+)`)
+
+// Normalize returns the canonical form of a tag, or false if it isn't a
+// valid tag.
+func Normalize(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+
+	if tag == "" || len([]rune(tag)) > MaxLength {
+		return "", false
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsNumber(r), r == '_':
+		default:
+			return "", false
+		}
+	}
+
+	return tag, hasLetter
+}
+
+// Parse returns the distinct tags in a message, in the order they first
+// appear.
+func Parse(message string) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+
+	for _, match := range re.FindAllStringSubmatch(message, -1) {
+		tag, ok := Normalize(match[1])
+		if !ok || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// Path returns the path of a tag's listing page for the guestbook served
+// under base.
+func Path(base string, tag string) string {
+	return base + "/tags/" + url.PathEscape(tag)
+}
+
+// Linkify escapes the message for HTML, turning each tag into a link to
+// its listing page.
+func Linkify(message string, base string) template.HTML {
+	var b strings.Builder
+
+	last := 0
+	for _, idx := range re.FindAllStringSubmatchIndex(message, -1) {
+		// idx[2] and idx[3] bound the tag, the # sits just before it
+		start, end := idx[2]-1, idx[3]
+
+		tag, ok := Normalize(message[idx[2]:end])
+		if !ok {
+			continue
+		}
+
+		b.WriteString(template.HTMLEscapeString(message[last:start]))
+		b.WriteString(`<a class="underline" href="`)
+		b.WriteString(template.HTMLEscapeString(Path(base, tag)))
+		b.WriteString(`">`)
+		b.WriteString(template.HTMLEscapeString(message[start:end]))
+		b.WriteString(`</a>`)
+
+		last = end
+	}
+
+	b.WriteString(template.HTMLEscapeString(message[last:]))
+
+	return template.HTML(b.String())
+}
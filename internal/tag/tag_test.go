@@ -0,0 +1,40 @@
+package tag_test
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/tag"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Message     string
+		Expected    []string
+	}{
+		{"no tags", "hello world", []string{}},
+		{"single tag", "congrats #Wedding", []string{"wedding"}},
+		{"duplicate tags", "#party #Party #fun", []string{"party", "fun"}},
+		{"numbers only are not tags", "we're #1", []string{}},
+		{"url fragments are ignored", "see https://example.com/#top", []string{}},
+		{"html entities are ignored", "&#39;quoted&#39;", []string{}},
+		{"unicode tags", "#café time", []string{"café"}},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Description, func(t *testing.T) {
+			assert.Equal(t, test.Expected, tag.Parse(test.Message))
+		})
+	}
+}
+
+func TestLinkify(t *testing.T) {
+	assert.Equal(
+		t,
+		template.HTML(`&lt;b&gt;hi&lt;/b&gt; <a class="underline" href="/g/party/tags/fun">#Fun</a>!`),
+		tag.Linkify("<b>hi</b> #Fun!", "/g/party"),
+	)
+}
@@ -0,0 +1,45 @@
+package theme
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxCSSLength is the largest custom stylesheet an owner can store.
+const MaxCSSLength = 20 * 1024
+
+var (
+	comments = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+	// unsafe matches constructs that can load remote resources or run
+	// script, which custom CSS is never allowed to use.
+	unsafe = regexp.MustCompile(
+		`(?i)@import|@charset|@namespace|expression\s*\(|javascript:|vbscript:|` +
+			`behavior\s*:|-moz-binding|url\s*\(|image-set\s*\(|src\s*\(`,
+	)
+)
+
+// SanitizeCSS strips anything from owner supplied CSS that could load
+// external resources or execute script. Escapes and comments are removed
+// first so they can't be used to disguise those constructs.
+func SanitizeCSS(css string) string {
+	if len(css) > MaxCSSLength {
+		css = css[:MaxCSSLength]
+	}
+
+	css = strings.ToValidUTF8(css, "")
+	css = comments.ReplaceAllString(css, "")
+	css = strings.NewReplacer(`\`, "", "<", "", "\x00", "").Replace(css)
+
+	// Removing one match can join the text around it into another, so
+	// keep going until nothing changes
+	for {
+		cleaned := unsafe.ReplaceAllString(css, "")
+		if cleaned == css {
+			break
+		}
+		css = cleaned
+	}
+
+	return strings.TrimSpace(css)
+}
@@ -0,0 +1,4 @@
+// Package theme provides per-guestbook themes. Built-in themes restyle
+// pages with an extra stylesheet, while themes found in the themes
+// directory may also override individual templates.
+package theme
@@ -0,0 +1,133 @@
+package theme
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Default is the theme used when a guestbook hasn't chosen one.
+const Default = "default"
+
+// builtIn themes ship with a stylesheet in static/themes.
+var builtIn = []string{Default, "light", "forest", "sunset"}
+
+// Set holds the default templates along with any theme overrides.
+type Set struct {
+	logger    *slog.Logger
+	base      *template.Template
+	overrides map[string]*template.Template
+	names     []string
+}
+
+// Load creates a Set from the default templates, looking for template
+// overrides in a directory per theme inside dir. An override that fails to
+// parse is logged and skipped, leaving that theme on the defaults.
+func Load(logger *slog.Logger, base *template.Template, dir string) *Set {
+	set := &Set{
+		logger:    logger,
+		base:      base,
+		overrides: map[string]*template.Template{},
+		names:     slices.Clone(builtIn),
+	}
+
+	if dir == "" {
+		return set
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Error("failed to read themes dir", slog.Any("error", err))
+		return set
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		if !slices.Contains(set.names, name) {
+			set.names = append(set.names, name)
+		}
+
+		tmpl, err := set.parse(filepath.Join(dir, name))
+		if err != nil {
+			logger.Error(
+				"failed to parse theme, falling back to defaults",
+				slog.String("theme", name), slog.Any("error", err),
+			)
+			continue
+		}
+
+		if tmpl != nil {
+			set.overrides[name] = tmpl
+		}
+	}
+
+	return set
+}
+
+// parse layers the templates in dir over a copy of the defaults, returning
+// nil if the directory has no templates.
+func (s *Set) parse(dir string) (*template.Template, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	tmpl, err := s.base.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone templates: %w", err)
+	}
+
+	return tmpl.ParseFiles(files...)
+}
+
+// Names returns the themes a guestbook can choose from.
+func (s *Set) Names() []string {
+	return s.names
+}
+
+// Has reports whether the theme exists.
+func (s *Set) Has(name string) bool {
+	return slices.Contains(s.names, name)
+}
+
+// HasStylesheet reports whether the theme ships a stylesheet.
+func HasStylesheet(name string) bool {
+	return name != Default && slices.Contains(builtIn, name)
+}
+
+// Base returns the default templates.
+func (s *Set) Base() *template.Template {
+	return s.base
+}
+
+// ExecuteTemplate renders the named template using the given theme. The
+// override is rendered into a buffer first so that if it fails, the
+// default template can be rendered in its place.
+func (s *Set) ExecuteTemplate(w io.Writer, theme string, name string, data any) error {
+	tmpl, ok := s.overrides[theme]
+	if !ok {
+		return s.base.ExecuteTemplate(w, name, data)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		s.logger.Error(
+			"failed to render theme template, falling back to defaults",
+			slog.String("theme", theme), slog.String("template", name),
+			slog.Any("error", err),
+		)
+		return s.base.ExecuteTemplate(w, name, data)
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
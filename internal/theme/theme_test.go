@@ -0,0 +1,69 @@
+package theme_test
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/internal/theme"
+)
+
+func TestSanitizeCSS(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Input       string
+		Expected    string
+	}{
+		{"plain css is kept", "body { color: red; }", "body { color: red; }"},
+		{"imports are removed", `@import "https://evil.example";`, `"https://evil.example";`},
+		{"urls are removed", "a { background: url(https://x) }", "a { background: https://x) }"},
+		{"escapes cannot hide urls", `a { background: \75rl(https://x) }`, "a { background: 75rl(https://x) }"},
+		{"comments cannot hide urls", "a { background: ur/**/l(https://x) }", "a { background: https://x) }"},
+		{"nested matches are removed", "a { b: uurl(rl(x) }", "a { b: x) }"},
+		{"expressions are removed", "a { width: expression(alert(1)) }", "a { width: alert(1)) }"},
+		{"closing tags are removed", "</style><script>", "/style>script>"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Description, func(t *testing.T) {
+			assert.Equal(t, test.Expected, theme.SanitizeCSS(test.Input))
+		})
+	}
+}
+
+func TestLoadFallsBack(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	base := template.Must(template.New("page.html").Parse("default {{ .Name }}"))
+
+	dir := t.TempDir()
+
+	os.Mkdir(filepath.Join(dir, "good"), 0o755)
+	os.WriteFile(filepath.Join(dir, "good", "page.html"), []byte("good {{ .Name }}"), 0o644)
+
+	os.Mkdir(filepath.Join(dir, "broken"), 0o755)
+	os.WriteFile(filepath.Join(dir, "broken", "page.html"), []byte("broken {{ .Name "), 0o644)
+
+	os.Mkdir(filepath.Join(dir, "failing"), 0o755)
+	os.WriteFile(filepath.Join(dir, "failing", "page.html"), []byte("failing {{ .Missing }}"), 0o644)
+
+	set := theme.Load(logger, base, dir)
+
+	render := func(name string) string {
+		var buf bytes.Buffer
+		err := set.ExecuteTemplate(&buf, name, "page.html", struct{ Name string }{"ok"})
+		assert.NoError(t, err)
+		return buf.String()
+	}
+
+	assert.True(t, set.Has("good"))
+	assert.True(t, set.Has("broken"))
+	assert.Equal(t, "good ok", render("good"))
+	assert.Equal(t, "default ok", render("broken"))
+	assert.Equal(t, "default ok", render("failing"))
+	assert.Equal(t, "default ok", render(theme.Default))
+}
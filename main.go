@@ -6,15 +6,14 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
 
 	"github.com/dreamsofcode-io/guestbook/internal/app"
+	"github.com/dreamsofcode-io/guestbook/migrations"
 )
 
-//go:embed migrations/*.sql
-var migrations embed.FS
-
 //go:embed templates/*.html
 var templates embed.FS
 
@@ -22,10 +21,10 @@ func main() {
 	godotenv.Load()
 
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	a := app.New(logger, migrations, templates)
+	a := app.New(logger, migrations.FS, templates)
 
 	if err := a.Start(ctx); err != nil {
 		logger.Error("failed to start server", slog.Any("error", err))
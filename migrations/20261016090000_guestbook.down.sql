@@ -0,0 +1,3 @@
+ALTER TABLE guest DROP COLUMN guestbook_id;
+
+DROP TABLE guestbook;
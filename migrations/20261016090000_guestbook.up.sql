@@ -0,0 +1,21 @@
+CREATE TABLE guestbook (
+  id uuid primary key,
+  slug varchar(64) not null unique,
+  title varchar(128) not null,
+  visibility varchar(16) not null default 'public'
+    check (visibility in ('public', 'unlisted', 'access_code', 'login_required')),
+  access_code_hash text not null default '',
+  created_at timestamptz not null,
+  updated_at timestamptz not null
+);
+
+INSERT INTO guestbook (id, slug, title, created_at, updated_at)
+VALUES (gen_random_uuid(), 'default', 'Guest Book', now(), now());
+
+ALTER TABLE guest ADD COLUMN guestbook_id uuid references guestbook (id) on delete cascade;
+
+UPDATE guest SET guestbook_id = (SELECT id FROM guestbook WHERE slug = 'default');
+
+ALTER TABLE guest ALTER COLUMN guestbook_id SET NOT NULL;
+
+CREATE INDEX ON guest (guestbook_id, created_at);
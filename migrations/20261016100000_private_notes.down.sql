@@ -0,0 +1,7 @@
+ALTER TABLE guest DROP COLUMN sealed_message;
+
+ALTER TABLE guest DROP COLUMN private;
+
+ALTER TABLE guestbook DROP COLUMN owner_id;
+
+DROP TABLE account;
@@ -0,0 +1,13 @@
+CREATE TABLE account (
+  id uuid primary key,
+  email varchar(320) not null unique,
+  password_hash text not null,
+  created_at timestamptz not null,
+  updated_at timestamptz not null
+);
+
+ALTER TABLE guestbook ADD COLUMN owner_id uuid references account (id) on delete set null;
+
+ALTER TABLE guest ADD COLUMN private boolean not null default false;
+
+ALTER TABLE guest ADD COLUMN sealed_message bytea;
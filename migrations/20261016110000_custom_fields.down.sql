@@ -0,0 +1,3 @@
+ALTER TABLE guest DROP COLUMN fields;
+
+ALTER TABLE guestbook DROP COLUMN field_definitions;
@@ -0,0 +1,3 @@
+ALTER TABLE guestbook ADD COLUMN field_definitions jsonb not null default '[]';
+
+ALTER TABLE guest ADD COLUMN fields jsonb not null default '{}';
@@ -0,0 +1,3 @@
+DROP TABLE blocked_tag;
+
+DROP TABLE guest_tag;
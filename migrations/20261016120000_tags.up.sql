@@ -0,0 +1,15 @@
+CREATE TABLE guest_tag (
+  guest_id uuid not null references guest (id) on delete cascade,
+  guestbook_id uuid not null references guestbook (id) on delete cascade,
+  tag varchar(64) not null,
+  primary key (guest_id, tag)
+);
+
+CREATE INDEX ON guest_tag (guestbook_id, tag);
+
+CREATE TABLE blocked_tag (
+  guestbook_id uuid not null references guestbook (id) on delete cascade,
+  tag varchar(64) not null,
+  created_at timestamptz not null,
+  primary key (guestbook_id, tag)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE guestbook DROP COLUMN custom_css;
+
+ALTER TABLE guestbook DROP COLUMN theme;
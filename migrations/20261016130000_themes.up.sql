@@ -0,0 +1,3 @@
+ALTER TABLE guestbook ADD COLUMN theme varchar(32) not null default 'default';
+
+ALTER TABLE guestbook ADD COLUMN custom_css text not null default '';
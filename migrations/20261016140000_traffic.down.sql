@@ -0,0 +1 @@
+DROP TABLE guestbook_traffic;
@@ -0,0 +1,7 @@
+CREATE TABLE guestbook_traffic (
+  guestbook_id uuid not null references guestbook (id) on delete cascade,
+  day date not null,
+  views bigint not null,
+  visitors bigint not null,
+  primary key (guestbook_id, day)
+);
@@ -0,0 +1,10 @@
+DROP TABLE usage;
+
+ALTER TABLE account DROP COLUMN quota_api_calls;
+ALTER TABLE account DROP COLUMN quota_storage_bytes;
+ALTER TABLE account DROP COLUMN quota_messages;
+ALTER TABLE account DROP COLUMN quota_guestbooks;
+
+ALTER TABLE account DROP COLUMN plan;
+
+ALTER TABLE account DROP COLUMN admin;
@@ -0,0 +1,17 @@
+ALTER TABLE account ADD COLUMN admin boolean not null default false;
+
+ALTER TABLE account ADD COLUMN plan text not null default 'free';
+
+-- Per account overrides of the plan's limits, null uses the plan's limit
+ALTER TABLE account ADD COLUMN quota_guestbooks bigint;
+ALTER TABLE account ADD COLUMN quota_messages bigint;
+ALTER TABLE account ADD COLUMN quota_storage_bytes bigint;
+ALTER TABLE account ADD COLUMN quota_api_calls bigint;
+
+CREATE TABLE usage (
+  account_id uuid not null references account (id) on delete cascade,
+  period date not null,
+  metric text not null,
+  amount bigint not null default 0,
+  primary key (account_id, period, metric)
+);
@@ -0,0 +1,5 @@
+DROP TABLE audit_log;
+
+DROP TABLE ban;
+
+ALTER TABLE guest DROP COLUMN status;
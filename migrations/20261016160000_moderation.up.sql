@@ -0,0 +1,19 @@
+ALTER TABLE guest ADD COLUMN status varchar(16) not null default 'approved'
+  check (status in ('pending', 'approved', 'rejected'));
+
+CREATE TABLE ban (
+  network inet primary key,
+  reason text not null default '',
+  created_at timestamptz not null
+);
+
+CREATE TABLE audit_log (
+  id uuid primary key,
+  account_id uuid references account (id) on delete set null,
+  action varchar(32) not null,
+  query text not null,
+  affected bigint not null,
+  created_at timestamptz not null
+);
+
+CREATE INDEX ON audit_log (created_at);
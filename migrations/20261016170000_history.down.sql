@@ -0,0 +1,7 @@
+DROP TABLE report;
+
+DROP TABLE rate_limit_hit;
+
+DROP INDEX ban_network_idx;
+
+ALTER TABLE guest DROP COLUMN fingerprint;
@@ -0,0 +1,29 @@
+ALTER TABLE guest ADD COLUMN fingerprint varchar(64) not null default '';
+
+CREATE INDEX ON guest USING gist (ip inet_ops);
+
+CREATE INDEX ON guest (fingerprint) WHERE fingerprint <> '';
+
+CREATE INDEX ON ban USING gist (network inet_ops);
+
+CREATE TABLE rate_limit_hit (
+  id bigint generated always as identity primary key,
+  ip inet not null,
+  fingerprint varchar(64) not null,
+  path text not null,
+  created_at timestamptz not null
+);
+
+CREATE INDEX ON rate_limit_hit USING gist (ip inet_ops);
+
+CREATE INDEX ON rate_limit_hit (fingerprint);
+
+CREATE TABLE report (
+  id uuid primary key,
+  guest_id uuid not null references guest (id) on delete cascade,
+  reporter_ip inet not null,
+  reason varchar(256) not null default '',
+  created_at timestamptz not null
+);
+
+CREATE INDEX ON report (guest_id);
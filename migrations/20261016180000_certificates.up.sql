@@ -0,0 +1,5 @@
+CREATE TABLE certificate_cache (
+  key text primary key,
+  data bytea not null,
+  updated_at timestamptz not null default now()
+);
@@ -0,0 +1,3 @@
+DROP TABLE push_subscription;
+DROP TABLE vapid_key;
+DROP TABLE job;
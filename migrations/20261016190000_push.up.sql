@@ -0,0 +1,34 @@
+CREATE TABLE job (
+  id bigint generated always as identity primary key,
+  kind varchar(64) not null,
+  payload jsonb not null,
+  run_at timestamptz not null,
+  attempts int not null default 0,
+  last_error text not null default '',
+  created_at timestamptz not null
+);
+
+CREATE INDEX ON job (run_at);
+
+-- There's a single VAPID key, shared between replicas, unless one is
+-- configured.
+CREATE TABLE vapid_key (
+  id boolean primary key default true check (id),
+  private_key bytea not null,
+  created_at timestamptz not null
+);
+
+CREATE TABLE push_subscription (
+  id uuid primary key,
+  endpoint text not null,
+  p256dh bytea not null,
+  auth bytea not null,
+  account_id uuid references account (id) on delete cascade,
+  guest_id uuid references guest (id) on delete cascade,
+  created_at timestamptz not null,
+  check ((account_id is null) != (guest_id is null))
+);
+
+CREATE UNIQUE INDEX ON push_subscription (endpoint, account_id) WHERE account_id IS NOT NULL;
+CREATE UNIQUE INDEX ON push_subscription (endpoint, guest_id) WHERE guest_id IS NOT NULL;
+CREATE INDEX ON push_subscription (guest_id);
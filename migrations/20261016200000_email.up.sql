@@ -0,0 +1,8 @@
+-- Guestbooks with a row here accept messages by email, from any sender
+-- when allowed_senders is empty, and otherwise only from the addresses and
+-- @domains listed.
+CREATE TABLE guestbook_email (
+  guestbook_id uuid primary key references guestbook (id) on delete cascade,
+  allowed_senders text[] not null default '{}',
+  updated_at timestamptz not null
+);
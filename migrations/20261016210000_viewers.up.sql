@@ -0,0 +1,8 @@
+-- Accounts invited by a guestbook's owner to view it while it requires
+-- signing in.
+CREATE TABLE guestbook_viewer (
+  guestbook_id uuid not null references guestbook (id) on delete cascade,
+  account_id uuid not null references account (id) on delete cascade,
+  created_at timestamptz not null,
+  primary key (guestbook_id, account_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE guest DROP COLUMN sealed_fields;
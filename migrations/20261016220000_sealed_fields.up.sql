@@ -0,0 +1,3 @@
+-- The custom field values of private messages are stored encrypted, as
+-- their text is.
+ALTER TABLE guest ADD COLUMN sealed_fields bytea;
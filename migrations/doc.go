@@ -0,0 +1,6 @@
+// Package migrations holds the guestbook's Postgres schema, as the
+// up and down migrations golang-migrate applies in order.
+//
+// Services embedding the guestbook can apply them with guestbook.Migrate,
+// or through their own tooling from FS.
+package migrations
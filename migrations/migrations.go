@@ -0,0 +1,9 @@
+package migrations
+
+import "embed"
+
+// FS contains the migrations, named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, at its root.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,24 @@
+package migrations_test
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dreamsofcode-io/guestbook/migrations"
+)
+
+func TestFS(t *testing.T) {
+	ups, err := fs.Glob(migrations.FS, "*.up.sql")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ups)
+
+	for _, up := range ups {
+		down := strings.TrimSuffix(up, ".up.sql") + ".down.sql"
+
+		_, err := fs.Stat(migrations.FS, down)
+		assert.NoError(t, err, "%s has no down migration", up)
+	}
+}
@@ -1,13 +1,362 @@
 -- name: Insert :one
-INSERT INTO guest (id, message, created_at, updated_at, ip)
-VALUES ($1, $2, $3, $3, $4)
+INSERT INTO guest (
+  id, message, created_at, updated_at, ip, guestbook_id, private,
+  sealed_message, fields, fingerprint, status, sealed_fields
+)
+VALUES ($1, $2, $3, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 RETURNING *;
 
 -- name: FindAll :many
 SELECT *
 FROM guest
+WHERE guestbook_id = $1 AND NOT private AND status = 'approved'
 ORDER BY created_at DESC
-LIMIT $1;
+LIMIT $2;
+
+-- name: FindAllWithPrivate :many
+SELECT *
+FROM guest
+WHERE guestbook_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
 
 -- name: Count :one
-SELECT COUNT(*) FROM guest;
+SELECT COUNT(*) FROM guest
+WHERE guestbook_id = $1 AND NOT private AND status = 'approved';
+
+-- name: FindGuestbookBySlug :one
+SELECT *
+FROM guestbook
+WHERE slug = $1;
+
+-- name: ClaimGuestbook :exec
+UPDATE guestbook
+SET owner_id = $2, updated_at = now()
+WHERE slug = $1 AND owner_id IS NULL;
+
+-- name: UpdateGuestbookFields :exec
+UPDATE guestbook
+SET field_definitions = $2, updated_at = now()
+WHERE id = $1;
+
+-- name: FindAccount :one
+SELECT *
+FROM account
+WHERE id = $1;
+
+-- name: FindAccountForUpdate :one
+SELECT *
+FROM account
+WHERE id = $1
+FOR UPDATE;
+
+-- name: FindAccountByEmail :one
+SELECT *
+FROM account
+WHERE email = $1;
+
+-- name: UpsertAccount :one
+INSERT INTO account (id, email, password_hash, created_at, updated_at, admin)
+VALUES ($1, $2, $3, $4, $4, $5)
+ON CONFLICT (email) DO UPDATE
+SET password_hash = EXCLUDED.password_hash, updated_at = EXCLUDED.updated_at,
+    admin = EXCLUDED.admin
+RETURNING *;
+
+-- name: InsertGuestTag :exec
+INSERT INTO guest_tag (guest_id, guestbook_id, tag)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: FindAllByTag :many
+SELECT guest.*
+FROM guest
+JOIN guest_tag ON guest_tag.guest_id = guest.id
+WHERE guest_tag.guestbook_id = $1 AND guest_tag.tag = $2 AND NOT guest.private
+  AND guest.status = 'approved'
+ORDER BY guest.created_at DESC
+LIMIT $3;
+
+-- name: CountTags :many
+SELECT tag, COUNT(*) AS count
+FROM guest_tag
+JOIN guest ON guest.id = guest_tag.guest_id
+WHERE guest_tag.guestbook_id = $1 AND guest.status = 'approved'
+  AND tag NOT IN (
+    SELECT blocked_tag.tag FROM blocked_tag
+    WHERE blocked_tag.guestbook_id = $1
+  )
+GROUP BY tag
+ORDER BY count DESC, tag
+LIMIT $2;
+
+-- name: FindBlockedTags :many
+SELECT tag
+FROM blocked_tag
+WHERE guestbook_id = $1
+ORDER BY tag;
+
+-- name: BlockTag :exec
+INSERT INTO blocked_tag (guestbook_id, tag, created_at)
+VALUES ($1, $2, now())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockTag :exec
+DELETE FROM blocked_tag
+WHERE guestbook_id = $1 AND tag = $2;
+
+-- name: UpdateGuestbookTheme :exec
+UPDATE guestbook
+SET theme = $2, custom_css = $3, updated_at = now()
+WHERE id = $1;
+
+-- name: UpsertTraffic :exec
+INSERT INTO guestbook_traffic (guestbook_id, day, views, visitors)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (guestbook_id, day) DO UPDATE
+SET views = GREATEST(guestbook_traffic.views, EXCLUDED.views),
+    visitors = GREATEST(guestbook_traffic.visitors, EXCLUDED.visitors);
+
+-- name: FindTraffic :many
+SELECT *
+FROM guestbook_traffic
+WHERE guestbook_id = $1 AND day >= $2
+ORDER BY day DESC;
+
+-- name: InsertAccount :one
+INSERT INTO account (id, email, password_hash, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $4)
+RETURNING *;
+
+-- name: InsertGuestbook :one
+INSERT INTO guestbook (
+  id, slug, title, visibility, access_code_hash, owner_id, created_at,
+  updated_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+RETURNING *;
+
+-- name: FindGuestbooksByOwner :many
+SELECT *
+FROM guestbook
+WHERE owner_id = $1
+ORDER BY created_at;
+
+-- name: UpdateGuestbookSettings :exec
+UPDATE guestbook
+SET title = $2, visibility = $3, access_code_hash = $4, updated_at = now()
+WHERE id = $1;
+
+-- name: CountGuestbooksByOwner :one
+SELECT COUNT(*)
+FROM guestbook
+WHERE owner_id = $1;
+
+-- name: CountGuestbooksGroupedByOwner :many
+SELECT owner_id, COUNT(*) AS count
+FROM guestbook
+WHERE owner_id IS NOT NULL
+GROUP BY owner_id;
+
+-- name: FindAccounts :many
+SELECT *
+FROM account
+ORDER BY email;
+
+-- name: UpdateAccountPlan :exec
+UPDATE account
+SET plan = $2,
+    quota_guestbooks = $3,
+    quota_messages = $4,
+    quota_storage_bytes = $5,
+    quota_api_calls = $6,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: FindUsage :many
+SELECT *
+FROM usage
+WHERE account_id = $1 AND period = $2;
+
+-- name: FindUsageByPeriod :many
+SELECT *
+FROM usage
+WHERE period = $1;
+
+-- name: IncrementUsage :one
+INSERT INTO usage (account_id, period, metric, amount)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (account_id, period, metric) DO UPDATE
+SET amount = usage.amount + EXCLUDED.amount
+RETURNING amount;
+
+-- name: IsBanned :one
+SELECT EXISTS (
+  SELECT 1 FROM ban WHERE sqlc.arg(ip)::inet <<= network
+);
+
+-- name: InsertBan :exec
+INSERT INTO ban (network, reason, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: InsertAuditLog :exec
+INSERT INTO audit_log (id, account_id, action, query, affected, created_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: FindAuditLog :many
+SELECT audit_log.id, audit_log.action, audit_log.query, audit_log.affected,
+  audit_log.created_at, account.email
+FROM audit_log
+LEFT JOIN account ON account.id = audit_log.account_id
+ORDER BY audit_log.created_at DESC
+LIMIT $1;
+
+-- name: InsertRateLimitHit :exec
+INSERT INTO rate_limit_hit (ip, fingerprint, path, created_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: InsertReport :execrows
+INSERT INTO report (id, guest_id, reporter_ip, reason, created_at)
+SELECT $1, guest.id, $2, $3, $4
+FROM guest
+WHERE guest.id = sqlc.arg(guest_id) AND guest.guestbook_id = sqlc.arg(guestbook_id);
+
+-- name: FindRateLimitHitsByNetwork :many
+SELECT *
+FROM rate_limit_hit
+WHERE ip <<= sqlc.arg(network)::cidr
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: FindRateLimitHitsByFingerprint :many
+SELECT *
+FROM rate_limit_hit
+WHERE fingerprint = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: FindReportsByNetwork :many
+SELECT report.id, report.guest_id, report.reporter_ip, report.reason,
+  report.created_at, guest.message, guest.private
+FROM report
+JOIN guest ON guest.id = report.guest_id
+WHERE guest.ip <<= sqlc.arg(network)::cidr
+ORDER BY report.created_at DESC
+LIMIT $2;
+
+-- name: FindReportsByFingerprint :many
+SELECT report.id, report.guest_id, report.reporter_ip, report.reason,
+  report.created_at, guest.message, guest.private
+FROM report
+JOIN guest ON guest.id = report.guest_id
+WHERE guest.fingerprint = $1
+ORDER BY report.created_at DESC
+LIMIT $2;
+
+-- name: FindBansByNetwork :many
+SELECT *
+FROM ban
+WHERE network && sqlc.arg(network)::cidr
+ORDER BY created_at DESC;
+
+-- name: FindBansByFingerprint :many
+SELECT *
+FROM ban
+WHERE EXISTS (
+  SELECT 1 FROM guest
+  WHERE guest.fingerprint = $1 AND guest.ip <<= ban.network
+)
+ORDER BY created_at DESC;
+
+-- name: FindCachedCertificate :one
+SELECT data FROM certificate_cache WHERE key = $1;
+
+-- name: UpsertCachedCertificate :exec
+INSERT INTO certificate_cache (key, data, updated_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (key) DO UPDATE
+SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at;
+
+-- name: DeleteCachedCertificate :exec
+DELETE FROM certificate_cache WHERE key = $1;
+
+-- name: InsertJob :exec
+INSERT INTO job (kind, payload, run_at, created_at)
+VALUES ($1, $2, $3, NOW());
+
+-- name: ClaimJob :one
+UPDATE job
+SET run_at = sqlc.arg(lease_until), attempts = attempts + 1
+WHERE id = (
+  SELECT id FROM job
+  WHERE run_at <= NOW()
+  ORDER BY run_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RetryJob :exec
+UPDATE job SET run_at = $2, last_error = $3 WHERE id = $1;
+
+-- name: DeleteJob :exec
+DELETE FROM job WHERE id = $1;
+
+-- name: FindVapidKey :one
+SELECT private_key FROM vapid_key;
+
+-- name: InsertVapidKey :exec
+INSERT INTO vapid_key (private_key, created_at)
+VALUES ($1, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: InsertPushSubscription :exec
+INSERT INTO push_subscription (
+  id, endpoint, p256dh, auth, account_id, guest_id, created_at
+) VALUES ($1, $2, $3, $4, $5, $6, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: FindPushSubscription :one
+SELECT * FROM push_subscription WHERE id = $1;
+
+-- name: FindPushSubscriptionsByAccount :many
+SELECT * FROM push_subscription WHERE account_id = $1;
+
+-- name: FindPushSubscriptionsByGuests :many
+SELECT * FROM push_subscription WHERE guest_id = ANY(sqlc.arg(guest_ids)::uuid[]);
+
+-- name: DeletePushSubscriptionsByEndpoint :exec
+DELETE FROM push_subscription WHERE endpoint = $1;
+
+-- name: FindGuestbookEmail :one
+SELECT * FROM guestbook_email WHERE guestbook_id = $1;
+
+-- name: UpsertGuestbookEmail :exec
+INSERT INTO guestbook_email (guestbook_id, allowed_senders, updated_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (guestbook_id) DO UPDATE
+SET allowed_senders = EXCLUDED.allowed_senders, updated_at = EXCLUDED.updated_at;
+
+-- name: DeleteGuestbookEmail :exec
+DELETE FROM guestbook_email WHERE guestbook_id = $1;
+
+-- name: FindGuestbookViewers :many
+SELECT account.id, account.email
+FROM guestbook_viewer
+JOIN account ON account.id = guestbook_viewer.account_id
+WHERE guestbook_viewer.guestbook_id = $1
+ORDER BY account.email;
+
+-- name: IsGuestbookViewer :one
+SELECT EXISTS (
+  SELECT 1 FROM guestbook_viewer WHERE guestbook_id = $1 AND account_id = $2
+);
+
+-- name: InsertGuestbookViewer :exec
+INSERT INTO guestbook_viewer (guestbook_id, account_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteGuestbookViewer :exec
+DELETE FROM guestbook_viewer WHERE guestbook_id = $1 AND account_id = $2;